@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	config "go-password-manager/internal/config/runtimeconfig"
+	"go-password-manager/internal/crypto"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+
+	"golang.org/x/term"
+)
+
+// masterPasswordEnv lets scripts supply the master password without a prompt
+const masterPasswordEnv = "GO_PASSWORD_MANAGER_MASTER_PASSWORD"
+
+const usage = `Usage: password-manager [flags] [command]
+
+Without a command the graphical interface is started.
+
+Commands:
+  rotate-key              Generate a new vault key and rewrap every secret's data key
+  trash                   List the deleted secrets in the trash, most recently deleted first
+  restore <name>          Take a deleted secret out of the trash
+  purge [name]            Permanently remove a secret from the trash, or empty the trash
+  backups                 List the backups of the vault, newest first
+  restore-backup <id>     Replace the vault with a backup; the current vault is backed up first
+  history [name]          List the commits of a git vault, or the versions of a secret, newest first
+  checkout-at <commit> [name]
+                          List the secrets as of a commit, or print the value a secret had then
+  sync [--remote=<location>] [--prefer=local|remote] [<name>=local|remote ...]
+                          Merge the vault with the sync remote; conflicts are listed until resolved
+  serve-sync [address] <directory>
+                          Serve a directory as a sync remote over HTTP to clients holding storage.sync.token,
+                          on 127.0.0.1:8700 unless an address is given; use TLS beyond localhost
+`
+
+// syncSettings says where the sync command syncs with
+type syncSettings struct {
+	remote   string // Location of the remote, empty when not configured
+	token    string // Shared secret for HTTP remotes and serve-sync
+	basePath string // File keeping the vault as of the last sync
+}
+
+// runCommand executes a command-line subcommand against the vault and returns the process exit code
+func runCommand(args []string, cryptoService *crypto.CryptoService, configService *config.ConfigService, secretsService *service.SecretsService, sync syncSettings, trashRetention time.Duration) int {
+	switch args[0] {
+	case "help":
+		fmt.Print(usage)
+		return 0
+	case "serve-sync":
+		// The served vault stays sealed, so this needs no password
+		switch len(args) {
+		case 2:
+			return serveSync(defaultSyncAddress, args[1], sync.token)
+		case 3:
+			return serveSync(args[1], args[2], sync.token)
+		default:
+			fmt.Fprintf(os.Stderr, "Usage: password-manager serve-sync [address] <directory>\n")
+			return 2
+		}
+	}
+
+	if err := unlockFromTerminal(cryptoService); err != nil {
+		printVaultError("Failed to unlock vault", err)
+		return 1
+	}
+
+	// Backups must stay reachable when the vault itself no longer opens
+	switch args[0] {
+	case "backups":
+		return listBackups(secretsService)
+	case "restore-backup":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: password-manager restore-backup <id>\n")
+			return 2
+		}
+		if err := secretsService.RestoreBackup(args[1]); err != nil {
+			printVaultError("Failed to restore backup", err)
+			return 1
+		}
+		fmt.Printf("Restored backup %s\n", args[1])
+		return 0
+	case "history":
+		if len(args) > 2 {
+			fmt.Fprintf(os.Stderr, "Usage: password-manager history [name]\n")
+			return 2
+		}
+		if len(args) == 2 {
+			return listVersions(secretsService, args[1])
+		}
+		return listHistory(secretsService)
+	case "checkout-at":
+		if len(args) < 2 || len(args) > 3 {
+			fmt.Fprintf(os.Stderr, "Usage: password-manager checkout-at <commit> [name]\n")
+			return 2
+		}
+		return checkoutAt(secretsService, args[1], args[2:])
+	}
+
+	if err := secretsService.ResumeKeyRotation(configService); err != nil {
+		printVaultError("Failed to resume key rotation", err)
+		return 1
+	}
+	if err := secretsService.MigrateSecrets(); err != nil {
+		printVaultError("Failed to open vault", err)
+		return 1
+	}
+	if trashRetention > 0 {
+		if _, err := secretsService.PurgeTrash(trashRetention); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to purge expired secrets from the trash: %v\n", err)
+		}
+	}
+
+	switch args[0] {
+	case "rotate-key":
+		if err := secretsService.RotateKey(configService); err != nil {
+			fmt.Fprintf(os.Stderr, "Key rotation failed: %v\n", err)
+			return 1
+		}
+		fmt.Println("Vault key rotated")
+		return 0
+	case "sync":
+		return runSync(args[1:], cryptoService, secretsService, sync)
+	case "trash":
+		return listTrash(secretsService)
+	case "restore":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: password-manager restore <name>\n")
+			return 2
+		}
+		if err := secretsService.RestoreSecret(args[1]); err != nil {
+			printVaultError("Failed to restore secret", err)
+			return 1
+		}
+		fmt.Printf("Restored %s\n", args[1])
+		return 0
+	case "purge":
+		return purgeTrash(secretsService, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// listBackups prints the available backups, newest first
+func listBackups(secretsService *service.SecretsService) int {
+	backups, err := secretsService.ListBackups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list backups: %v\n", err)
+		return 1
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups")
+		return 0
+	}
+	for _, backup := range backups {
+		fmt.Printf("%s  %s  %d bytes\n", backup.ID, backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), backup.Size)
+	}
+	return 0
+}
+
+// listTrash prints the secrets in the trash, most recently deleted first
+func listTrash(secretsService *service.SecretsService) int {
+	trash, err := secretsService.ListTrash()
+	if err != nil {
+		printVaultError("Failed to list the trash", err)
+		return 1
+	}
+	if len(trash) == 0 {
+		fmt.Println("Trash is empty")
+		return 0
+	}
+	for _, secret := range trash {
+		deletedAt := secret.DeletedAt
+		if t, err := time.Parse(time.RFC3339, secret.DeletedAt); err == nil {
+			deletedAt = t.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%s  deleted %s  v%d\n", secret.SecretName, deletedAt, secret.CurrentVersion)
+	}
+	return 0
+}
+
+// purgeTrash permanently removes the named secret from the trash, or every secret in it
+func purgeTrash(secretsService *service.SecretsService, name []string) int {
+	switch len(name) {
+	case 0:
+		purged, err := secretsService.PurgeTrash(0)
+		if err != nil {
+			printVaultError("Failed to empty the trash", err)
+			return 1
+		}
+		fmt.Printf("Purged %d secrets\n", len(purged))
+		return 0
+	case 1:
+		if err := secretsService.PurgeSecret(name[0]); err != nil {
+			printVaultError("Failed to purge secret", err)
+			return 1
+		}
+		fmt.Printf("Purged %s\n", name[0])
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: password-manager purge [name]\n")
+		return 2
+	}
+}
+
+// listHistory prints the commits of the vault, newest first
+func listHistory(secretsService *service.SecretsService) int {
+	commits, err := secretsService.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list history: %v\n", err)
+		return 1
+	}
+	if len(commits) == 0 {
+		fmt.Println("No history")
+		return 0
+	}
+	for _, commit := range commits {
+		fmt.Printf("%.12s  %s  %s  %s\n", commit.ID, commit.CreatedAt.Local().Format("2006-01-02 15:04:05"), commit.Author, commit.Message)
+	}
+	return 0
+}
+
+// listVersions prints the versions of the named secret, newest first, with who wrote them and why
+func listVersions(secretsService *service.SecretsService, name string) int {
+	secret, err := secretsService.GetSecret(name)
+	if err != nil {
+		printVaultError("Failed to read secret", err)
+		return 1
+	}
+	for _, version := range secret.GetVersionsSorted() {
+		updatedAt := version.UpdatedAt
+		if t, err := time.Parse(time.RFC3339, version.UpdatedAt); err == nil {
+			updatedAt = t.Local().Format("2006-01-02 15:04:05")
+		}
+		author := version.UpdatedBy
+		if author == "" {
+			author = "-"
+		}
+		line := fmt.Sprintf("v%d  %s  %s", version.Version, updatedAt, author)
+		if version.Version == secret.CurrentVersion {
+			line += "  (current)"
+		}
+		if version.RenamedFrom != "" {
+			line += "  renamed from " + version.RenamedFrom
+		}
+		if version.Note != "" {
+			line += "  " + version.Note
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// checkoutAt lists the secrets of the vault as of a commit, or prints the value of the named one
+func checkoutAt(secretsService *service.SecretsService, commit string, name []string) int {
+	past, err := secretsService.VaultAt(commit)
+	if err != nil {
+		printVaultError("Failed to open vault at "+commit, err)
+		return 1
+	}
+
+	if len(name) == 1 {
+		value, err := past.GetCurrentVersionValue(name[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s at %s: %v\n", name[0], commit, err)
+			return 1
+		}
+		fmt.Println(value)
+		return 0
+	}
+
+	data, err := past.LoadAllSecrets()
+	if err != nil {
+		printVaultError("Failed to open vault at "+commit, err)
+		return 1
+	}
+	if len(data.Secrets) == 0 {
+		fmt.Println("No secrets")
+		return 0
+	}
+	for _, secret := range data.Secrets {
+		fmt.Printf("%s  v%d\n", secret.SecretName, secret.CurrentVersion)
+	}
+	return 0
+}
+
+// runSync merges the vault with the remote and reports what moved, or the conflicts to resolve
+func runSync(args []string, cryptoService *crypto.CryptoService, secretsService *service.SecretsService, settings syncSettings) int {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	location := flags.String("remote", settings.remote, "Directory or URL of the sync remote")
+	prefer := flags.String("prefer", "", "Resolve every conflict in favour of local or remote")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *location == "" {
+		fmt.Fprintln(os.Stderr, "No sync remote configured, set storage.sync.remote or pass --remote")
+		return 2
+	}
+
+	resolutions := map[string]service.Resolution{}
+	for _, arg := range flags.Args() {
+		name, side, ok := strings.Cut(arg, "=")
+		if !ok || !validResolution(side) {
+			fmt.Fprintf(os.Stderr, "Invalid resolution %q, expected <name>=local or <name>=remote\n", arg)
+			return 2
+		}
+		resolutions[name] = service.Resolution(side)
+	}
+	if *prefer != "" && !validResolution(*prefer) {
+		fmt.Fprintf(os.Stderr, "Invalid --prefer %q, expected local or remote\n", *prefer)
+		return 2
+	}
+
+	remote, err := storage.NewRemote(*location, settings.token, cryptoService)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open sync remote: %v\n", err)
+		return 1
+	}
+	base := storage.NewSyncBase(settings.basePath, cryptoService)
+
+	result, err := secretsService.Sync(remote, base, resolutions)
+	if errors.Is(err, service.ErrSyncConflict) && *prefer != "" {
+		for _, conflict := range result.Conflicts {
+			if _, ok := resolutions[conflict.Name]; !ok {
+				resolutions[conflict.Name] = service.Resolution(*prefer)
+			}
+		}
+		result, err = secretsService.Sync(remote, base, resolutions)
+	}
+	if errors.Is(err, service.ErrSyncConflict) {
+		fmt.Fprintln(os.Stderr, "Sync stopped, these secrets were changed on both sides:")
+		for _, conflict := range result.Conflicts {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", conflict.Name, describeConflict(conflict))
+		}
+		fmt.Fprintln(os.Stderr, "Run 'sync <name>=local' or 'sync <name>=remote' for each of them, or 'sync --prefer=local|remote'.")
+		return 1
+	}
+	if err != nil {
+		printVaultError("Sync failed", err)
+		return 1
+	}
+
+	if len(result.Received) == 0 && len(result.Sent) == 0 {
+		fmt.Println("Already up to date")
+		return 0
+	}
+	if len(result.Received) > 0 {
+		fmt.Printf("Received: %s\n", strings.Join(result.Received, ", "))
+	}
+	if len(result.Sent) > 0 {
+		fmt.Printf("Sent: %s\n", strings.Join(result.Sent, ", "))
+	}
+	return 0
+}
+
+func validResolution(side string) bool {
+	return side == string(service.ResolveLocal) || side == string(service.ResolveRemote)
+}
+
+// describeConflict says what happened to a conflicting secret on each side
+func describeConflict(conflict domain.SyncConflict) string {
+	switch {
+	case conflict.Local == nil:
+		return fmt.Sprintf("deleted here, changed on the remote (v%d)", conflict.Remote.CurrentVersion)
+	case conflict.Remote == nil:
+		return fmt.Sprintf("changed here (v%d), deleted on the remote", conflict.Local.CurrentVersion)
+	default:
+		return fmt.Sprintf("v%d here, v%d on the remote", conflict.Local.CurrentVersion, conflict.Remote.CurrentVersion)
+	}
+}
+
+// defaultSyncAddress keeps serve-sync on this machine unless told otherwise
+const defaultSyncAddress = "127.0.0.1:8700"
+
+// serveSync serves directory as a sync remote to clients holding token until the process is stopped.
+// An address without a host binds to localhost only.
+func serveSync(address, directory, token string) int {
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "serve-sync needs a token: set storage.sync.token or SYNC_TOKEN here and on every client")
+		return 2
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid address %q: %v\n", address, err)
+		return 2
+	}
+	if host == "" {
+		host = "127.0.0.1"
+		address = net.JoinHostPort(host, port)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintln(os.Stderr, "Warning: the sync server speaks plain HTTP. Put it behind a TLS reverse proxy before exposing it beyond localhost.")
+	}
+
+	handler := storage.NewRemoteHandler(storage.NewDirectoryTransport(directory, nil), token)
+	fmt.Printf("Serving sync remote %s on http://%s/\n", directory, address)
+	server := &http.Server{Addr: address, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Sync server stopped: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printVaultError reports err, explaining integrity failures so they are not mistaken for bugs
+func printVaultError(context string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", context, err)
+	switch {
+	case errors.Is(err, service.ErrVaultTampered):
+		fmt.Fprintln(os.Stderr, "The secrets file was modified outside the password manager. Restore it from a backup, see 'backups'.")
+	case errors.Is(err, service.ErrVaultRolledBack):
+		fmt.Fprintln(os.Stderr, "The secrets file was replaced by an older copy. Restore the latest version from a backup, see 'backups'.")
+	case errors.Is(err, service.ErrSchemaTooNew):
+		fmt.Fprintln(os.Stderr, "Install the latest version of the password manager to open this vault.")
+	case errors.Is(err, service.ErrBackupKeyRotated):
+		fmt.Fprintln(os.Stderr, "Backups taken before the last key rotation cannot be restored.")
+	case errors.Is(err, service.ErrSyncIncompatible):
+		fmt.Fprintln(os.Stderr, "Both machines must use the same vault key: copy the app config and key files to the second machine before its first sync.")
+	case errors.Is(err, service.ErrSyncRolledBack):
+		fmt.Fprintln(os.Stderr, "The sync remote served an older copy of the vault than the one last synced. Check the remote before syncing again.")
+	case errors.Is(err, service.ErrCommitKeyRotated):
+		fmt.Fprintln(os.Stderr, "Commits made before the last key rotation cannot be opened.")
+	case errors.Is(err, crypto.ErrKeyMissing):
+		fmt.Fprintln(os.Stderr, "Copy the app config and key file of this vault back, a new key could not open it.")
+	}
+}
+
+// unlockFromTerminal unlocks the vault with the password from the environment or an interactive prompt
+func unlockFromTerminal(cryptoService *crypto.CryptoService) error {
+	if password := os.Getenv(masterPasswordEnv); password != "" {
+		return cryptoService.Unlock(password)
+	}
+
+	initialized, err := cryptoService.IsInitialized()
+	if err != nil {
+		return err
+	}
+
+	password, err := promptPassword("Master password: ")
+	if err != nil {
+		return err
+	}
+	if !initialized {
+		confirm, err := promptPassword("Confirm master password: ")
+		if err != nil {
+			return err
+		}
+		if confirm != password {
+			return fmt.Errorf("passwords do not match")
+		}
+	}
+	return cryptoService.Unlock(password)
+}
+
+// promptPassword reads a password from the terminal without echoing it
+func promptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	// Not a terminal, read a line from stdin instead
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
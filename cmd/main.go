@@ -24,6 +24,11 @@ var (
 func main() {
 	// Handle version flag
 	var showVersion = flag.Bool("version", false, "Show version information")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	buildCfg, err := buildconfig.Load()
@@ -46,20 +51,57 @@ func main() {
 		log.Fatalf("Failed to create config service: %v", err)
 	}
 
-	cryptoService, err := crypto.NewCryptoService(configService)
+	secretsPath, err := buildCfg.GetSecretsFilePath()
+	if err != nil {
+		log.Fatalf("Failed to get secrets file path: %v", err)
+	}
+	backendCfg := storage.BackendConfig{
+		SecretsFile:     secretsPath,
+		Directory:       buildCfg.GetVaultDir(secretsPath),
+		AppVersion:      buildCfg.Application.Version,
+		AppUser:         "e2e-user",
+		EncryptMetadata: buildCfg.Storage.EncryptMetadata,
+		CompactAfter:    buildCfg.Storage.Journal.CompactAfter,
+		Options:         []storage.Option{storage.WithLockTimeout(buildCfg.GetLockTimeout())},
+	}
+
+	cryptoService, err := crypto.NewCryptoService(configService, crypto.WithVaultCheck(func() (bool, error) {
+		return storage.Exists(buildCfg.Storage.Type, backendCfg)
+	}))
 	if err != nil {
 		log.Fatalf("Failed to create crypto service: %v", err)
 	}
 
-	secretsPath, err := buildCfg.GetSecretsFilePath()
+	backendCfg.Crypto = cryptoService
+	storageService, err := storage.New(buildCfg.Storage.Type, backendCfg)
 	if err != nil {
-		log.Fatalf("Failed to get secrets file path: %v", err)
+		log.Fatalf("Failed to create storage: %v", err)
 	}
-	storageService := storage.NewFileStorage(secretsPath, buildCfg.Application.Version, "e2e-user")
+	// The memory storage saves nothing, so there is nothing to back up
+	if backups := buildCfg.Storage.Backups; backups.Enabled && buildCfg.Storage.Type != storage.TypeMemory {
+		withBackups, err := storage.NewBackupStorage(storageService, buildCfg.GetBackupDir(secretsPath), storage.Retention{
+			KeepLast:   backups.KeepLast,
+			KeepDaily:  backups.KeepDaily,
+			KeepWeekly: backups.KeepWeekly,
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up backups: %v", err)
+		}
+		storageService = withBackups
+	}
+
+	secretsService := service.NewSecretsService(cryptoService, storageService, service.WithAuthor(buildCfg.Application.Identity))
 
-	secretsService := service.NewSecretsService(cryptoService, storageService)
+	// Run a command-line subcommand instead of the UI when one is given
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args(), cryptoService, configService, secretsService, syncSettings{
+			remote:   buildCfg.Storage.Sync.Remote,
+			token:    buildCfg.Storage.Sync.Token,
+			basePath: buildCfg.GetSyncBasePath(secretsPath),
+		}, buildCfg.GetTrashRetention()))
+	}
 
 	// Pass services to the UI
-	app := ui.NewApp(buildCfg, secretsService)
+	app := ui.NewApp(buildCfg, configService, secretsService, cryptoService)
 	app.Run()
 }
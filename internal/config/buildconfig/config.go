@@ -27,6 +27,7 @@ type ApplicationConfig struct {
 	Name        string `yaml:"name"`
 	Version     string `yaml:"version"`
 	Environment string `yaml:"environment"`
+	Identity    string `yaml:"identity"` // Recorded as the author of new versions; empty uses user@host
 }
 
 type UIConfig struct {
@@ -47,16 +48,63 @@ type LoggingConfig struct {
 
 type SecurityConfig struct {
 	Encryption EncryptionConfig `yaml:"encryption"`
+	KDF        KDFConfig        `yaml:"kdf"`
 }
 
 type EncryptionConfig struct {
 	KeySize   int    `yaml:"key_size"`
-	Algorithm string `yaml:"algorithm"`
+	Algorithm string `yaml:"algorithm"` // AEAD cipher for new ciphertexts, e.g. "AES-256-GCM"
+}
+
+// KDFConfig controls how the master password is stretched into a key-encryption key.
+// Only the parameters for the selected algorithm are used.
+type KDFConfig struct {
+	Algorithm string `yaml:"algorithm"`  // "argon2id" or "scrypt"
+	Time      uint32 `yaml:"time"`       // argon2id passes
+	MemoryKiB uint32 `yaml:"memory_kib"` // argon2id memory in KiB
+	Threads   uint8  `yaml:"threads"`    // argon2id parallelism
+	ScryptN   int    `yaml:"scrypt_n"`   // scrypt CPU/memory cost
+	ScryptR   int    `yaml:"scrypt_r"`   // scrypt block size
+	ScryptP   int    `yaml:"scrypt_p"`   // scrypt parallelism
 }
 
 type StorageConfig struct {
-	SecretsFile string `yaml:"secrets_file"`
-	ConfigFile  string `yaml:"config_file"`
+	Type            string        `yaml:"type"`      // Backend: "file", "directory", "git", "journal" or "memory"
+	Directory       string        `yaml:"directory"` // Vault root of the directory and git backends, relative to the secrets file
+	SecretsFile     string        `yaml:"secrets_file"`
+	ConfigFile      string        `yaml:"config_file"`
+	EncryptMetadata bool          `yaml:"encrypt_metadata"` // Seal the whole secrets file, not just the values
+	LockTimeout     string        `yaml:"lock_timeout"`     // How long to wait for another process to release the vault
+	Backups         BackupConfig  `yaml:"backups"`
+	Journal         JournalConfig `yaml:"journal"`
+	Sync            SyncConfig    `yaml:"sync"`
+	Trash           TrashConfig   `yaml:"trash"`
+}
+
+// TrashConfig controls how long deleted secrets can be restored
+type TrashConfig struct {
+	RetentionDays int `yaml:"retention_days"` // Days before deleted secrets are purged; 0 keeps them until purged by hand
+}
+
+// SyncConfig points at the other side of the sync command
+type SyncConfig struct {
+	Remote string `yaml:"remote"` // Directory, file:// or http(s):// URL; empty disables sync
+	Token  string `yaml:"token"`  // Shared secret sent to an HTTP remote and required by serve-sync
+}
+
+// JournalConfig tunes the journal storage backend
+type JournalConfig struct {
+	CompactAfter int `yaml:"compact_after"` // Entries to append before folding them into a snapshot
+}
+
+// BackupConfig controls the copies of the secrets file taken before every write.
+// A backup is kept if any of the keep rules selects it.
+type BackupConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Dir        string `yaml:"dir"`         // Relative paths are resolved next to the secrets file
+	KeepLast   int    `yaml:"keep_last"`   // Most recent backups to keep
+	KeepDaily  int    `yaml:"keep_daily"`  // Days to keep the newest backup of
+	KeepWeekly int    `yaml:"keep_weekly"` // Weeks to keep the newest backup of
 }
 
 type DevelopmentConfig struct {
@@ -217,6 +265,9 @@ func applyApplicationOverrides(config *Config) {
 	if env := os.Getenv("GO_PASSWORD_MANAGER_ENV"); env != "" {
 		config.Application.Environment = env
 	}
+	if env := os.Getenv("APP_IDENTITY"); env != "" {
+		config.Application.Identity = env
+	}
 }
 
 func applyUIOverrides(config *Config) {
@@ -252,12 +303,36 @@ func applySecurityOverrides(config *Config) {
 }
 
 func applyStorageOverrides(config *Config) {
+	if env := os.Getenv("STORAGE_TYPE"); env != "" {
+		config.Storage.Type = env
+	}
 	if env := os.Getenv("SECRETS_FILE_PATH"); env != "" {
 		config.Storage.SecretsFile = env
 	}
 	if env := os.Getenv("CONFIG_FILE_PATH"); env != "" {
 		config.Storage.ConfigFile = env
 	}
+	if env := os.Getenv("ENCRYPT_METADATA"); env != "" {
+		if val, err := strconv.ParseBool(env); err == nil {
+			config.Storage.EncryptMetadata = val
+		}
+	}
+	if env := os.Getenv("BACKUPS_ENABLED"); env != "" {
+		if val, err := strconv.ParseBool(env); err == nil {
+			config.Storage.Backups.Enabled = val
+		}
+	}
+	if env := os.Getenv("SYNC_REMOTE"); env != "" {
+		config.Storage.Sync.Remote = env
+	}
+	if env := os.Getenv("SYNC_TOKEN"); env != "" {
+		config.Storage.Sync.Token = env
+	}
+	if env := os.Getenv("TRASH_RETENTION_DAYS"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil {
+			config.Storage.Trash.RetentionDays = val
+		}
+	}
 }
 
 func applyDevelopmentOverrides(config *Config) {
@@ -360,6 +435,52 @@ func (c *Config) GetTestTimeout() time.Duration {
 	return duration
 }
 
+// GetLockTimeout returns how long to wait for the vault lock as a duration
+func (c *Config) GetLockTimeout() time.Duration {
+	duration, err := time.ParseDuration(c.Storage.LockTimeout)
+	if err != nil {
+		return 5 * time.Second // Default fallback
+	}
+	return duration
+}
+
+// GetVaultDir returns the root of the directory and git backends, relative to the secrets file unless absolute
+func (c *Config) GetVaultDir(secretsPath string) string {
+	dir := c.Storage.Directory
+	if dir == "" {
+		dir = "vault"
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(secretsPath), dir)
+}
+
+// GetSyncBasePath returns where the vault as of the last sync is kept, next to the secrets file
+func (c *Config) GetSyncBasePath(secretsPath string) string {
+	return secretsPath + ".sync-base"
+}
+
+// GetTrashRetention returns how long deleted secrets stay in the trash, 0 meaning until purged by hand
+func (c *Config) GetTrashRetention() time.Duration {
+	if c.Storage.Trash.RetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(c.Storage.Trash.RetentionDays) * 24 * time.Hour
+}
+
+// GetBackupDir returns the backup directory, relative to the secrets file unless absolute
+func (c *Config) GetBackupDir(secretsPath string) string {
+	dir := c.Storage.Backups.Dir
+	if dir == "" {
+		dir = "backups"
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(secretsPath), dir)
+}
+
 // GetWindowSize returns the configured window dimensions
 func (c *Config) GetWindowSize() (int, int) {
 	return c.UI.Window.Width, c.UI.Window.Height
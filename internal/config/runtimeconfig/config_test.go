@@ -95,3 +95,24 @@ func TestConfigServiceSaveAndLoad(t *testing.T) {
 		tc.Assert.Equal(1024, service2.Config.WindowWidth, "WindowWidth should be loaded from the saved file")
 	})
 }
+
+func TestConfigServiceSaveIsAtomic(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Replaces the file without leaving temporary files", func(tc *helpers.UnitTestCase) {
+		tempDir := t.TempDir()
+		configFilePath := filepath.Join(tempDir, "app.config.json")
+
+		service, err := config.NewConfigService(&mockBuildConfig{ConfigPath: configFilePath})
+		tc.Require.NoError(err)
+		tc.Require.NoError(service.SetPendingKeyUUID("next-key"))
+		tc.Require.NoError(service.SetKeyUUID("next-key"))
+
+		entries, err := os.ReadDir(tempDir)
+		tc.Require.NoError(err)
+		tc.Assert.Len(entries, 1, "Only the config file should remain")
+
+		reloaded, err := config.NewConfigService(&mockBuildConfig{ConfigPath: configFilePath})
+		tc.Require.NoError(err)
+		tc.Assert.Equal("next-key", reloaded.GetKeyUUID())
+		tc.Assert.Empty(reloaded.GetPendingKeyUUID())
+	})
+}
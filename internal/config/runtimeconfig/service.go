@@ -6,17 +6,19 @@ import (
 	"os"
 
 	buildconfig "go-password-manager/internal/config/buildconfig"
+	"go-password-manager/internal/fsutil"
 
 	"github.com/google/uuid"
 )
 
 // AppConfig represents the application configuration that is persisted on disk.
 type AppConfig struct {
-	KeyUUID      string `json:"keyUUID"`
-	AppVersion   string `json:"appVersion"`
-	WindowWidth  int    `json:"windowWidth"`
-	WindowHeight int    `json:"windowHeight"`
-	Theme        string `json:"theme"`
+	KeyUUID        string `json:"keyUUID"`
+	PendingKeyUUID string `json:"pendingKeyUUID,omitempty"` // Set while a key rotation is in progress
+	AppVersion     string `json:"appVersion"`
+	WindowWidth    int    `json:"windowWidth"`
+	WindowHeight   int    `json:"windowHeight"`
+	Theme          string `json:"theme"`
 }
 
 // ConfigService manages application configuration
@@ -81,13 +83,13 @@ func loadConfigFromFile(path string) (*AppConfig, error) {
 	return &cfg, err
 }
 
-// Save saves the configuration to disk
+// Save saves the configuration to disk. It records which key is live, so it is replaced atomically.
 func (cs *ConfigService) Save() error {
 	data, err := json.MarshalIndent(cs.Config, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(cs.path, data, 0600)
+	return fsutil.WriteFileAtomic(cs.path, data, 0600)
 }
 
 // SetWindowSize sets the window dimensions in the configuration
@@ -107,6 +109,24 @@ func (cs *ConfigService) GetKeyUUID() string {
 	return cs.Config.KeyUUID
 }
 
+// SetKeyUUID makes keyUUID the active key and clears any pending rotation
+func (cs *ConfigService) SetKeyUUID(keyUUID string) error {
+	cs.Config.KeyUUID = keyUUID
+	cs.Config.PendingKeyUUID = ""
+	return cs.Save()
+}
+
+// GetPendingKeyUUID returns the key UUID of an unfinished key rotation, if any
+func (cs *ConfigService) GetPendingKeyUUID() string {
+	return cs.Config.PendingKeyUUID
+}
+
+// SetPendingKeyUUID records the key UUID a rotation is moving to
+func (cs *ConfigService) SetPendingKeyUUID(keyUUID string) error {
+	cs.Config.PendingKeyUUID = keyUUID
+	return cs.Save()
+}
+
 func (cs *ConfigService) GetTheme() string {
 	return cs.Config.Theme
 }
@@ -1,48 +1,176 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 )
 
-// Encrypt encrypts the plaintext using the provided key.
+// Ciphertexts start with a small header so algorithms and keys can be mixed in one vault:
+//
+//	magic (2) | format version (1) | cipher id (1) | key id length (1) | key id | nonce | sealed data
+//
+// Ciphertexts written before the header existed are bare AES-GCM nonce|sealed data.
+var headerMagic = [2]byte{'S', 'P'}
+
+const (
+	headerVersion  = 1
+	headerFixedLen = 5
+	maxKeyIDLen    = 255
+)
+
+// Header describes how a ciphertext was produced
+type Header struct {
+	Version   int
+	Algorithm string
+	KeyID     string
+	Legacy    bool // Written before headers were introduced, always AES-GCM
+}
+
+// EncryptOptions selects the algorithm and metadata recorded in the ciphertext header
+type EncryptOptions struct {
+	Algorithm      string // Registered cipher name, defaults to DefaultAlgorithm
+	KeyID          string // Identifier of the key, recorded for key lookup and rotation
+	AdditionalData []byte // Context the ciphertext is bound to, must be supplied again to decrypt
+}
+
+// Encrypt encrypts the plaintext using the provided key and the default algorithm.
 func Encrypt(plaintext []byte, key []byte) (string, error) {
-	block, err := aes.NewCipher(key)
+	return EncryptWithOptions(plaintext, key, EncryptOptions{})
+}
+
+// EncryptWithOptions encrypts the plaintext with the algorithm selected in opts.
+func EncryptWithOptions(plaintext []byte, key []byte, opts EncryptOptions) (string, error) {
+	c, err := LookupCipher(opts.Algorithm)
 	if err != nil {
 		return "", err
 	}
+	if len(opts.KeyID) > maxKeyIDLen {
+		return "", fmt.Errorf("key id too long")
+	}
 
-	gcm, err := cipher.NewGCM(block)
+	aead, err := c.New(key)
 	if err != nil {
 		return "", err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
+	header := make([]byte, 0, headerFixedLen+len(opts.KeyID))
+	header = append(header, headerMagic[0], headerMagic[1], headerVersion, c.ID, byte(len(opts.KeyID)))
+	header = append(header, opts.KeyID...)
+
+	nonce := make([]byte, aead.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return "", err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	// The header is authenticated as additional data so it cannot be altered
+	out := append(header, nonce...)
+	out = aead.Seal(out, nonce, plaintext, additionalData(header, opts.AdditionalData))
+	return base64.StdEncoding.EncodeToString(out), nil
 }
 
-// Decrypt decrypts the ciphertext using the provided key.
+// Decrypt decrypts the ciphertext using the provided key, dispatching on the algorithm in its header.
 func Decrypt(ciphertext string, key []byte) ([]byte, error) {
+	return DecryptWithAD(ciphertext, key, nil)
+}
+
+// DecryptWithAD decrypts a ciphertext that was sealed with the given additional data.
+// It fails if ad differs from the data used when encrypting.
+func DecryptWithAD(ciphertext string, key []byte, ad []byte) ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
+	header, headerLen, err := parseHeader(data)
+	if err != nil {
+		return decryptLegacy(data, key, ad)
+	}
+
+	plaintext, err := openWithHeader(data, headerLen, header, key, ad)
+	if err != nil {
+		// A legacy nonce can start with the magic bytes by chance
+		if legacyPlaintext, legacyErr := decryptLegacy(data, key, ad); legacyErr == nil {
+			return legacyPlaintext, nil
+		}
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// ParseHeader returns the header of a ciphertext without decrypting it.
+func ParseHeader(ciphertext string) (Header, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return Header{}, err
+	}
+	header, _, err := parseHeader(data)
+	if err != nil {
+		return Header{Algorithm: AlgorithmAES256GCM, Legacy: true}, nil
+	}
+	return header, nil
+}
+
+// parseHeader decodes the versioned header, returning its length
+func parseHeader(data []byte) (Header, int, error) {
+	if len(data) < headerFixedLen || data[0] != headerMagic[0] || data[1] != headerMagic[1] {
+		return Header{}, 0, errors.New("missing ciphertext header")
+	}
+	if data[2] != headerVersion {
+		return Header{}, 0, fmt.Errorf("unsupported ciphertext version %d", data[2])
+	}
+
+	c, err := lookupCipherByID(data[3])
+	if err != nil {
+		return Header{}, 0, err
+	}
+
+	headerLen := headerFixedLen + int(data[4])
+	if len(data) < headerLen {
+		return Header{}, 0, errors.New("ciphertext header truncated")
+	}
+
+	return Header{
+		Version:   int(data[2]),
+		Algorithm: c.Name,
+		KeyID:     string(data[headerFixedLen:headerLen]),
+	}, headerLen, nil
+}
+
+func openWithHeader(data []byte, headerLen int, header Header, key []byte, ad []byte) ([]byte, error) {
+	c, err := LookupCipher(header.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.New(key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	body := data[headerLen:]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce := body[:aead.NonceSize()]
+	return aead.Open(nil, nonce, body[aead.NonceSize():], additionalData(data[:headerLen], ad))
+}
+
+// additionalData authenticates the header followed by the caller supplied context
+func additionalData(header []byte, ad []byte) []byte {
+	if len(ad) == 0 {
+		return header
+	}
+	out := make([]byte, 0, len(header)+len(ad))
+	out = append(out, header...)
+	return append(out, ad...)
+}
+
+// decryptLegacy opens a header-less AES-GCM ciphertext
+func decryptLegacy(data []byte, key []byte, ad []byte) ([]byte, error) {
+	gcm, err := newAESGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +181,7 @@ func Decrypt(ciphertext string, key []byte) ([]byte, error) {
 
 	nonce := data[:gcm.NonceSize()]
 	ciphertextBytes := data[gcm.NonceSize():]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, ad)
 	if err != nil {
 		return nil, err
 	}
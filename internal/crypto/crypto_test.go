@@ -1,6 +1,9 @@
 package crypto_test
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
 	"go-password-manager/internal/crypto"
 	"go-password-manager/tests/helpers"
 	"go-password-manager/tests/testdata"
@@ -92,3 +95,88 @@ func TestEncryptDecryptSpecialCharacters(t *testing.T) {
 		tc.Assert.Equal(string(decrypted), plaintext, "Expected decrypted text to match original")
 	})
 }
+
+func TestEncryptDecryptAllAlgorithms(t *testing.T) {
+	algorithms := []string{crypto.AlgorithmAES256GCM, crypto.AlgorithmChaCha20Poly1305, crypto.AlgorithmXChaCha20Poly1305}
+	for _, algorithm := range algorithms {
+		helpers.WithUnitTestCase(t, algorithm, func(tc *helpers.UnitTestCase) {
+			plaintext := testdata.TestSecrets.Simple.Value
+			key := []byte(testdata.TestEncryptionKey)
+
+			encrypted, err := crypto.EncryptWithOptions([]byte(plaintext), key, crypto.EncryptOptions{Algorithm: algorithm, KeyID: "key-1"})
+			tc.Require.NoError(err, "Expected no error encrypting")
+
+			header, err := crypto.ParseHeader(encrypted)
+			tc.Require.NoError(err, "Expected no error parsing header")
+			tc.Assert.Equal(algorithm, header.Algorithm, "Header should record the algorithm")
+			tc.Assert.Equal("key-1", header.KeyID, "Header should record the key id")
+			tc.Assert.False(header.Legacy)
+
+			decrypted, err := crypto.Decrypt(encrypted, key)
+			tc.Require.NoError(err, "Expected no error decrypting")
+			tc.Assert.Equal(plaintext, string(decrypted), "Decrypted text should match original")
+		})
+	}
+}
+
+func TestEncryptWithUnknownAlgorithm(t *testing.T) {
+	helpers.WithUnitTestCase(t, "TestEncryptWithUnknownAlgorithm", func(tc *helpers.UnitTestCase) {
+		_, err := crypto.EncryptWithOptions([]byte("data"), []byte(testdata.TestEncryptionKey), crypto.EncryptOptions{Algorithm: "ROT13"})
+		tc.Assert.Error(err, "Expected error for unsupported algorithm")
+	})
+}
+
+func TestDecryptLegacyCiphertext(t *testing.T) {
+	helpers.WithUnitTestCase(t, "TestDecryptLegacyCiphertext", func(tc *helpers.UnitTestCase) {
+		plaintext := testdata.TestSecrets.Simple.Value
+		key := []byte(testdata.TestEncryptionKey)
+
+		// Header-less AES-GCM as written by earlier versions
+		block, err := aes.NewCipher(key)
+		tc.Require.NoError(err)
+		gcm, err := cipher.NewGCM(block)
+		tc.Require.NoError(err)
+		nonce := make([]byte, gcm.NonceSize())
+		legacy := base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+
+		header, err := crypto.ParseHeader(legacy)
+		tc.Require.NoError(err, "Expected no error parsing legacy header")
+		tc.Assert.True(header.Legacy, "Ciphertext should be reported as legacy")
+		tc.Assert.Equal(crypto.AlgorithmAES256GCM, header.Algorithm)
+
+		decrypted, err := crypto.Decrypt(legacy, key)
+		tc.Require.NoError(err, "Expected legacy ciphertext to decrypt")
+		tc.Assert.Equal(plaintext, string(decrypted), "Decrypted text should match original")
+	})
+}
+
+func TestDecryptTamperedHeader(t *testing.T) {
+	helpers.WithUnitTestCase(t, "TestDecryptTamperedHeader", func(tc *helpers.UnitTestCase) {
+		key := []byte(testdata.TestEncryptionKey)
+		encrypted, err := crypto.EncryptWithOptions([]byte("data"), key, crypto.EncryptOptions{KeyID: "key-1"})
+		tc.Require.NoError(err, "Expected no error encrypting")
+
+		raw, err := base64.StdEncoding.DecodeString(encrypted)
+		tc.Require.NoError(err)
+		raw[len(raw)-len("data")-16-12-1] ^= 0x01 // last byte of the key id
+		_, err = crypto.Decrypt(base64.StdEncoding.EncodeToString(raw), key)
+		tc.Assert.Error(err, "Expected error when the header was modified")
+	})
+}
+
+func TestDecryptWithMismatchedAssociatedData(t *testing.T) {
+	helpers.WithUnitTestCase(t, "TestDecryptWithMismatchedAssociatedData", func(tc *helpers.UnitTestCase) {
+		key := []byte(testdata.TestEncryptionKey)
+		encrypted, err := crypto.EncryptWithOptions([]byte("data"), key, crypto.EncryptOptions{AdditionalData: []byte("secret-a")})
+		tc.Require.NoError(err, "Expected no error encrypting")
+
+		decrypted, err := crypto.DecryptWithAD(encrypted, key, []byte("secret-a"))
+		tc.Require.NoError(err, "Expected no error decrypting with matching associated data")
+		tc.Assert.Equal("data", string(decrypted))
+
+		_, err = crypto.DecryptWithAD(encrypted, key, []byte("secret-b"))
+		tc.Assert.Error(err, "Expected error with different associated data")
+		_, err = crypto.Decrypt(encrypted, key)
+		tc.Assert.Error(err, "Expected error without associated data")
+	})
+}
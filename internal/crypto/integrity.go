@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"go-password-manager/internal/fsutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// generationFileName holds the vault's write counter, next to the key files
+const generationFileName = ".generation"
+
+// macKeyLabel separates the integrity key from every other use of the vault key
+var macKeyLabel = []byte("go-password-manager/secrets-file-mac")
+
+// MAC returns an HMAC-SHA256 of data keyed by a subkey of the active vault key
+func (s *CryptoService) MAC(data []byte) ([]byte, error) {
+	if len(s.key) == 0 {
+		return nil, ErrLocked
+	}
+
+	derive := hmac.New(sha256.New, s.key)
+	derive.Write(macKeyLabel)
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Generation returns the last secrets file generation recorded for this vault, or 0 if none was
+func (s *CryptoService) Generation() (uint64, error) {
+	path, err := s.generationFilePath()
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// SetGeneration records the generation of the secrets file that was just written
+func (s *CryptoService) SetGeneration(generation uint64) error {
+	path, err := s.generationFilePath()
+	if err != nil {
+		return err
+	}
+
+	// A counter that goes backwards after a crash would hide a rollback or reject a valid vault
+	return fsutil.WriteFileAtomic(path, []byte(strconv.FormatUint(generation, 10)), 0600)
+}
+
+func (s *CryptoService) generationFilePath() (string, error) {
+	keyPath, err := keyFilePath(resolveKeyUUID(s.configProvider))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(keyPath), generationFileName), nil
+}
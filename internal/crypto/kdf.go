@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	buildconfig "go-password-manager/internal/config/buildconfig"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Supported key derivation functions
+const (
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+const (
+	kekSize  = 32
+	saltSize = 16
+)
+
+// KDFParams describes how the key-encryption key was derived from the master password.
+// It is stored in the key file header so costs can be tuned without breaking existing vaults.
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      string `json:"salt"`
+	Time      uint32 `json:"time,omitempty"`
+	MemoryKiB uint32 `json:"memoryKiB,omitempty"`
+	Threads   uint8  `json:"threads,omitempty"`
+	N         int    `json:"n,omitempty"`
+	R         int    `json:"r,omitempty"`
+	P         int    `json:"p,omitempty"`
+}
+
+// newKDFParams builds fresh KDF parameters with a random salt from the build configuration
+func newKDFParams(cfg buildconfig.KDFConfig) (KDFParams, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFParams{}, err
+	}
+
+	params := KDFParams{
+		Algorithm: cfg.Algorithm,
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+	}
+	if params.Algorithm == "" {
+		params.Algorithm = KDFArgon2id
+	}
+
+	switch params.Algorithm {
+	case KDFArgon2id:
+		params.Time = cfg.Time
+		params.MemoryKiB = cfg.MemoryKiB
+		params.Threads = cfg.Threads
+		if params.Time == 0 {
+			params.Time = 3
+		}
+		if params.MemoryKiB == 0 {
+			params.MemoryKiB = 64 * 1024
+		}
+		if params.Threads == 0 {
+			params.Threads = 4
+		}
+	case KDFScrypt:
+		params.N = cfg.ScryptN
+		params.R = cfg.ScryptR
+		params.P = cfg.ScryptP
+		if params.N == 0 {
+			params.N = 32768
+		}
+		if params.R == 0 {
+			params.R = 8
+		}
+		if params.P == 0 {
+			params.P = 1
+		}
+	default:
+		return KDFParams{}, fmt.Errorf("unsupported key derivation function: %s", params.Algorithm)
+	}
+
+	return params, nil
+}
+
+// deriveKEK stretches the master password into a key-encryption key
+func deriveKEK(masterPassword string, params KDFParams) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KDF salt: %w", err)
+	}
+
+	switch params.Algorithm {
+	case KDFArgon2id:
+		if params.Time == 0 || params.MemoryKiB == 0 || params.Threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return argon2.IDKey([]byte(masterPassword), salt, params.Time, params.MemoryKiB, params.Threads, kekSize), nil
+	case KDFScrypt:
+		return scrypt.Key([]byte(masterPassword), salt, params.N, params.R, params.P, kekSize)
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function: %s", params.Algorithm)
+	}
+}
@@ -1,14 +1,42 @@
 package crypto
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	buildconfig "go-password-manager/internal/config/buildconfig"
+	"go-password-manager/internal/fsutil"
 	"go-password-manager/internal/logger"
+	"io"
 	"os"
 	"path/filepath"
 )
 
+// keyFileVersion is the current on-disk format of wrapped key files
+const keyFileVersion = 1
+
+var (
+	// ErrWrongPassword is returned when the master password cannot unwrap the vault key
+	ErrWrongPassword = errors.New("incorrect master password")
+	// ErrEmptyPassword is returned when an empty master password is supplied
+	ErrEmptyPassword = errors.New("master password cannot be empty")
+	// ErrLocked is returned when the vault key is used before the vault has been unlocked
+	ErrLocked = errors.New("vault is locked")
+	// ErrKeyMissing is returned instead of creating a new key for a vault that was already saved
+	ErrKeyMissing = errors.New("the vault exists but its key file is missing")
+)
+
+// keyFile is the on-disk representation of a data key wrapped by the master password
+type keyFile struct {
+	Version    int       `json:"version"`
+	KDF        KDFParams `json:"kdf"`
+	WrappedKey string    `json:"wrappedKey"`
+}
+
 func keyFilePath(keyUUID string) (string, error) {
 	buildCfg, err := buildconfig.Load()
 	if err != nil {
@@ -45,13 +73,8 @@ func keyFilePath(keyUUID string) (string, error) {
 	return filepath.Join(appConfigDir, "."+keyUUID), nil // Obfuscated file name
 }
 
-// LoadOrCreateKey loads an existing encryption key or creates a new one
-func LoadOrCreateKey(configProvider ConfigProvider) ([]byte, error) {
-	buildCfg, err := buildconfig.Load()
-	if err != nil {
-		return nil, err
-	}
-
+// resolveKeyUUID returns the configured key UUID or the default one
+func resolveKeyUUID(configProvider ConfigProvider) string {
 	// Generate a default key UUID if config service is not available
 	keyUUID := "default-key"
 
@@ -59,44 +82,220 @@ func LoadOrCreateKey(configProvider ConfigProvider) ([]byte, error) {
 	if configProvider != nil && configProvider.GetKeyUUID() != "" {
 		keyUUID = configProvider.GetKeyUUID()
 	}
+	return keyUUID
+}
+
+// KeyExists reports whether a key file has already been created for the configured key UUID
+func KeyExists(configProvider ConfigProvider) (bool, error) {
+	path, err := keyFilePath(resolveKeyUUID(configProvider))
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// LoadOrCreateKey unwraps the existing encryption key with the master password or creates a new one.
+// Legacy key files holding the raw key are re-written in the wrapped format on first unlock.
+func LoadOrCreateKey(configProvider ConfigProvider, masterPassword string) ([]byte, error) {
+	key, _, err := unlockKey(resolveKeyUUID(configProvider), masterPassword)
+	return key, err
+}
+
+// unlockKey is LoadOrCreateKey for an explicit key UUID, also returning the key-encryption
+// context so further keys can be wrapped without asking for the password again.
+func unlockKey(keyUUID, masterPassword string) ([]byte, *keyWrapper, error) {
+	if masterPassword == "" {
+		return nil, nil, ErrEmptyPassword
+	}
+
+	buildCfg, err := buildconfig.Load()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	path, err := keyFilePath(keyUUID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Check if key file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Create new key
-		keySize := buildCfg.Security.Encryption.KeySize
-		if keySize == 0 {
-			keySize = 32 // Default to AES-256
+		key, err := newDataKey(buildCfg)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		key := make([]byte, keySize)
-		_, err := rand.Read(key)
+		wrapper, err := newKeyWrapper(masterPassword, buildCfg.Security.KDF)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-
-		if err := os.WriteFile(path, key, 0600); err != nil {
-			return nil, err
+		if err := wrapper.writeKey(path, keyUUID, key); err != nil {
+			return nil, nil, err
 		}
 
-		logger.Debug("creating new encryption key: ", string(key))
-		return key, nil
+		logger.Debug("Created new wrapped encryption key")
+		return key, wrapper, nil
 	}
 
 	// Load existing key
-	key, err := os.ReadFile(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, nil, errors.New("encryption key is empty")
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil || kf.Version == 0 {
+		// Legacy raw key file, wrap it with the master password
+		logger.Info("Migrating raw encryption key to wrapped format")
+		wrapper, err := newKeyWrapper(masterPassword, buildCfg.Security.KDF)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := wrapper.writeKey(path, keyUUID, data); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate encryption key: %w", err)
+		}
+		return data, wrapper, nil
+	}
+
+	if kf.Version > keyFileVersion {
+		return nil, nil, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+
+	kek, err := deriveKEK(masterPassword, kf.KDF)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := unwrapKey(kf.WrappedKey, kek, keyUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &keyWrapper{kek: kek, params: kf.KDF}, nil
+}
+
+// newDataKey generates a random data key of the configured size
+func newDataKey(buildCfg *buildconfig.Config) ([]byte, error) {
+	keySize := buildCfg.Security.Encryption.KeySize
+	if keySize == 0 {
+		keySize = 32 // Default to AES-256
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// keyWrapper holds a derived key-encryption key together with the parameters used to derive it
+type keyWrapper struct {
+	kek    []byte
+	params KDFParams
+}
+
+// newKeyWrapper derives a key-encryption key from the master password with fresh parameters
+func newKeyWrapper(masterPassword string, kdfCfg buildconfig.KDFConfig) (*keyWrapper, error) {
+	params, err := newKDFParams(kdfCfg)
 	if err != nil {
 		return nil, err
 	}
+	kek, err := deriveKEK(masterPassword, params)
+	if err != nil {
+		return nil, err
+	}
+	return &keyWrapper{kek: kek, params: params}, nil
+}
 
-	// Validate key size
-	if len(key) == 0 {
-		return nil, errors.New("encryption key is empty")
+// readKey unwraps the key stored at path. The key must have been wrapped with the same parameters.
+func (w *keyWrapper) readKey(path, keyUUID string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+	if kf.KDF != w.params {
+		return nil, errors.New("key file was wrapped with different KDF parameters")
+	}
+	return unwrapKey(kf.WrappedKey, w.kek, keyUUID)
+}
+
+// writeKey wraps key and writes it to path
+func (w *keyWrapper) writeKey(path, keyUUID string, key []byte) error {
+	wrapped, err := wrapKey(key, w.kek, keyUUID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(keyFile{
+		Version:    keyFileVersion,
+		KDF:        w.params,
+		WrappedKey: wrapped,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The vault cannot be opened without this file, so a crash must never leave it torn
+	return fsutil.WriteFileAtomic(path, data, 0600)
+}
+
+// wrapKey seals key with kek, binding it to the key UUID
+func wrapKey(key, kek []byte, keyUUID string) (string, error) {
+	gcm, err := newKeyWrapGCM(kek)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, key, []byte(keyUUID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unwrapKey opens a wrapped key, returning ErrWrongPassword if kek does not match
+func unwrapKey(wrapped string, kek []byte, keyUUID string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+
+	gcm, err := newKeyWrapGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+
+	key, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(keyUUID))
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
 	return key, nil
 }
+
+func newKeyWrapGCM(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,34 +1,267 @@
 package crypto
 
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	buildconfig "go-password-manager/internal/config/buildconfig"
+	"os"
+)
+
 // CryptoService handles encryption and decryption operations.
+// The service starts locked and must be unlocked with the master password before use.
 type CryptoService struct {
-	key []byte
+	configProvider ConfigProvider
+	algorithm      string
+	key            []byte
+	keyUUID        string
+	keyring        map[string][]byte // every key unwrapped in this session, by UUID
+	wrapper        *keyWrapper       // retained after unlock so rotated keys can be wrapped
+	vaultExists    func() (bool, error)
 }
 
 type ConfigProvider interface {
 	GetKeyUUID() string
 }
 
-// NewCryptoService creates a new CryptoService.
-func NewCryptoService(configProvider ConfigProvider) (*CryptoService, error) {
-	key, err := LoadOrCreateKey(configProvider)
+// ConfigSaver is implemented by config providers that persist the key UUID. The config is saved
+// as soon as a key is created, so the key is found again on the next start.
+type ConfigSaver interface {
+	Save() error
+}
+
+// Option configures a CryptoService
+type Option func(*CryptoService)
+
+// WithVaultCheck makes Unlock refuse to create a new vault key while exists reports a saved vault,
+// which could never be opened with it
+func WithVaultCheck(exists func() (bool, error)) Option {
+	return func(s *CryptoService) {
+		s.vaultExists = exists
+	}
+}
+
+// NewCryptoService creates a new, locked CryptoService.
+func NewCryptoService(configProvider ConfigProvider, opts ...Option) (*CryptoService, error) {
+	buildCfg, err := buildconfig.Load()
 	if err != nil {
 		return nil, err
 	}
-	return &CryptoService{key: key}, nil
+
+	// Validate configuration up front so it surfaces before unlock
+	algorithm := buildCfg.Security.Encryption.Algorithm
+	if _, err := LookupCipher(algorithm); err != nil {
+		return nil, err
+	}
+	if _, err := keyFilePath(resolveKeyUUID(configProvider)); err != nil {
+		return nil, err
+	}
+
+	s := &CryptoService{
+		configProvider: configProvider,
+		algorithm:      algorithm,
+		keyring:        map[string][]byte{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// IsInitialized reports whether a master password has already been set for this vault.
+func (s *CryptoService) IsInitialized() (bool, error) {
+	return KeyExists(s.configProvider)
 }
 
-// GetKey returns the encryption key.
+// Unlock derives the key-encryption key from the master password and unwraps the vault key.
+// On a new vault the password becomes the master password. Returns ErrWrongPassword on mismatch.
+func (s *CryptoService) Unlock(masterPassword string) error {
+	initialized, err := s.IsInitialized()
+	if err != nil {
+		return err
+	}
+	if !initialized && s.vaultExists != nil {
+		exists, err := s.vaultExists()
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrKeyMissing
+		}
+	}
+
+	keyUUID := resolveKeyUUID(s.configProvider)
+	key, wrapper, err := unlockKey(keyUUID, masterPassword)
+	if err != nil {
+		return err
+	}
+	if !initialized {
+		if err := s.saveConfig(); err != nil {
+			return err
+		}
+	}
+	s.key = key
+	s.keyUUID = keyUUID
+	s.keyring[keyUUID] = key
+	s.wrapper = wrapper
+	return nil
+}
+
+// IsUnlocked reports whether the vault key is available.
+func (s *CryptoService) IsUnlocked() bool {
+	return len(s.key) > 0
+}
+
+// Lock wipes the vault key from memory.
+func (s *CryptoService) Lock() {
+	for i := range s.key {
+		s.key[i] = 0
+	}
+	for _, key := range s.keyring {
+		for i := range key {
+			key[i] = 0
+		}
+	}
+	s.key = nil
+	s.keyring = map[string][]byte{}
+	s.wrapper = nil
+}
+
+// GetKey returns the encryption key, or nil while the service is locked.
 func (s *CryptoService) GetKey() []byte {
 	return s.key
 }
 
+// ActiveKeyID returns the UUID of the key currently used for encryption.
+func (s *CryptoService) ActiveKeyID() string {
+	return s.keyUUID
+}
+
+// GenerateKey creates a new random key, wraps it with the master password and stores it under keyUUID.
+func (s *CryptoService) GenerateKey(keyUUID string) ([]byte, error) {
+	if s.wrapper == nil {
+		return nil, ErrLocked
+	}
+	buildCfg, err := buildconfig.Load()
+	if err != nil {
+		return nil, err
+	}
+	path, err := keyFilePath(keyUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newDataKey(buildCfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.wrapper.writeKey(path, keyUUID, key); err != nil {
+		return nil, err
+	}
+	if err := s.saveConfig(); err != nil {
+		return nil, err
+	}
+	s.keyring[keyUUID] = key
+	return key, nil
+}
+
+// saveConfig persists the config after a key was created, see ConfigSaver
+func (s *CryptoService) saveConfig() error {
+	saver, ok := s.configProvider.(ConfigSaver)
+	if !ok {
+		return nil
+	}
+	if err := saver.Save(); err != nil {
+		return fmt.Errorf("failed to save the key configuration: %w", err)
+	}
+	return nil
+}
+
+// LoadKey unwraps a key previously created with GenerateKey.
+func (s *CryptoService) LoadKey(keyUUID string) ([]byte, error) {
+	if s.wrapper == nil {
+		return nil, ErrLocked
+	}
+	path, err := keyFilePath(keyUUID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.wrapper.readKey(path, keyUUID)
+	if err != nil {
+		return nil, err
+	}
+	s.keyring[keyUUID] = key
+	return key, nil
+}
+
+// ActivateKey makes key the active encryption key.
+func (s *CryptoService) ActivateKey(keyUUID string, key []byte) {
+	s.key = key
+	s.keyUUID = keyUUID
+	s.keyring[keyUUID] = key
+}
+
+// RetireKey deletes the key file for keyUUID. It must no longer be referenced by any data.
+func (s *CryptoService) RetireKey(keyUUID string) error {
+	if keyUUID == s.keyUUID {
+		return errors.New("cannot retire the active key")
+	}
+	path, err := keyFilePath(keyUUID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.keyring, keyUUID)
+	return nil
+}
+
+// Algorithm returns the configured cipher used for new ciphertexts.
+func (s *CryptoService) Algorithm() string {
+	return s.algorithm
+}
+
+// keyIDFor finds the UUID of key in the keyring so it can be recorded in ciphertext headers.
+// Keys are matched by their fingerprints in constant time, raw keys are never compared.
+func (s *CryptoService) keyIDFor(key []byte) string {
+	fingerprint := keyFingerprint(key)
+	for keyUUID, candidate := range s.keyring {
+		if subtle.ConstantTimeCompare(keyFingerprint(candidate), fingerprint) == 1 {
+			return keyUUID
+		}
+	}
+	return ""
+}
+
+// keyFingerprintLabel separates key fingerprints from every other use of a key
+var keyFingerprintLabel = []byte("go-password-manager/key-fingerprint")
+
+// keyFingerprint derives an identifier of key that reveals nothing about the key itself
+func keyFingerprint(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(keyFingerprintLabel)
+	return mac.Sum(nil)
+}
+
 // Encrypt implements the service.CryptoService interface
 func (s *CryptoService) Encrypt(data, key []byte) ([]byte, error) {
-	// This seems incorrect, the free function Encrypt returns a string.
-	// For now, I will just call it and convert.
-	// We can refactor this later.
-	encryptedString, err := Encrypt(data, key)
+	return s.EncryptWithAD(data, key, nil)
+}
+
+// EncryptWithAD encrypts data bound to the associated data ad
+func (s *CryptoService) EncryptWithAD(data, key, ad []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrLocked
+	}
+	// The free function returns base64 text, which is what gets persisted
+	encryptedString, err := EncryptWithOptions(data, key, EncryptOptions{
+		Algorithm:      s.algorithm,
+		KeyID:          s.keyIDFor(key),
+		AdditionalData: ad,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -37,5 +270,13 @@ func (s *CryptoService) Encrypt(data, key []byte) ([]byte, error) {
 
 // Decrypt implements the service.CryptoService interface
 func (s *CryptoService) Decrypt(data, key []byte) ([]byte, error) {
-	return Decrypt(string(data), key)
+	return s.DecryptWithAD(data, key, nil)
+}
+
+// DecryptWithAD decrypts data that was sealed with the associated data ad
+func (s *CryptoService) DecryptWithAD(data, key, ad []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrLocked
+	}
+	return DecryptWithAD(string(data), key, ad)
 }
@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,3 +34,209 @@ func TestNewCryptoServiceWithMockConfig(t *testing.T) {
 	assert.NoError(t, err, "NewCryptoService should not return an error with a valid mock provider")
 	assert.NotNil(t, cryptoService, "NewCryptoService should return a non-nil service instance")
 }
+
+// useTestKeyDir points key storage at a temporary directory for the duration of the test.
+func useTestKeyDir(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("GO_PASSWORD_MANAGER_ENV", "test")
+	t.Setenv("TEST_DATA_DIR", dir)
+	return dir
+}
+
+// TestUnlockCreatesAndReopensWrappedKey verifies that the first unlock sets the master password
+// and that the same password unwraps the same key afterwards.
+func TestUnlockCreatesAndReopensWrappedKey(t *testing.T) {
+	useTestKeyDir(t)
+	provider := &mockConfigProvider{keyUUID: "unlock-test"}
+
+	svc, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.False(t, svc.IsUnlocked(), "service should start locked")
+
+	initialized, err := svc.IsInitialized()
+	assert.NoError(t, err)
+	assert.False(t, initialized, "no key file should exist yet")
+
+	assert.NoError(t, svc.Unlock("correct horse battery staple"))
+	assert.True(t, svc.IsUnlocked())
+	key := append([]byte(nil), svc.GetKey()...)
+
+	reopened, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Unlock("correct horse battery staple"))
+	assert.Equal(t, key, reopened.GetKey(), "unlocking again should yield the same key")
+}
+
+// savingConfigProvider records how often the config was saved.
+type savingConfigProvider struct {
+	mockConfigProvider
+	saves int
+}
+
+func (m *savingConfigProvider) Save() error {
+	m.saves++
+	return nil
+}
+
+// TestUnlockSavesTheConfigWithANewKey verifies that the key UUID is persisted as soon as its key
+// file is created, and only then.
+func TestUnlockSavesTheConfigWithANewKey(t *testing.T) {
+	useTestKeyDir(t)
+	provider := &savingConfigProvider{mockConfigProvider: mockConfigProvider{keyUUID: "saved-key"}}
+
+	svc, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Unlock("password"))
+	assert.Equal(t, 1, provider.saves, "creating the key should save the config")
+
+	reopened, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Unlock("password"))
+	assert.Equal(t, 1, provider.saves, "unlocking an existing key should not save the config")
+
+	_, err = reopened.GenerateKey("saved-key-2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, provider.saves, "generating a key should save the config")
+}
+
+// TestUnlockRefusesANewKeyForASavedVault verifies that a vault whose key file is missing is not
+// given a new key it could never be opened with.
+func TestUnlockRefusesANewKeyForASavedVault(t *testing.T) {
+	useTestKeyDir(t)
+	provider := &savingConfigProvider{mockConfigProvider: mockConfigProvider{keyUUID: "lost-key"}}
+
+	svc, err := NewCryptoService(provider, WithVaultCheck(func() (bool, error) { return true, nil }))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, svc.Unlock("password"), ErrKeyMissing)
+	assert.False(t, svc.IsUnlocked())
+	initialized, err := svc.IsInitialized()
+	assert.NoError(t, err)
+	assert.False(t, initialized, "no key file should be created")
+	assert.Zero(t, provider.saves)
+}
+
+// TestUnlockWithWrongPassword verifies that a wrong master password fails cleanly.
+func TestUnlockWithWrongPassword(t *testing.T) {
+	useTestKeyDir(t)
+	provider := &mockConfigProvider{keyUUID: "wrong-password-test"}
+
+	svc, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Unlock("right"))
+
+	other, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, other.Unlock("wrong"), ErrWrongPassword)
+	assert.False(t, other.IsUnlocked())
+
+	_, err = other.Encrypt([]byte("data"), other.GetKey())
+	assert.ErrorIs(t, err, ErrLocked, "encrypting while locked should fail")
+}
+
+// TestUnlockMigratesRawKeyFile verifies that legacy raw key files are wrapped on first unlock.
+func TestUnlockMigratesRawKeyFile(t *testing.T) {
+	dir := useTestKeyDir(t)
+	provider := &mockConfigProvider{keyUUID: "legacy-key"}
+
+	rawKey := []byte("12345678901234567890123456789012")
+	keyPath := filepath.Join(dir, "keys", ".legacy-key")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(keyPath), 0700))
+	assert.NoError(t, os.WriteFile(keyPath, rawKey, 0600))
+
+	svc, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Unlock("migrate-me"))
+	assert.Equal(t, rawKey, svc.GetKey(), "migrated key should be unchanged")
+
+	onDisk, err := os.ReadFile(keyPath)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(onDisk), string(rawKey), "raw key should no longer be stored on disk")
+
+	reopened, err := NewCryptoService(provider)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, reopened.Unlock("not-the-password"), ErrWrongPassword)
+	assert.NoError(t, reopened.Unlock("migrate-me"))
+	assert.Equal(t, rawKey, reopened.GetKey())
+}
+
+// TestGenerateLoadAndRetireKey verifies the key management operations used by key rotation.
+func TestGenerateLoadAndRetireKey(t *testing.T) {
+	useTestKeyDir(t)
+	svc, err := NewCryptoService(&mockConfigProvider{keyUUID: "rotation-old"})
+	assert.NoError(t, err)
+
+	_, err = svc.GenerateKey("rotation-new")
+	assert.ErrorIs(t, err, ErrLocked, "generating keys requires an unlocked service")
+
+	assert.NoError(t, svc.Unlock("rotate"))
+	oldKey := svc.GetKey()
+
+	newKey, err := svc.GenerateKey("rotation-new")
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKey, newKey)
+
+	loaded, err := svc.LoadKey("rotation-new")
+	assert.NoError(t, err)
+	assert.Equal(t, newKey, loaded)
+
+	svc.ActivateKey("rotation-new", newKey)
+	assert.Equal(t, "rotation-new", svc.ActiveKeyID())
+	assert.Error(t, svc.RetireKey("rotation-new"), "the active key cannot be retired")
+	assert.NoError(t, svc.RetireKey("rotation-old"))
+
+	exists, err := KeyExists(&mockConfigProvider{keyUUID: "rotation-old"})
+	assert.NoError(t, err)
+	assert.False(t, exists, "retired key file should be removed")
+
+	// The new key is wrapped with the same master password
+	reopened, err := NewCryptoService(&mockConfigProvider{keyUUID: "rotation-new"})
+	assert.NoError(t, err)
+	assert.NoError(t, reopened.Unlock("rotate"))
+	assert.Equal(t, newKey, reopened.GetKey())
+}
+
+// TestGenerationAndMAC verifies the integrity primitives used to sign the secrets file.
+func TestGenerationAndMAC(t *testing.T) {
+	useTestKeyDir(t)
+	svc, err := NewCryptoService(&mockConfigProvider{keyUUID: "integrity"})
+	assert.NoError(t, err)
+
+	_, err = svc.MAC([]byte("data"))
+	assert.ErrorIs(t, err, ErrLocked, "a MAC requires an unlocked service")
+
+	generation, err := svc.Generation()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), generation, "a new vault has no generation")
+
+	assert.NoError(t, svc.SetGeneration(7))
+	generation, err = svc.Generation()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), generation)
+
+	assert.NoError(t, svc.Unlock("integrity"))
+	mac, err := svc.MAC([]byte("data"))
+	assert.NoError(t, err)
+	again, err := svc.MAC([]byte("data"))
+	assert.NoError(t, err)
+	assert.Equal(t, mac, again, "the MAC should be deterministic")
+	other, err := svc.MAC([]byte("other"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, mac, other)
+}
+
+// TestKeyIDFor verifies that ciphertexts record the UUID of a keyring key and nothing for other keys.
+func TestKeyIDFor(t *testing.T) {
+	useTestKeyDir(t)
+	svc, err := NewCryptoService(&mockConfigProvider{keyUUID: "key-id"})
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Unlock("key-id"))
+
+	encrypted, err := svc.Encrypt([]byte("data"), svc.GetKey())
+	assert.NoError(t, err)
+	header, err := ParseHeader(string(encrypted))
+	assert.NoError(t, err)
+	assert.Equal(t, "key-id", header.KeyID)
+
+	dataKey := make([]byte, len(svc.GetKey()))
+	assert.Equal(t, "", svc.keyIDFor(dataKey), "keys outside the keyring have no UUID")
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// Backup describes a snapshot of the secrets file taken before a write
+type Backup struct {
+	ID        string
+	CreatedAt time.Time
+	Size      int64
+}
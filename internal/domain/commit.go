@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// Commit describes one recorded change of a vault kept under version control
+type Commit struct {
+	ID        string
+	Author    string
+	CreatedAt time.Time
+	Message   string
+}
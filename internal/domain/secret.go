@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"maps"
+	"slices"
+)
+
 // SecretType represents the type of secret being stored
 type SecretType string
 
@@ -11,24 +16,97 @@ const (
 	SecretTypeJSON SecretType = "json"
 	// SecretTypeOther represents any other type of secret
 	SecretTypeOther SecretType = "other"
+	// SecretTypeLogin represents website or application credentials
+	SecretTypeLogin SecretType = "login"
+	// SecretTypeCreditCard represents a payment card
+	SecretTypeCreditCard SecretType = "credit_card"
+	// SecretTypeSSHKey represents an SSH key pair
+	SecretTypeSSHKey SecretType = "ssh_key"
+	// SecretTypeSecureNote represents free-form private text
+	SecretTypeSecureNote SecretType = "secure_note"
+	// SecretTypeAPIToken represents an API token and its scopes
+	SecretTypeAPIToken SecretType = "api_token"
+)
+
+// Field names of the secret types, see service.SecretTypes
+const (
+	FieldValue      = "value"
+	FieldUsername   = "username"
+	FieldPassword   = "password"
+	FieldURL        = "url"
+	FieldNotes      = "notes"
+	FieldCardholder = "cardholder"
+	FieldCardNumber = "number"
+	FieldExpiry     = "expiry"
+	FieldCVV        = "cvv"
+	FieldPrivateKey = "private_key"
+	FieldPublicKey  = "public_key"
+	FieldPassphrase = "passphrase"
+	FieldNote       = "note"
+	FieldToken      = "token"
+	FieldScopes     = "scopes"
+)
+
+// CustomFieldType tells how the value of a custom field is checked and shown
+type CustomFieldType string
+
+// Custom field type constants
+const (
+	CustomFieldText  CustomFieldType = "text"
+	CustomFieldURL   CustomFieldType = "url"
+	CustomFieldEmail CustomFieldType = "email"
+	CustomFieldOTP   CustomFieldType = "otp" // An otpauth:// URI or a base32 TOTP seed
 )
 
-// SecretVersion represents a specific version of a secret with its encrypted value
+// CustomField is an extra labelled value on any secret. Stored in a SecretVersion its value
+// is encrypted; the service returns it decrypted.
+type CustomField struct {
+	Label  string          `json:"label"`
+	Value  string          `json:"value"`
+	Type   CustomFieldType `json:"type,omitempty"`
+	Hidden bool            `json:"hidden,omitempty"` // Masked until revealed
+}
+
+// Attachment describes a file kept with a secret. Its content is encrypted and stored
+// out of the secrets file under ID, so versions can share it without copying it.
+type Attachment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MIMEType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+}
+
+// SecretVersion represents a specific version of a secret with its encrypted value.
+// Typed secrets with several fields keep each field encrypted on its own in Fields instead.
 type SecretVersion struct {
-	SecretValueEnc string `json:"secretValueEnc"`
-	Version        int    `json:"version"`
-	UpdatedAt      string `json:"updatedAt"`
-	UpdatedBy      string `json:"updatedBy,omitempty"`
+	SecretValueEnc string            `json:"secretValueEnc"`
+	Fields         map[string]string `json:"fields,omitempty"`
+	CustomFields   []CustomField     `json:"customFields,omitempty"`
+	Attachments    []Attachment      `json:"attachments,omitempty"`
+	Version        int               `json:"version"`
+	UpdatedAt      string            `json:"updatedAt"`
+	UpdatedBy      string            `json:"updatedBy,omitempty"`   // Who wrote the version, see service.WithAuthor
+	Note           string            `json:"note,omitempty"`        // Why the version was written, given with the change
+	RenamedFrom    string            `json:"renamedFrom,omitempty"` // Name of the secret before the rename that added this version
 }
 
 // Secret represents a secret with its metadata and version history
 type Secret struct {
 	SecretName     string          `json:"secretName"`
 	Type           SecretType      `json:"type"`
+	Folder         string          `json:"folder,omitempty"` // Slash separated path, empty for the top level
+	Tags           []string        `json:"tags,omitempty"`
+	WrappedKey     string          `json:"wrappedKey,omitempty"` // Data key for the versions, encrypted with the vault key
+	DeletedAt      string          `json:"deletedAt,omitempty"`  // When the secret was moved to the trash, empty while it is in use
 	CurrentVersion int             `json:"currentVersion"`
 	Versions       []SecretVersion `json:"versions"`
 }
 
+// Trashed reports whether the secret was deleted and waits in the trash to be restored or purged
+func (s *Secret) Trashed() bool {
+	return s.DeletedAt != ""
+}
+
 // GetCurrentVersion returns the current (latest) version of the secret
 func (s *Secret) GetCurrentVersion() *SecretVersion {
 	for _, version := range s.Versions {
@@ -39,6 +117,16 @@ func (s *Secret) GetCurrentVersion() *SecretVersion {
 	return nil
 }
 
+// NextVersion returns the number of the next version, one above the highest. Vaults reverted by
+// earlier releases may have an older current version, so it is not always CurrentVersion+1.
+func (s *Secret) NextVersion() int {
+	next := 1
+	for _, version := range s.Versions {
+		next = max(next, version.Version+1)
+	}
+	return next
+}
+
 // GetVersionsSorted returns all versions sorted by version number (latest first)
 func (s *Secret) GetVersionsSorted() []SecretVersion {
 	versions := make([]SecretVersion, len(s.Versions))
@@ -56,12 +144,49 @@ func (s *Secret) GetVersionsSorted() []SecretVersion {
 	return versions
 }
 
+// CurrentSchemaVersion is the layout of SecretsFile written by this version, see storage migrations
+const CurrentSchemaVersion = 9
+
 // SecretsFile represents the file structure for storing secrets
 type SecretsFile struct {
-	AppVersion  string   `json:"appVersion"`
-	AppUser     string   `json:"appUser"`
-	LastUpdated string   `json:"lastUpdated"`
-	Secrets     []Secret `json:"secrets"`
+	SchemaVersion       int      `json:"schemaVersion,omitempty"` // Layout of the file, 0 for files written before versioning
+	AppVersion          string   `json:"appVersion"`
+	AppUser             string   `json:"appUser"`
+	LastUpdated         string   `json:"lastUpdated"`
+	KeyID               string   `json:"keyId,omitempty"`               // UUID of the key the secret values are encrypted with
+	AssociatedDataBound bool     `json:"associatedDataBound,omitempty"` // Values are sealed with their secret name, version and type
+	Generation          uint64   `json:"generation,omitempty"`          // Incremented on every write to detect rollbacks
+	MAC                 string   `json:"mac,omitempty"`                 // HMAC over the rest of the file, see storage.FileStorage
+	SyncGeneration      uint64   `json:"syncGeneration,omitempty"`      // Incremented on every push to a sync remote to detect replayed copies
+	Secrets             []Secret `json:"secrets"`
+}
+
+// Clone returns a deep copy of the file so cached data cannot be modified through it
+func (f SecretsFile) Clone() SecretsFile {
+	clone := f
+	if f.Secrets != nil {
+		clone.Secrets = make([]Secret, len(f.Secrets))
+		for i, secret := range f.Secrets {
+			clone.Secrets[i] = secret.Clone()
+		}
+	}
+	return clone
+}
+
+// Clone returns a deep copy of the secret
+func (s Secret) Clone() Secret {
+	clone := s
+	clone.Tags = slices.Clone(s.Tags)
+	if s.Versions != nil {
+		clone.Versions = make([]SecretVersion, len(s.Versions))
+		for i, version := range s.Versions {
+			clone.Versions[i] = version
+			clone.Versions[i].Fields = maps.Clone(version.Fields)
+			clone.Versions[i].CustomFields = slices.Clone(version.CustomFields)
+			clone.Versions[i].Attachments = slices.Clone(version.Attachments)
+		}
+	}
+	return clone
 }
 
 // SecretView represents a UI view model for displaying secrets
@@ -0,0 +1,16 @@
+package domain
+
+// SyncConflict is a secret that was changed differently on both sides since the last sync.
+// A nil side means the secret was deleted there.
+type SyncConflict struct {
+	Name   string
+	Local  *Secret
+	Remote *Secret
+}
+
+// SyncResult summarizes a sync with a remote vault
+type SyncResult struct {
+	Received  []string // Secrets changed locally by the sync
+	Sent      []string // Secrets changed on the remote by the sync
+	Conflicts []SyncConflict
+}
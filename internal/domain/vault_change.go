@@ -0,0 +1,7 @@
+package domain
+
+// VaultChange reports that the stored vault was changed by another process or tool
+type VaultChange struct {
+	Secrets []string // Names of the secrets added, changed or removed; nil when unknown
+	Err     error    // Set when the changed vault could not be read
+}
@@ -0,0 +1,39 @@
+package fsutil_test
+
+import (
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/tests/helpers"
+	os "os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Replaces the file and leaves no temporary files", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "vault.key")
+		tc.Require.NoError(os.WriteFile(path, []byte("old"), 0644))
+
+		tc.Require.NoError(fsutil.WriteFileAtomic(path, []byte("new"), 0600))
+
+		data, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.Equal("new", string(data))
+		entries, err := os.ReadDir(dir)
+		tc.Require.NoError(err)
+		tc.Assert.Len(entries, 1, "The temporary file should be renamed into place")
+		if runtime.GOOS != "windows" {
+			info, err := os.Stat(path)
+			tc.Require.NoError(err)
+			tc.Assert.Equal(os.FileMode(0600), info.Mode().Perm())
+		}
+	})
+
+	helpers.WithUnitTestCase(t, "Keeps the old file when the write fails", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "missing", "vault.key")
+		tc.Assert.Error(fsutil.WriteFileAtomic(path, []byte("new"), 0600))
+		_, err := os.Stat(path)
+		tc.Assert.True(os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-password-manager/internal/domain"
+)
+
+// versionContext is the associated data every secret version is sealed with.
+// Moving a ciphertext to another secret, version, type or field makes it fail to decrypt.
+type versionContext struct {
+	Name    string            `json:"name"`
+	Version int               `json:"version"`
+	Type    domain.SecretType `json:"type"`
+	Field   string            `json:"field,omitempty"`  // Only set for the fields of typed secrets
+	Custom  string            `json:"custom,omitempty"` // Only set for custom fields, by label
+}
+
+// versionAssociatedData returns the canonical associated data for a version of secret
+func versionAssociatedData(secret *domain.Secret, version int) []byte {
+	// Marshalling a struct with string and int fields cannot fail
+	ad, _ := json.Marshal(versionContext{
+		Name:    secret.SecretName,
+		Version: version,
+		Type:    secret.Type,
+	})
+	return ad
+}
+
+// fieldAssociatedData returns the canonical associated data for a field of a version of secret
+func fieldAssociatedData(secret *domain.Secret, version int, field string) []byte {
+	// Marshalling a struct with string and int fields cannot fail
+	ad, _ := json.Marshal(versionContext{
+		Name:    secret.SecretName,
+		Version: version,
+		Type:    secret.Type,
+		Field:   field,
+	})
+	return ad
+}
+
+// customFieldAssociatedData returns the canonical associated data for a custom field of a version of secret
+func customFieldAssociatedData(secret *domain.Secret, version int, label string) []byte {
+	// Marshalling a struct with string and int fields cannot fail
+	ad, _ := json.Marshal(versionContext{
+		Name:    secret.SecretName,
+		Version: version,
+		Type:    secret.Type,
+		Custom:  label,
+	})
+	return ad
+}
+
+// encryptVersion seals plaintext for the given version of secret with the secret's data key
+func (s *SecretsService) encryptVersion(secret *domain.Secret, version int, plaintext, masterKey []byte) ([]byte, error) {
+	dataKey, err := s.dataKey(secret, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.crypto.EncryptWithAD(plaintext, dataKey, versionAssociatedData(secret, version))
+}
+
+// decryptVersion opens a version of secret. Values without a data key or associated data
+// are only accepted until the file is migrated.
+func (s *SecretsService) decryptVersion(secret *domain.Secret, version *domain.SecretVersion, masterKey []byte, bound bool) ([]byte, error) {
+	key := masterKey
+	if secret.WrappedKey != "" {
+		dataKey, err := s.dataKey(secret, masterKey)
+		if err != nil {
+			return nil, err
+		}
+		key = dataKey
+	}
+
+	if !bound {
+		return s.crypto.Decrypt([]byte(version.SecretValueEnc), key)
+	}
+
+	plainBytes, err := s.crypto.DecryptWithAD([]byte(version.SecretValueEnc), key, versionAssociatedData(secret, version.Version))
+	if err != nil {
+		return nil, fmt.Errorf("secret '%s' version %d failed authentication: %w", secret.SecretName, version.Version, err)
+	}
+	return plainBytes, nil
+}
+
+// encryptFields seals each field for the given version of secret with the secret's data key
+func (s *SecretsService) encryptFields(secret *domain.Secret, version int, fields map[string]string, masterKey []byte) (map[string]string, error) {
+	dataKey, err := s.dataKey(secret, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make(map[string]string, len(fields))
+	for name, value := range fields {
+		encryptedValue, err := s.crypto.EncryptWithAD([]byte(value), dataKey, fieldAssociatedData(secret, version, name))
+		if err != nil {
+			return nil, err
+		}
+		sealed[name] = string(encryptedValue)
+	}
+	return sealed, nil
+}
+
+// decryptFields opens the fields of a version of a typed secret.
+// A single value secret returns its value as the value field.
+func (s *SecretsService) decryptFields(secret *domain.Secret, version *domain.SecretVersion, masterKey []byte, bound bool) (map[string]string, error) {
+	if version.Fields == nil {
+		plainBytes, err := s.decryptVersion(secret, version, masterKey, bound)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{domain.FieldValue: string(plainBytes)}, nil
+	}
+
+	dataKey, err := s.dataKey(secret, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(version.Fields))
+	for name, encryptedValue := range version.Fields {
+		plainBytes, err := s.crypto.DecryptWithAD([]byte(encryptedValue), dataKey, fieldAssociatedData(secret, version.Version, name))
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s' version %d field %s failed authentication: %w", secret.SecretName, version.Version, name, err)
+		}
+		fields[name] = string(plainBytes)
+	}
+	return fields, nil
+}
+
+// associatedDataBound reports whether the stored values are sealed with associated data
+func (s *SecretsService) associatedDataBound() (bool, error) {
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return false, err
+	}
+	return secretsData.AssociatedDataBound, nil
+}
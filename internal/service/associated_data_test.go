@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"go-password-manager/internal/crypto"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"testing"
+)
+
+func TestAssociatedData(t *testing.T) {
+	helpers.WithUnitTestCase(t, "SwappedSecretValueIsRejected", func(tc *helpers.UnitTestCase) {
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Special.Name, testdata.TestSecrets.Special.Value))
+
+		fileData, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Assert.True(fileData.AssociatedDataBound, "New vaults should be bound")
+
+		// Move the first secret's ciphertext into the second secret
+		fileData.Secrets[1].Versions[0].SecretValueEnc = fileData.Secrets[0].Versions[0].SecretValueEnc
+		tc.Require.NoError(storage.WriteSecrets(fileData))
+
+		_, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Special.Name)
+		tc.Assert.Error(err, "Expected error decrypting a value moved from another secret")
+	})
+
+	helpers.WithUnitTestCase(t, "SwappedVersionIsRejected", func(tc *helpers.UnitTestCase) {
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+
+		fileData, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		versions := fileData.Secrets[0].Versions
+		versions[0].SecretValueEnc, versions[1].SecretValueEnc = versions[1].SecretValueEnc, versions[0].SecretValueEnc
+		tc.Require.NoError(storage.WriteSecrets(fileData))
+
+		_, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Assert.Error(err, "Expected error decrypting a value moved from another version")
+	})
+
+	helpers.WithUnitTestCase(t, "MigrateUnboundValues", func(tc *helpers.UnitTestCase) {
+		key := []byte(testdata.TestEncryptionKey)
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService(key), storage)
+
+		// Values written before associated data existed
+		unbound, err := crypto.Encrypt([]byte(testdata.TestSecrets.Simple.Value), key)
+		tc.Require.NoError(err)
+		fileData, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		fileData.Secrets = []domain.Secret{{
+			SecretName:     testdata.TestSecrets.Simple.Name,
+			CurrentVersion: 1,
+			Versions:       []domain.SecretVersion{{Version: 1, SecretValueEnc: unbound}},
+		}}
+		tc.Require.NoError(storage.WriteSecrets(fileData))
+
+		value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, "Unbound values should decrypt before migration")
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+
+		tc.Require.NoError(svc.MigrateSecrets(), "Expected no error migrating")
+
+		fileData, err = storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Assert.True(fileData.AssociatedDataBound, "Migration should mark the vault as bound")
+		tc.Assert.NotEqual(unbound, fileData.Secrets[0].Versions[0].SecretValueEnc, "Value should be re-encrypted")
+
+		value, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+
+		// Unbound ciphertexts are no longer accepted once migrated
+		fileData.Secrets[0].Versions[0].SecretValueEnc = unbound
+		tc.Require.NoError(storage.WriteSecrets(fileData))
+		_, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Assert.Error(err, "Expected error decrypting an unbound value after migration")
+	})
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/logger"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrAttachmentsUnsupported is returned when the storage cannot keep attachments
+var ErrAttachmentsUnsupported = errors.New("storage does not support attachments")
+
+// ErrAttachmentMissing is returned when a version references an attachment the storage does not have,
+// for example after a sync, which only carries the secrets file
+var ErrAttachmentMissing = errors.New("attachment not found in storage")
+
+// ErrAttachmentPurged is returned for an attachment whose secret was purged from the trash. Backups and
+// git history keep the secrets file only, so a secret restored from them comes back without its attachments.
+var ErrAttachmentPurged = errors.New("attachment purged: it was deleted for good when its secret was purged from the trash")
+
+// MaxAttachmentSize bounds one attachment, since attachments are encrypted in memory
+const MaxAttachmentSize = 25 << 20
+
+// AttachmentStore is implemented by storages that keep attachment blobs out of the secrets file.
+// The service encrypts blobs before they are stored.
+type AttachmentStore interface {
+	PutAttachment(id string, blob []byte) error
+	GetAttachment(id string) ([]byte, error)
+	DeleteAttachment(id string) error
+}
+
+// attachmentContext is the associated data an attachment is sealed with. Attachments are shared
+// by versions, so it names the attachment only; the secret's data key ties it to the secret.
+type attachmentContext struct {
+	ID      string `json:"id"`
+	Purpose string `json:"purpose"`
+}
+
+func attachmentAssociatedData(id string) []byte {
+	// Marshalling a struct of strings cannot fail
+	ad, _ := json.Marshal(attachmentContext{ID: id, Purpose: "attachment"})
+	return ad
+}
+
+func (s *SecretsService) attachmentStore() (AttachmentStore, error) {
+	store, ok := s.storage.(AttachmentStore)
+	if !ok {
+		return nil, ErrAttachmentsUnsupported
+	}
+	return store, nil
+}
+
+// AddAttachment encrypts data and adds a version of the named secret with it attached.
+// The MIME type is guessed from the file name or the content when empty.
+func (s *SecretsService) AddAttachment(name, fileName, mimeType string, data []byte) (domain.Attachment, error) {
+	store, err := s.attachmentStore()
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	fileName = filepath.Base(strings.TrimSpace(fileName))
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		return domain.Attachment{}, fmt.Errorf("%w: attachments need a file name", ErrInvalidSecret)
+	}
+	if len(data) > MaxAttachmentSize {
+		return domain.Attachment{}, fmt.Errorf("%w: %s is larger than %d MiB", ErrInvalidSecret, fileName, MaxAttachmentSize>>20)
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	id, err := newAttachmentID()
+	if err != nil {
+		return domain.Attachment{}, err
+	}
+	attachment := domain.Attachment{ID: id, Name: fileName, MIMEType: mimeType, Size: int64(len(data))}
+
+	err = s.updateSecret(name, "", func(secret *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		if slices.ContainsFunc(content.attachments, func(a domain.Attachment) bool { return a.Name == fileName }) {
+			return fmt.Errorf("%w: '%s' already has an attachment named %s", ErrInvalidSecret, name, fileName)
+		}
+		dataKey, err := s.dataKey(secret, s.crypto.GetKey())
+		if err != nil {
+			return err
+		}
+		blob, err := s.crypto.EncryptWithAD(data, dataKey, attachmentAssociatedData(attachment.ID))
+		if err != nil {
+			return err
+		}
+		if err := store.PutAttachment(attachment.ID, blob); err != nil {
+			return err
+		}
+		content.attachments = append(content.attachments, attachment)
+		return nil
+	})
+	if err != nil {
+		// Nothing references the blob if the version was not written
+		_ = store.DeleteAttachment(attachment.ID)
+		return domain.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// newAttachmentID returns a random ID to store an attachment under
+func newAttachmentID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ExtractAttachment decrypts an attachment of any version of secret
+func (s *SecretsService) ExtractAttachment(secret *domain.Secret, id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.extractAttachment(secret, id)
+}
+
+func (s *SecretsService) extractAttachment(secret *domain.Secret, id string) ([]byte, error) {
+	store, err := s.attachmentStore()
+	if err != nil {
+		return nil, err
+	}
+	var attachment *domain.Attachment
+	for _, version := range secret.Versions {
+		if i := slices.IndexFunc(version.Attachments, func(a domain.Attachment) bool { return a.ID == id }); i >= 0 {
+			attachment = &version.Attachments[i]
+			break
+		}
+	}
+	if attachment == nil {
+		return nil, fmt.Errorf("attachment %s not found on secret '%s'", id, secret.SecretName)
+	}
+
+	blob, err := store.GetAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("%w: %s of '%s'", ErrAttachmentPurged, attachment.Name, secret.SecretName)
+	}
+	dataKey, err := s.dataKey(secret, s.crypto.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.crypto.DecryptWithAD(blob, dataKey, attachmentAssociatedData(id))
+	if err != nil {
+		return nil, fmt.Errorf("attachment %s of '%s' failed authentication: %w", attachment.Name, secret.SecretName, err)
+	}
+	return data, nil
+}
+
+// RemoveAttachment adds a version of the named secret without the attachment.
+// Older versions keep it, so the blob stays until the secret is purged from the trash.
+func (s *SecretsService) RemoveAttachment(name, id string) error {
+	return s.updateSecret(name, "", func(_ *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		i := slices.IndexFunc(content.attachments, func(a domain.Attachment) bool { return a.ID == id })
+		if i < 0 {
+			return fmt.Errorf("attachment %s not found on secret '%s'", id, name)
+		}
+		content.attachments = slices.Delete(content.attachments, i, i+1)
+		return nil
+	})
+}
+
+// deleteAttachments removes the blobs of every version of secret, copies no vault refers to yet.
+// A blob left behind only costs space, so failures are logged.
+func (s *SecretsService) deleteAttachments(secret domain.Secret) {
+	store, ok := s.storage.(AttachmentStore)
+	if !ok {
+		return
+	}
+	deleted := map[string]bool{}
+	for _, version := range secret.Versions {
+		for _, attachment := range version.Attachments {
+			if deleted[attachment.ID] {
+				continue
+			}
+			deleted[attachment.ID] = true
+			if err := store.DeleteAttachment(attachment.ID); err != nil {
+				logger.Warn("Failed to delete attachment "+attachment.ID+":", err.Error())
+			}
+		}
+	}
+}
+
+// purgeAttachments replaces the blobs of every version of secret with an empty tombstone after it was
+// purged from the trash. Sealed blobs are never empty, so the tombstone tells a purged attachment of a
+// restored backup or commit apart from one that never reached this machine. Failures are logged.
+func (s *SecretsService) purgeAttachments(secret domain.Secret) {
+	store, ok := s.storage.(AttachmentStore)
+	if !ok {
+		return
+	}
+	purged := map[string]bool{}
+	for _, version := range secret.Versions {
+		for _, attachment := range version.Attachments {
+			if purged[attachment.ID] {
+				continue
+			}
+			purged[attachment.ID] = true
+			if err := store.PutAttachment(attachment.ID, nil); err != nil {
+				logger.Warn("Failed to purge attachment "+attachment.ID+":", err.Error())
+			}
+		}
+	}
+}
+
+// copyAttachments stores a copy of every attachment of source sealed with the data key of target,
+// a clone of source, and points the versions of target at the copies, which are returned
+func (s *SecretsService) copyAttachments(source, target *domain.Secret) (copied []domain.Attachment, err error) {
+	defer func() {
+		if err != nil {
+			s.deleteAttachments(domain.Secret{Versions: []domain.SecretVersion{{Attachments: copied}}})
+		}
+	}()
+
+	copies := map[string]string{}
+	for i := range target.Versions {
+		attachments := target.Versions[i].Attachments
+		for j, attachment := range attachments {
+			if id, ok := copies[attachment.ID]; ok {
+				attachments[j].ID = id
+				continue
+			}
+			store, err := s.attachmentStore()
+			if err != nil {
+				return copied, err
+			}
+			data, err := s.extractAttachment(source, attachment.ID)
+			if err != nil {
+				return copied, err
+			}
+			id, err := newAttachmentID()
+			if err != nil {
+				return copied, err
+			}
+			dataKey, err := s.dataKey(target, s.crypto.GetKey())
+			if err != nil {
+				return copied, err
+			}
+			blob, err := s.crypto.EncryptWithAD(data, dataKey, attachmentAssociatedData(id))
+			if err != nil {
+				return copied, err
+			}
+			if err := store.PutAttachment(id, blob); err != nil {
+				return copied, err
+			}
+			copies[attachment.ID] = id
+			attachments[j].ID = id
+			copied = append(copied, attachments[j])
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Stores attachments encrypted out of the file", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+
+		attachment, err := svc.AddAttachment(name, "kubeconfig.yaml", "", []byte("apiVersion: v1"))
+		tc.Require.NoError(err)
+		tc.Assert.Equal("kubeconfig.yaml", attachment.Name)
+		tc.Assert.Equal(int64(14), attachment.Size)
+		tc.Assert.NotEmpty(attachment.MIMEType)
+
+		raw, err := svc.GetRaw()
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), "apiVersion", "The content is not in the secrets file")
+
+		secret, err := svc.GetSecret(name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(2, secret.CurrentVersion, "Attaching adds a version")
+		tc.Require.Len(secret.GetCurrentVersion().Attachments, 1)
+		data, err := svc.ExtractAttachment(secret, attachment.ID)
+		tc.Require.NoError(err)
+		tc.Assert.Equal("apiVersion: v1", string(data))
+
+		_, err = svc.AddAttachment(name, "kubeconfig.yaml", "", []byte("again"))
+		tc.Assert.ErrorIs(err, service.ErrInvalidSecret, "Names are unique per version")
+	})
+
+	helpers.WithUnitTestCase(t, "Removing keeps the attachment in history", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+		attachment, err := svc.AddAttachment(name, "codes.pdf", "application/pdf", []byte("%PDF-1.7"))
+		tc.Require.NoError(err)
+		tc.Require.NoError(svc.UpdateSecret(name, "value2", ""))
+		tc.Require.NoError(svc.RemoveAttachment(name, attachment.ID))
+
+		secret, err := svc.GetSecret(name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(4, secret.CurrentVersion)
+		tc.Assert.Empty(secret.GetCurrentVersion().Attachments)
+		tc.Assert.Equal([]domain.Attachment{attachment}, secret.Versions[2].Attachments, "Updating the value kept the attachment")
+		data, err := svc.ExtractAttachment(secret, attachment.ID)
+		tc.Require.NoError(err, "Older versions can still be extracted")
+		tc.Assert.Equal("%PDF-1.7", string(data))
+	})
+
+	helpers.WithUnitTestCase(t, "Purging the secret deletes its attachments", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+		attachment, err := svc.AddAttachment(name, filepath.Join(dir, "ca.pem"), "", []byte("certificate"))
+		tc.Require.NoError(err)
+		tc.Assert.Equal("ca.pem", attachment.Name, "Only the base name is kept")
+
+		secret, err := svc.GetSecret(name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Require.NoError(svc.DeleteSecret(name))
+		_, err = svc.ExtractAttachment(secret, attachment.ID)
+		tc.Assert.NoError(err, "A secret in the trash keeps its attachments")
+		tc.Require.NoError(svc.PurgeSecret(name))
+		_, err = svc.ExtractAttachment(secret, attachment.ID)
+		tc.Assert.ErrorIs(err, service.ErrAttachmentPurged, "A copy of the secret kept elsewhere learns why")
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects oversized attachments", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+		_, err := svc.AddAttachment(name, "big.bin", "", make([]byte, service.MaxAttachmentSize+1))
+		tc.Assert.ErrorIs(err, service.ErrInvalidSecret)
+	})
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"go-password-manager/internal/domain"
+)
+
+// ErrBackupsUnsupported is returned when the storage does not keep backups
+var ErrBackupsUnsupported = errors.New("storage does not support backups")
+
+// ErrBackupKeyRotated is returned when a backup predates a key rotation and its data keys can no longer be unwrapped
+var ErrBackupKeyRotated = errors.New("backup was made with a key that has since been rotated")
+
+// BackupManager is implemented by storages that snapshot the vault before writing.
+// RestoreBackup makes a backup the current vault again; the replaced vault is backed up first.
+type BackupManager interface {
+	ListBackups() ([]domain.Backup, error)
+	RestoreBackup(id string) error
+}
+
+// ListBackups returns the available backups, newest first
+func (s *SecretsService) ListBackups() ([]domain.Backup, error) {
+	backups, ok := s.storage.(BackupManager)
+	if !ok {
+		return nil, ErrBackupsUnsupported
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return backups.ListBackups()
+}
+
+// RestoreBackup replaces the vault with the backup with the given ID
+func (s *SecretsService) RestoreBackup(id string) error {
+	backups, ok := s.storage.(BackupManager)
+	if !ok {
+		return ErrBackupsUnsupported
+	}
+
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.invalidateCache()
+	return backups.RestoreBackup(id)
+}
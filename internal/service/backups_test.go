@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackups(t *testing.T) {
+	helpers.WithUnitTestCase(t, "UnsupportedStorage", func(tc *helpers.UnitTestCase) {
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), setupTestStorage(t))
+
+		_, err := svc.ListBackups()
+		tc.Assert.ErrorIs(err, service.ErrBackupsUnsupported)
+		tc.Assert.ErrorIs(svc.RestoreBackup("secrets-20240101T000000.000000000Z"), service.ErrBackupsUnsupported)
+	})
+
+	helpers.WithUnitTestCase(t, "RestoreRefreshesTheCache", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store, err := storage.NewBackupStorage(
+			storage.NewFileStorage(filepath.Join(dir, testSecretsFile), "1.0.0", testdata.TestUsers.UnitTestUser.Name),
+			filepath.Join(dir, "backups"),
+			storage.Retention{KeepLast: 10},
+		)
+		tc.Require.NoError(err)
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), store)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+		tc.Require.NoError(svc.DeleteSecret(testdata.TestSecrets.Simple.Name))
+
+		backups, err := svc.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 1, "Only the delete overwrote an existing file")
+		tc.Require.NoError(svc.RestoreBackup(backups[0].ID))
+
+		value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, "The deleted secret should be back")
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+	})
+}
@@ -0,0 +1,71 @@
+package service
+
+import "go-password-manager/internal/domain"
+
+// ChangeDetector is implemented by storages that can cheaply tell whether the stored secrets changed.
+// Stamp must return a different value after every write, including writes by other processes.
+type ChangeDetector interface {
+	Stamp() (string, error)
+}
+
+// readSecrets returns the secrets file, served from memory while the storage reports no change.
+// Callers get their own copy and may modify it freely.
+func (s *SecretsService) readSecrets() (domain.SecretsFile, error) {
+	detector, canCache := s.storage.(ChangeDetector)
+	var stamp string
+	if canCache {
+		var err error
+		if stamp, err = detector.Stamp(); err != nil {
+			canCache = false
+		}
+	}
+
+	if canCache {
+		s.cacheMu.Lock()
+		if s.cache != nil && s.cacheStamp == stamp {
+			cached := s.cache.Clone()
+			s.cacheMu.Unlock()
+			return cached, nil
+		}
+		s.cacheMu.Unlock()
+	}
+
+	data, err := s.storage.ReadSecrets()
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+
+	if canCache {
+		// The stamp was taken before reading, so a concurrent write only causes an extra read later
+		cached := data.Clone()
+		s.cacheMu.Lock()
+		s.cache = &cached
+		s.cacheStamp = stamp
+		s.cacheMu.Unlock()
+	}
+	return data, nil
+}
+
+// writeSecrets persists data and drops the cache, the storage fills in fields like the write time.
+// The stamp after the write is kept so watchers can tell this write from those of other processes.
+func (s *SecretsService) writeSecrets(data domain.SecretsFile) error {
+	s.invalidateCache()
+	if err := s.storage.WriteSecrets(data); err != nil {
+		return err
+	}
+	if detector, ok := s.storage.(ChangeDetector); ok {
+		if stamp, err := detector.Stamp(); err == nil {
+			s.cacheMu.Lock()
+			s.writtenStamp = stamp
+			s.cacheMu.Unlock()
+		}
+	}
+	return nil
+}
+
+func (s *SecretsService) invalidateCache() {
+	s.cacheMu.Lock()
+	s.cache = nil
+	s.cacheStamp = ""
+	s.cacheMu.Unlock()
+}
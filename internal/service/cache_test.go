@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingStorage counts how often the underlying file is actually read
+type countingStorage struct {
+	service.StorageService
+	reads atomic.Int32
+}
+
+func (c *countingStorage) ReadSecrets() (domain.SecretsFile, error) {
+	c.reads.Add(1)
+	return c.StorageService.ReadSecrets()
+}
+
+func (c *countingStorage) Stamp() (string, error) {
+	return c.StorageService.(service.ChangeDetector).Stamp()
+}
+
+func TestSecretsCache(t *testing.T) {
+	helpers.WithUnitTestCase(t, "RepeatedReadsUseTheCache", func(tc *helpers.UnitTestCase) {
+		counting := &countingStorage{StorageService: setupTestStorage(t)}
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), counting)
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+
+		counting.reads.Store(0)
+		for i := 0; i < 5; i++ {
+			total, err := svc.GetTotalSecrets()
+			tc.Require.NoError(err)
+			tc.Assert.Equal(1, total)
+		}
+		tc.Assert.Equal(int32(1), counting.reads.Load(), "Only the first read should reach the file")
+	})
+
+	helpers.WithUnitTestCase(t, "ChangesByAnotherProcessInvalidateTheCache", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), testSecretsFile)
+		crypto := newMockCryptoService([]byte(testdata.TestEncryptionKey))
+		first := service.NewSecretsService(crypto, storage.NewFileStorage(path, "1.0.0", testdata.TestUsers.UnitTestUser.Name))
+		second := service.NewSecretsService(crypto, storage.NewFileStorage(path, "1.0.0", testdata.TestUsers.UnitTestUser.Name))
+
+		total, err := first.GetTotalSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(0, total)
+
+		tc.Require.NoError(second.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+
+		total, err = first.GetTotalSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(1, total, "The cache should notice the file changed")
+	})
+
+	helpers.WithUnitTestCase(t, "CallersGetTheirOwnCopy", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+
+		fileData, err := svc.LoadAllSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		fileData.Secrets[0].SecretName = "modified"
+		fileData.Secrets[0].Versions[0].Version = 99
+
+		secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, "Modifying a returned copy should not affect the cache")
+		tc.Assert.Equal(1, secret.Versions[0].Version)
+	})
+
+	helpers.WithUnitTestCase(t, "ConcurrentCallsAreSafe", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 40)
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				errs <- svc.SaveNewSecret(fmt.Sprintf("secret-%d", i), "value")
+			}(i)
+			go func() {
+				defer wg.Done()
+				_, err := svc.GetTotalVersions()
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			tc.Require.NoError(err)
+		}
+
+		total, err := svc.GetTotalSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(20, total, "No update should be lost")
+	})
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/base32"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// secretContent is everything one version of a secret holds, decrypted
+type secretContent struct {
+	fields      map[string]string
+	custom      []domain.CustomField
+	attachments []domain.Attachment // Already encrypted and stored, see AttachmentStore
+}
+
+// versionContent opens the fields and custom fields of a version of a secret in a migrated file
+func (s *SecretsService) versionContent(secret *domain.Secret, version *domain.SecretVersion) (secretContent, error) {
+	fields, err := s.decryptFields(secret, version, s.crypto.GetKey(), true)
+	if err != nil {
+		return secretContent{}, err
+	}
+	custom, err := s.decryptCustomFields(secret, version, s.crypto.GetKey())
+	if err != nil {
+		return secretContent{}, err
+	}
+	return secretContent{fields: fields, custom: custom, attachments: slices.Clone(version.Attachments)}, nil
+}
+
+// encryptCustomFields seals the value of each custom field for the given version of secret
+func (s *SecretsService) encryptCustomFields(secret *domain.Secret, version int, fields []domain.CustomField, masterKey []byte) ([]domain.CustomField, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	dataKey, err := s.dataKey(secret, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sealed := slices.Clone(fields)
+	for i, field := range sealed {
+		encryptedValue, err := s.crypto.EncryptWithAD([]byte(field.Value), dataKey, customFieldAssociatedData(secret, version, field.Label))
+		if err != nil {
+			return nil, err
+		}
+		sealed[i].Value = string(encryptedValue)
+	}
+	return sealed, nil
+}
+
+// decryptCustomFields opens the custom fields of a version of secret
+func (s *SecretsService) decryptCustomFields(secret *domain.Secret, version *domain.SecretVersion, masterKey []byte) ([]domain.CustomField, error) {
+	if len(version.CustomFields) == 0 {
+		return nil, nil
+	}
+	dataKey, err := s.dataKey(secret, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	fields := slices.Clone(version.CustomFields)
+	for i, field := range fields {
+		plainBytes, err := s.crypto.DecryptWithAD([]byte(field.Value), dataKey, customFieldAssociatedData(secret, version.Version, field.Label))
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s' version %d custom field %s failed authentication: %w", secret.SecretName, version.Version, field.Label, err)
+		}
+		fields[i].Value = string(plainBytes)
+	}
+	return fields, nil
+}
+
+// checkCustomFields requires unique labels and values that fit their type
+func checkCustomFields(fields []domain.CustomField) error {
+	labels := map[string]bool{}
+	for _, field := range fields {
+		if strings.TrimSpace(field.Label) == "" {
+			return fmt.Errorf("%w: custom fields need a label", ErrInvalidSecret)
+		}
+		if labels[field.Label] {
+			return fmt.Errorf("%w: custom field '%s' already exists", ErrInvalidSecret, field.Label)
+		}
+		labels[field.Label] = true
+		if err := checkCustomFieldValue(field); err != nil {
+			return fmt.Errorf("%w: custom field '%s': %s", ErrInvalidSecret, field.Label, err.Error())
+		}
+	}
+	return nil
+}
+
+func checkCustomFieldValue(field domain.CustomField) error {
+	switch field.Type {
+	case "", domain.CustomFieldText:
+		return nil
+	case domain.CustomFieldURL:
+		return validateURL(field.Value)
+	case domain.CustomFieldEmail:
+		if _, err := mail.ParseAddress(field.Value); err != nil {
+			return fmt.Errorf("%q is not an email address", field.Value)
+		}
+		return nil
+	case domain.CustomFieldOTP:
+		seed := field.Value
+		if strings.HasPrefix(seed, "otpauth://") {
+			u, err := url.Parse(seed)
+			if err != nil {
+				return fmt.Errorf("OTP URI does not parse: %s", err.Error())
+			}
+			seed = u.Query().Get("secret")
+		}
+		seed = strings.ToUpper(strings.ReplaceAll(seed, " ", ""))
+		if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "=")); err != nil || seed == "" {
+			return fmt.Errorf("OTP seed must be base32 or an otpauth:// URI")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type %s", field.Type)
+	}
+}
+
+// GetCustomFields returns the custom fields of the current version of secret, decrypted
+func (s *SecretsService) GetCustomFields(secret *domain.Secret) ([]domain.CustomField, error) {
+	return s.GetCustomFieldsByVersion(secret, secret.CurrentVersion)
+}
+
+// GetCustomFieldsByVersion returns the custom fields of a version of secret, decrypted
+func (s *SecretsService) GetCustomFieldsByVersion(secret *domain.Secret, versionNumber int) ([]domain.CustomField, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range secret.Versions {
+		if secret.Versions[i].Version == versionNumber {
+			return s.decryptCustomFields(secret, &secret.Versions[i], s.crypto.GetKey())
+		}
+	}
+	return nil, fmt.Errorf("version %d not found for secret '%s'", versionNumber, secret.SecretName)
+}
+
+// AddCustomField adds a version of the named secret with field appended to its custom fields
+func (s *SecretsService) AddCustomField(name string, field domain.CustomField) error {
+	return s.updateSecret(name, "", func(_ *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		content.custom = append(content.custom, field)
+		return nil
+	})
+}
+
+// UpdateCustomField adds a version of the named secret with the custom field labelled label replaced by field
+func (s *SecretsService) UpdateCustomField(name, label string, field domain.CustomField) error {
+	return s.updateSecret(name, "", func(_ *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		i := slices.IndexFunc(content.custom, func(f domain.CustomField) bool { return f.Label == label })
+		if i < 0 {
+			return fmt.Errorf("custom field '%s' not found on secret '%s'", label, name)
+		}
+		content.custom[i] = field
+		return nil
+	})
+}
+
+// RemoveCustomField adds a version of the named secret without the custom field labelled label
+func (s *SecretsService) RemoveCustomField(name, label string) error {
+	return s.updateSecret(name, "", func(_ *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		i := slices.IndexFunc(content.custom, func(f domain.CustomField) bool { return f.Label == label })
+		if i < 0 {
+			return fmt.Errorf("custom field '%s' not found on secret '%s'", label, name)
+		}
+		content.custom = slices.Delete(content.custom, i, i+1)
+		return nil
+	})
+}
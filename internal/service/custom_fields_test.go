@@ -0,0 +1,75 @@
+package service_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"testing"
+)
+
+func TestCustomFields(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Adds, edits and removes fields as new versions", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+
+		recovery := domain.CustomField{Label: "Recovery email", Value: "me@example.com", Type: domain.CustomFieldEmail}
+		pin := domain.CustomField{Label: "PIN", Value: "1234", Hidden: true}
+		tc.Require.NoError(svc.AddCustomField(name, recovery))
+		tc.Require.NoError(svc.AddCustomField(name, pin))
+		pin.Value = "5678"
+		tc.Require.NoError(svc.UpdateCustomField(name, "PIN", pin))
+		tc.Require.NoError(svc.RemoveCustomField(name, "Recovery email"))
+
+		secret, err := svc.GetSecret(name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(5, secret.CurrentVersion)
+		tc.Assert.NotContains(secret.Versions[4].CustomFields[0].Value, "5678", "Values are stored encrypted")
+
+		fields, err := svc.GetCustomFields(secret)
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]domain.CustomField{pin}, fields)
+		fields, err = svc.GetCustomFieldsByVersion(secret, 3)
+		tc.Require.NoError(err)
+		tc.Assert.Len(fields, 2)
+		tc.Assert.Equal("1234", fields[1].Value, "Older versions keep their fields")
+
+		value, err := svc.GetSecretValue(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value1", value, "The value is carried over")
+	})
+
+	helpers.WithUnitTestCase(t, "Keeps fields when the value changes", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewTypedSecret("example", domain.SecretTypeLogin, map[string]string{domain.FieldPassword: "old"}), errCreateSecret)
+		tc.Require.NoError(svc.AddCustomField("example", domain.CustomField{Label: "Site", Value: "https://example.com", Type: domain.CustomFieldURL}))
+		tc.Require.NoError(svc.UpdateSecretFields("example", map[string]string{domain.FieldPassword: "new"}, ""))
+
+		secret, err := svc.GetSecret("example")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		fields, err := svc.GetCustomFields(secret)
+		tc.Require.NoError(err)
+		tc.Require.Len(fields, 1)
+		tc.Assert.Equal("https://example.com", fields[0].Value)
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects invalid fields", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		name := testdata.TestSecrets.Simple.Name
+		tc.Require.NoError(svc.SaveNewSecret(name, "value1"), errCreateSecret)
+		tc.Require.NoError(svc.AddCustomField(name, domain.CustomField{Label: "TOTP", Value: "otpauth://totp/Example:me?secret=JBSWY3DPEHPK3PXP", Type: domain.CustomFieldOTP}))
+
+		for _, field := range []domain.CustomField{
+			{Label: "", Value: "x"},
+			{Label: "TOTP", Value: "JBSWY3DPEHPK3PXP", Type: domain.CustomFieldOTP},
+			{Label: "Seed", Value: "not base32!", Type: domain.CustomFieldOTP},
+			{Label: "Mail", Value: "nobody", Type: domain.CustomFieldEmail},
+			{Label: "Site", Value: "example.com", Type: domain.CustomFieldURL},
+			{Label: "Odd", Value: "x", Type: "color"},
+		} {
+			tc.Assert.ErrorIs(svc.AddCustomField(name, field), service.ErrInvalidSecret, "%+v should be rejected", field)
+		}
+		tc.Assert.Error(svc.RemoveCustomField(name, nonExistentName))
+	})
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/logger"
+)
+
+// errNoDataKey is returned when a secret has not been migrated to envelope encryption yet
+var errNoDataKey = errors.New("secret has no data key")
+
+// dataKeyContext is the associated data a data key is wrapped with, so it cannot be moved to another secret
+type dataKeyContext struct {
+	Name    string `json:"name"`
+	Purpose string `json:"purpose"`
+}
+
+func dataKeyAssociatedData(secret *domain.Secret) []byte {
+	// Marshalling a struct of strings cannot fail
+	ad, _ := json.Marshal(dataKeyContext{Name: secret.SecretName, Purpose: "data-key"})
+	return ad
+}
+
+// newDataKey generates a random data key for secret and stores it wrapped with masterKey
+func (s *SecretsService) newDataKey(secret *domain.Secret, masterKey []byte) ([]byte, error) {
+	dataKey := make([]byte, len(masterKey))
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if err := s.wrapDataKey(secret, dataKey, masterKey); err != nil {
+		return nil, err
+	}
+	return dataKey, nil
+}
+
+// wrapDataKey encrypts dataKey with masterKey and stores it on secret
+func (s *SecretsService) wrapDataKey(secret *domain.Secret, dataKey, masterKey []byte) error {
+	wrapped, err := s.crypto.EncryptWithAD(dataKey, masterKey, dataKeyAssociatedData(secret))
+	if err != nil {
+		return err
+	}
+	secret.WrappedKey = string(wrapped)
+	return nil
+}
+
+// dataKey unwraps the data key of secret with masterKey
+func (s *SecretsService) dataKey(secret *domain.Secret, masterKey []byte) ([]byte, error) {
+	if secret.WrappedKey == "" {
+		return nil, errNoDataKey
+	}
+	dataKey, err := s.crypto.DecryptWithAD([]byte(secret.WrappedKey), masterKey, dataKeyAssociatedData(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of '%s': %w", secret.SecretName, err)
+	}
+	return dataKey, nil
+}
+
+// secretsNeedUpgrade reports whether any value still uses an older encryption scheme
+func secretsNeedUpgrade(secretsData domain.SecretsFile) bool {
+	if !secretsData.AssociatedDataBound {
+		return true
+	}
+	for _, secret := range secretsData.Secrets {
+		if secret.WrappedKey == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeSecrets re-encrypts values written by older versions with a per-secret data key and associated data
+func (s *SecretsService) upgradeSecrets(secretsData *domain.SecretsFile) error {
+	masterKey := s.crypto.GetKey()
+	for i := range secretsData.Secrets {
+		secret := &secretsData.Secrets[i]
+		if secretsData.AssociatedDataBound && secret.WrappedKey != "" {
+			continue
+		}
+
+		plaintexts := make([][]byte, len(secret.Versions))
+		for j := range secret.Versions {
+			plainBytes, err := s.decryptVersion(secret, &secret.Versions[j], masterKey, secretsData.AssociatedDataBound)
+			if err != nil {
+				return err
+			}
+			plaintexts[j] = plainBytes
+		}
+
+		if secret.WrappedKey == "" {
+			if _, err := s.newDataKey(secret, masterKey); err != nil {
+				return err
+			}
+		}
+		for j := range secret.Versions {
+			version := &secret.Versions[j]
+			encryptedValue, err := s.encryptVersion(secret, version.Version, plaintexts[j], masterKey)
+			if err != nil {
+				return err
+			}
+			version.SecretValueEnc = string(encryptedValue)
+		}
+	}
+
+	secretsData.AssociatedDataBound = true
+	return nil
+}
+
+// MigrateSecrets upgrades values written by older versions to per-secret data keys and associated data.
+// It only writes when something changed, so it is cheap to call after every unlock.
+func (s *SecretsService) MigrateSecrets() error {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return err
+	}
+	if !secretsNeedUpgrade(secretsData) {
+		return nil
+	}
+
+	logger.Info("Migrating secrets to per-secret data keys")
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
+	}
+	return s.writeSecrets(secretsData)
+}
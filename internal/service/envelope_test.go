@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"go-password-manager/internal/crypto"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"testing"
+)
+
+func TestEnvelopeEncryption(t *testing.T) {
+	helpers.WithUnitTestCase(t, "EachSecretHasItsOwnDataKey", func(tc *helpers.UnitTestCase) {
+		key := []byte(testdata.TestEncryptionKey)
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService(key), storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Special.Name, testdata.TestSecrets.Special.Value))
+
+		fileData, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		first, second := fileData.Secrets[0], fileData.Secrets[1]
+		tc.Assert.NotEmpty(first.WrappedKey, "Secret should store a wrapped data key")
+		tc.Assert.NotEqual(first.WrappedKey, second.WrappedKey, "Data keys should be unique per secret")
+
+		_, err = crypto.Decrypt(first.Versions[0].SecretValueEnc, key)
+		tc.Assert.Error(err, "Values should not be encrypted with the vault key directly")
+
+		value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "SwappedDataKeyIsRejected", func(tc *helpers.UnitTestCase) {
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Special.Name, testdata.TestSecrets.Special.Value))
+
+		fileData, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		fileData.Secrets[1].WrappedKey = fileData.Secrets[0].WrappedKey
+		tc.Require.NoError(storage.WriteSecrets(fileData))
+
+		_, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Special.Name)
+		tc.Assert.Error(err, "Expected error unwrapping a data key moved from another secret")
+	})
+}
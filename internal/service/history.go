@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"go-password-manager/internal/domain"
+)
+
+// ErrHistoryUnsupported is returned when the storage does not keep a history of the vault
+var ErrHistoryUnsupported = errors.New("storage does not keep a history")
+
+// ErrCommitKeyRotated is returned when a commit predates a key rotation and its data keys can no longer be unwrapped
+var ErrCommitKeyRotated = errors.New("commit was made with a key that has since been rotated")
+
+// ErrReadOnly is returned when writing to a vault opened as of a past commit
+var ErrReadOnly = errors.New("vault is opened read-only")
+
+// HistoryKeeper is implemented by storages that record every write as a commit.
+// History lists the commits newest first; ReadSecretsAt returns the vault as of one of them.
+type HistoryKeeper interface {
+	History() ([]domain.Commit, error)
+	ReadSecretsAt(commit string) (domain.SecretsFile, error)
+}
+
+// readOnlyStorage serves a fixed copy of the vault
+type readOnlyStorage struct {
+	data domain.SecretsFile
+}
+
+func (r readOnlyStorage) ReadSecrets() (domain.SecretsFile, error) {
+	return r.data.Clone(), nil
+}
+
+func (r readOnlyStorage) WriteSecrets(domain.SecretsFile) error {
+	return ErrReadOnly
+}
+
+// History returns the commits of the vault, newest first
+func (s *SecretsService) History() ([]domain.Commit, error) {
+	history, ok := s.storage.(HistoryKeeper)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return history.History()
+}
+
+// VaultAt opens the vault as it was at a commit. The returned service reads with the current
+// key and refuses every change.
+func (s *SecretsService) VaultAt(commit string) (*SecretsService, error) {
+	history, ok := s.storage.(HistoryKeeper)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := history.ReadSecretsAt(commit)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretsService(s.crypto, readOnlyStorage{data: data}), nil
+}
@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	helpers.WithUnitTestCase(t, "UnsupportedStorage", func(tc *helpers.UnitTestCase) {
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), setupTestStorage(t))
+
+		_, err := svc.History()
+		tc.Assert.ErrorIs(err, service.ErrHistoryUnsupported)
+		_, err = svc.VaultAt("HEAD")
+		tc.Assert.ErrorIs(err, service.ErrHistoryUnsupported)
+	})
+
+	helpers.WithUnitTestCase(t, "OpensThePastReadOnly", func(tc *helpers.UnitTestCase) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git is not installed")
+		}
+		key := []byte(testdata.TestEncryptionKey)
+		store, err := storage.NewGitStorage(filepath.Join(t.TempDir(), "vault"), "1.0.0", testdata.TestUsers.UnitTestUser.Name, newMockCryptoService(key), false)
+		tc.Require.NoError(err)
+		svc := service.NewSecretsService(newMockCryptoService(key), store)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+
+		commits, err := svc.History()
+		tc.Require.NoError(err)
+		tc.Require.Len(commits, 2)
+		tc.Assert.Equal("update secret "+testdata.TestSecrets.Simple.Name+" to v2", commits[0].Message)
+
+		past, err := svc.VaultAt(commits[1].ID)
+		tc.Require.NoError(err)
+		value, err := past.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value1", value, secretValueShouldMatch)
+		tc.Assert.ErrorIs(past.UpdateSecret(testdata.TestSecrets.Simple.Name, "value3", ""), service.ErrReadOnly)
+
+		value, err = svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value2", value, "The current vault should be unchanged")
+	})
+}
@@ -0,0 +1,18 @@
+package service
+
+import "errors"
+
+// Errors returned by storages that verify the secrets file, so callers can tell the user
+// why the vault was not opened instead of silently using it.
+var (
+	ErrVaultTampered   = errors.New("secrets file failed its integrity check")
+	ErrVaultRolledBack = errors.New("secrets file is older than the last saved version")
+)
+
+// IntegrityProvider authenticates the secrets file and records its generation so
+// modified, truncated or rolled-back files can be detected.
+type IntegrityProvider interface {
+	MAC(data []byte) ([]byte, error)
+	Generation() (uint64, error)
+	SetGeneration(generation uint64) error
+}
@@ -7,6 +7,8 @@ type CryptoService interface {
 	GetKey() []byte
 	Encrypt(data, key []byte) ([]byte, error)
 	Decrypt(data, key []byte) ([]byte, error)
+	EncryptWithAD(data, key, ad []byte) ([]byte, error)
+	DecryptWithAD(data, key, ad []byte) ([]byte, error)
 }
 
 // StorageService defines the interface for secret persistence.
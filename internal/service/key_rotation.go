@@ -0,0 +1,171 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-password-manager/internal/logger"
+
+	"github.com/google/uuid"
+)
+
+// ErrKeyRotationUnsupported is returned when the crypto provider cannot manage keys
+var ErrKeyRotationUnsupported = errors.New("crypto provider does not support key rotation")
+
+// KeyManager defines the contract for creating, activating and retiring encryption keys.
+// Crypto providers that implement it can be used with RotateKey.
+type KeyManager interface {
+	ActiveKeyID() string
+	GenerateKey(keyID string) ([]byte, error)
+	LoadKey(keyID string) ([]byte, error)
+	ActivateKey(keyID string, key []byte)
+	RetireKey(keyID string) error
+}
+
+// KeyConfig defines the contract for persisting which key the vault uses.
+type KeyConfig interface {
+	GetKeyUUID() string
+	GetPendingKeyUUID() string
+	SetPendingKeyUUID(keyID string) error
+	SetKeyUUID(keyID string) error
+}
+
+// RotateKey replaces the vault key with a newly generated one and rewraps every secret's data key.
+// Secret values are encrypted with their data keys and are left untouched.
+//
+// The rotation is recorded in keyConfig before any data is touched and the secrets file is replaced
+// in a single write, so an interrupted rotation can be finished by calling RotateKey or
+// ResumeKeyRotation again. The old key file is only removed once the new key is committed.
+func (s *SecretsService) RotateKey(keyConfig KeyConfig) error {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	keys, ok := s.crypto.(KeyManager)
+	if !ok {
+		return ErrKeyRotationUnsupported
+	}
+
+	if err := s.resumeKeyRotation(keyConfig); err != nil {
+		return err
+	}
+
+	newKeyID := uuid.NewString()
+	newKey, err := keys.GenerateKey(newKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to generate new key: %w", err)
+	}
+	if err := keyConfig.SetPendingKeyUUID(newKeyID); err != nil {
+		_ = keys.RetireKey(newKeyID)
+		return fmt.Errorf("failed to record pending key: %w", err)
+	}
+
+	return s.rewrapWithKey(keys, keyConfig, newKeyID, newKey)
+}
+
+// ResumeKeyRotation finishes a key rotation that was interrupted. It is a no-op when none is pending.
+func (s *SecretsService) ResumeKeyRotation(keyConfig KeyConfig) error {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.resumeKeyRotation(keyConfig)
+}
+
+func (s *SecretsService) resumeKeyRotation(keyConfig KeyConfig) error {
+	pendingKeyID := keyConfig.GetPendingKeyUUID()
+	if pendingKeyID == "" {
+		return nil
+	}
+
+	keys, ok := s.crypto.(KeyManager)
+	if !ok {
+		return ErrKeyRotationUnsupported
+	}
+
+	logger.Info("Resuming interrupted key rotation")
+	oldKeyID := keys.ActiveKeyID()
+	oldKey := s.crypto.GetKey()
+	newKey, loadErr := keys.LoadKey(pendingKeyID)
+
+	secretsData, err := s.readSecrets()
+	if err != nil && loadErr == nil {
+		// A fully encrypted secrets file is sealed with the key it was last written with
+		keys.ActivateKey(pendingKeyID, newKey)
+		if secretsData, err = s.readSecrets(); err != nil {
+			keys.ActivateKey(oldKeyID, oldKey)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if loadErr != nil {
+		if secretsData.KeyID == pendingKeyID {
+			return fmt.Errorf("failed to load pending key: %w", loadErr)
+		}
+		// Nothing was written with the pending key yet, abandon the rotation
+		logger.Warn("Abandoning key rotation, pending key unavailable:", loadErr.Error())
+		return keyConfig.SetPendingKeyUUID("")
+	}
+
+	if secretsData.KeyID == pendingKeyID {
+		// The data keys were already rewrapped, only the commit is missing
+		keys.ActivateKey(pendingKeyID, newKey)
+		return s.commitKeyRotation(keys, keyConfig, oldKeyID, pendingKeyID)
+	}
+
+	return s.rewrapWithKey(keys, keyConfig, pendingKeyID, newKey)
+}
+
+// rewrapWithKey wraps every secret's data key with newKey, writes the secrets and commits the new key.
+// Secrets from older versions are first migrated to data keys so their values stay readable.
+func (s *SecretsService) rewrapWithKey(keys KeyManager, keyConfig KeyConfig, newKeyID string, newKey []byte) error {
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return err
+	}
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
+	}
+
+	oldKeyID := keys.ActiveKeyID()
+	oldKey := s.crypto.GetKey()
+
+	for i := range secretsData.Secrets {
+		secret := &secretsData.Secrets[i]
+		dataKey, err := s.dataKey(secret, oldKey)
+		if err != nil {
+			return err
+		}
+		if err := s.wrapDataKey(secret, dataKey, newKey); err != nil {
+			return err
+		}
+	}
+	secretsData.KeyID = newKeyID
+
+	keys.ActivateKey(newKeyID, newKey)
+	if err := s.writeSecrets(secretsData); err != nil {
+		// The old file is still intact, keep using the old key
+		keys.ActivateKey(oldKeyID, oldKey)
+		return fmt.Errorf("failed to write rewrapped secrets: %w", err)
+	}
+
+	return s.commitKeyRotation(keys, keyConfig, oldKeyID, newKeyID)
+}
+
+// commitKeyRotation points the configuration at the new key and removes the old key file
+func (s *SecretsService) commitKeyRotation(keys KeyManager, keyConfig KeyConfig, oldKeyID, newKeyID string) error {
+	if err := keyConfig.SetKeyUUID(newKeyID); err != nil {
+		return fmt.Errorf("failed to activate new key: %w", err)
+	}
+	if oldKeyID != "" && oldKeyID != newKeyID {
+		if err := keys.RetireKey(oldKeyID); err != nil {
+			logger.Warn("Failed to remove old key file:", err.Error())
+		}
+	}
+	logger.Info("Key rotation completed")
+	return nil
+}
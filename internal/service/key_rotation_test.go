@@ -0,0 +1,176 @@
+package service_test
+
+import (
+	"errors"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"testing"
+)
+
+// mockKeyManager is a crypto service that keeps its keys in memory and supports rotation
+type mockKeyManager struct {
+	mockCryptoService
+	activeKeyID string
+	keys        map[string][]byte
+	retired     []string
+}
+
+func newMockKeyManager() *mockKeyManager {
+	return &mockKeyManager{
+		mockCryptoService: mockCryptoService{key: []byte(testdata.TestEncryptionKey)},
+		activeKeyID:       "original-key",
+		keys:              map[string][]byte{"original-key": []byte(testdata.TestEncryptionKey)},
+	}
+}
+
+func (m *mockKeyManager) ActiveKeyID() string { return m.activeKeyID }
+
+func (m *mockKeyManager) GenerateKey(keyID string) ([]byte, error) {
+	m.keys[keyID] = []byte(testdata.DifferentEncryptionKey)
+	return m.keys[keyID], nil
+}
+
+func (m *mockKeyManager) LoadKey(keyID string) ([]byte, error) {
+	key, ok := m.keys[keyID]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return key, nil
+}
+
+func (m *mockKeyManager) ActivateKey(keyID string, key []byte) {
+	m.activeKeyID = keyID
+	m.key = key
+}
+
+func (m *mockKeyManager) RetireKey(keyID string) error {
+	delete(m.keys, keyID)
+	m.retired = append(m.retired, keyID)
+	return nil
+}
+
+// mockKeyConfig records the key UUIDs written by a rotation
+type mockKeyConfig struct {
+	keyUUID        string
+	pendingKeyUUID string
+}
+
+func (c *mockKeyConfig) GetKeyUUID() string        { return c.keyUUID }
+func (c *mockKeyConfig) GetPendingKeyUUID() string { return c.pendingKeyUUID }
+
+func (c *mockKeyConfig) SetPendingKeyUUID(keyID string) error {
+	c.pendingKeyUUID = keyID
+	return nil
+}
+
+func (c *mockKeyConfig) SetKeyUUID(keyID string) error {
+	c.keyUUID = keyID
+	c.pendingKeyUUID = ""
+	return nil
+}
+
+func TestRotateKey(t *testing.T) {
+	helpers.WithUnitTestCase(t, "RotateKeyReencryptsAllVersions", func(tc *helpers.UnitTestCase) {
+		keys := newMockKeyManager()
+		svc := service.NewSecretsService(keys, setupTestStorage(t))
+		keyConfig := &mockKeyConfig{keyUUID: "original-key"}
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+
+		tc.Require.NoError(svc.RotateKey(keyConfig), "Expected no error rotating key")
+
+		tc.Assert.NotEqual("original-key", keyConfig.keyUUID, "Config should point to the new key")
+		tc.Assert.Empty(keyConfig.pendingKeyUUID, "Pending key should be cleared")
+		tc.Assert.Equal(keyConfig.keyUUID, keys.ActiveKeyID(), "New key should be active")
+		tc.Assert.Equal([]string{"original-key"}, keys.retired, "Old key should be retired")
+
+		fileData, err := svc.LoadAllSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Assert.Equal(keyConfig.keyUUID, fileData.KeyID, "Secrets file should record the new key")
+
+		secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		value, err := svc.GetSecretValueByVersion(secret, 1)
+		tc.Require.NoError(err, "Old versions should decrypt with the new key")
+		tc.Assert.Equal("value1", value, secretValueShouldMatch)
+		value, err = svc.GetSecretValue(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value2", value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "RotateKeyOnlyRewrapsDataKeys", func(tc *helpers.UnitTestCase) {
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockKeyManager(), storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+		before, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+
+		tc.Require.NoError(svc.RotateKey(&mockKeyConfig{keyUUID: "original-key"}), "Expected no error rotating key")
+
+		after, err := storage.ReadSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Assert.NotEqual(before.Secrets[0].WrappedKey, after.Secrets[0].WrappedKey, "Data key should be rewrapped")
+		tc.Assert.Equal(before.Secrets[0].Versions, after.Secrets[0].Versions, "Values should not be re-encrypted")
+	})
+
+	helpers.WithUnitTestCase(t, "ResumeFinishesRotationAfterWrite", func(tc *helpers.UnitTestCase) {
+		keys := newMockKeyManager()
+		storage := setupTestStorage(t)
+		svc := service.NewSecretsService(keys, storage)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+
+		// Simulate a rotation that wrote the secrets file but crashed before updating the config
+		committed := &mockKeyConfig{keyUUID: "original-key"}
+		tc.Require.NoError(svc.RotateKey(committed))
+		newKeyID := committed.keyUUID
+
+		keys.keys["original-key"] = []byte(testdata.TestEncryptionKey)
+		keys.ActivateKey("original-key", []byte(testdata.TestEncryptionKey))
+		keys.retired = nil
+		interrupted := &mockKeyConfig{keyUUID: "original-key", pendingKeyUUID: newKeyID}
+
+		tc.Require.NoError(svc.ResumeKeyRotation(interrupted), "Expected no error resuming rotation")
+		tc.Assert.Equal(newKeyID, interrupted.keyUUID, "Resume should commit the pending key")
+		tc.Assert.Equal(newKeyID, keys.ActiveKeyID())
+
+		value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "ResumeOpensContainerSealedWithPendingKey", func(tc *helpers.UnitTestCase) {
+		keys := newMockKeyManager()
+		encrypted := storage.NewEncryptedFileStorage(filepath.Join(t.TempDir(), testSecretsFile), "1.0.0", testdata.TestUsers.UnitTestUser.Name, keys)
+		svc := service.NewSecretsService(keys, encrypted)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, testdata.TestSecrets.Simple.Value))
+
+		committed := &mockKeyConfig{keyUUID: "original-key"}
+		tc.Require.NoError(svc.RotateKey(committed))
+		newKeyID := committed.keyUUID
+
+		// The container is now sealed with the new key, but the config still names the old one
+		keys.keys["original-key"] = []byte(testdata.TestEncryptionKey)
+		keys.ActivateKey("original-key", []byte(testdata.TestEncryptionKey))
+		interrupted := &mockKeyConfig{keyUUID: "original-key", pendingKeyUUID: newKeyID}
+
+		tc.Require.NoError(svc.ResumeKeyRotation(interrupted), "Expected no error resuming rotation")
+		tc.Assert.Equal(newKeyID, interrupted.keyUUID, "Resume should commit the pending key")
+
+		value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Value, value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "RotateKeyUnsupported", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		err := svc.RotateKey(&mockKeyConfig{keyUUID: "original-key"})
+		tc.Assert.ErrorIs(err, service.ErrKeyRotationUnsupported)
+	})
+}
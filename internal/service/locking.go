@@ -0,0 +1,34 @@
+package service
+
+import "errors"
+
+// ErrVaultLocked is returned when another process holds the vault lock past the wait timeout
+var ErrVaultLocked = errors.New("vault is locked")
+
+// Locker is implemented by storages that can serialize access to the vault across processes.
+// Lock takes an exclusive lock for a read-modify-write cycle and returns the function that releases it.
+type Locker interface {
+	Lock() (unlock func(), err error)
+}
+
+// lockWrite serializes a read-modify-write cycle within this process and, through the storage, across processes
+func (s *SecretsService) lockWrite() (func(), error) {
+	s.mu.Lock()
+	unlockStorage, err := s.lockForWrite()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockStorage()
+		s.mu.Unlock()
+	}, nil
+}
+
+// lockForWrite takes the storage's exclusive lock, if it has one
+func (s *SecretsService) lockForWrite() (func(), error) {
+	if locker, ok := s.storage.(Locker); ok {
+		return locker.Lock()
+	}
+	return func() {}, nil
+}
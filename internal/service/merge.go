@@ -0,0 +1,121 @@
+package service
+
+import (
+	"go-password-manager/internal/domain"
+	"reflect"
+	"sort"
+)
+
+// Resolution picks the side that wins a sync conflict
+type Resolution string
+
+const (
+	ResolveLocal  Resolution = "local"
+	ResolveRemote Resolution = "remote"
+)
+
+// mergeSecrets merges the secrets of local and remote that changed since base. Secrets changed on
+// one side only take that side; secrets changed on both are merged version by version, and are
+// conflicts when the same version, the current version or the metadata differs. Conflicts listed
+// in resolutions are settled, the others are returned and left out of the result.
+func mergeSecrets(base, local, remote []domain.Secret, resolutions map[string]Resolution) ([]domain.Secret, []domain.SyncConflict) {
+	baseByName, localByName, remoteByName := secretsByName(base), secretsByName(local), secretsByName(remote)
+
+	// Local order first, then secrets only the remote has
+	var names []string
+	for _, secret := range local {
+		names = append(names, secret.SecretName)
+	}
+	for _, secret := range remote {
+		if _, ok := localByName[secret.SecretName]; !ok {
+			names = append(names, secret.SecretName)
+		}
+	}
+
+	var merged []domain.Secret
+	var conflicts []domain.SyncConflict
+	for _, name := range names {
+		l, r := localByName[name], remoteByName[name]
+		secret, ok := mergeSecret(baseByName[name], l, r)
+		if !ok {
+			switch resolutions[name] {
+			case ResolveLocal:
+				secret = l
+			case ResolveRemote:
+				secret = r
+			default:
+				conflicts = append(conflicts, domain.SyncConflict{Name: name, Local: l, Remote: r})
+				continue
+			}
+		}
+		if secret != nil {
+			merged = append(merged, secret.Clone())
+		}
+	}
+	return merged, conflicts
+}
+
+// mergeSecret merges one secret, nil meaning it does not exist on that side. ok is false on a conflict.
+func mergeSecret(base, local, remote *domain.Secret) (merged *domain.Secret, ok bool) {
+	switch {
+	case reflect.DeepEqual(local, remote):
+		return local, true
+	case reflect.DeepEqual(base, local):
+		return remote, true
+	case reflect.DeepEqual(base, remote):
+		return local, true
+	case base == nil || local == nil || remote == nil:
+		// Created on both sides, or deleted on one and changed on the other
+		return nil, false
+	}
+
+	result := *local
+	result.Versions = nil
+	other := *remote
+	other.Versions = nil
+	common := *base
+	common.Versions = nil
+
+	// Everything but the versions, the current one included, must have changed on one side at most
+	result.CurrentVersion, other.CurrentVersion, common.CurrentVersion = 0, 0, 0
+	switch {
+	case reflect.DeepEqual(result, other), reflect.DeepEqual(common, other):
+	case reflect.DeepEqual(common, result):
+		result = other
+	default:
+		return nil, false
+	}
+	switch {
+	case local.CurrentVersion == remote.CurrentVersion, remote.CurrentVersion == base.CurrentVersion:
+		result.CurrentVersion = local.CurrentVersion
+	case local.CurrentVersion == base.CurrentVersion:
+		result.CurrentVersion = remote.CurrentVersion
+	default:
+		return nil, false
+	}
+
+	// Versions are never edited, so one numbered the same on both sides must be the same version
+	versions := map[int]domain.SecretVersion{}
+	for _, version := range local.Versions {
+		versions[version.Version] = version
+	}
+	for _, version := range remote.Versions {
+		if existing, found := versions[version.Version]; found && !reflect.DeepEqual(existing, version) {
+			return nil, false
+		}
+		versions[version.Version] = version
+	}
+	for _, version := range versions {
+		result.Versions = append(result.Versions, version)
+	}
+	sort.Slice(result.Versions, func(i, j int) bool { return result.Versions[i].Version < result.Versions[j].Version })
+	return &result, true
+}
+
+func secretsByName(secrets []domain.Secret) map[string]*domain.Secret {
+	byName := make(map[string]*domain.Secret, len(secrets))
+	for i := range secrets {
+		byName[secrets[i].SecretName] = &secrets[i]
+	}
+	return byName
+}
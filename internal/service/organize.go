@@ -0,0 +1,263 @@
+package service
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// NormalizeFolder cleans a folder path, so "/aws//prod/" becomes "aws/prod". Empty is the top level.
+func NormalizeFolder(folder string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "":
+			continue
+		case ".", "..":
+			return "", fmt.Errorf("%w: folder %q may not contain . or ..", ErrInvalidSecret, folder)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// inFolder reports whether folder is the given folder or one below it
+func inFolder(folder, parent string) bool {
+	return parent == "" || folder == parent || strings.HasPrefix(folder, parent+"/")
+}
+
+// normalizeTags trims, deduplicates and sorts tags. Tags cannot contain spaces since they are searched as tag:name.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.ContainsFunc(tag, unicode.IsSpace) {
+			return nil, fmt.Errorf("%w: tag %q may not contain spaces", ErrInvalidSecret, tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// modifySecrets applies change to the vault and writes it if change reports that something changed
+func (s *SecretsService) modifySecrets(change func(secrets []domain.Secret) (bool, error)) error {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return err
+	}
+	changed, err := change(secretsData.Secrets)
+	if err != nil || !changed {
+		return err
+	}
+	return s.writeSecrets(secretsData)
+}
+
+// modifySecret applies change to the named secret and writes the vault
+func (s *SecretsService) modifySecret(name string, change func(secret *domain.Secret)) error {
+	return s.modifySecrets(func(secrets []domain.Secret) (bool, error) {
+		for i := range secrets {
+			if secrets[i].SecretName == name && !secrets[i].Trashed() {
+				change(&secrets[i])
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("secret '%s' not found", name)
+	})
+}
+
+// SetSecretFolder moves the named secret into folder, see NormalizeFolder
+func (s *SecretsService) SetSecretFolder(name, folder string) error {
+	folder, err := NormalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+	return s.modifySecret(name, func(secret *domain.Secret) {
+		secret.Folder = folder
+	})
+}
+
+// SetSecretTags replaces the tags of the named secret
+func (s *SecretsService) SetSecretTags(name string, tags []string) error {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	return s.modifySecret(name, func(secret *domain.Secret) {
+		secret.Tags = tags
+	})
+}
+
+// RenameFolder moves every secret in folder, and in the folders below it, to newFolder
+func (s *SecretsService) RenameFolder(folder, newFolder string) error {
+	folder, err := NormalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+	if newFolder, err = NormalizeFolder(newFolder); err != nil {
+		return err
+	}
+	if folder == "" {
+		return fmt.Errorf("%w: the top level cannot be renamed", ErrInvalidSecret)
+	}
+
+	return s.modifySecrets(func(secrets []domain.Secret) (bool, error) {
+		changed := false
+		for i := range secrets {
+			if inFolder(secrets[i].Folder, folder) {
+				secrets[i].Folder = strings.TrimPrefix(newFolder+strings.TrimPrefix(secrets[i].Folder, folder), "/")
+				changed = true
+			}
+		}
+		if !changed {
+			return false, fmt.Errorf("folder '%s' not found", folder)
+		}
+		return true, nil
+	})
+}
+
+// RenameTag replaces tag with newTag on every secret carrying it
+func (s *SecretsService) RenameTag(tag, newTag string) error {
+	renamed, err := normalizeTags([]string{newTag})
+	if err != nil {
+		return err
+	}
+	if len(renamed) == 0 {
+		return fmt.Errorf("%w: tag name cannot be empty", ErrInvalidSecret)
+	}
+
+	return s.modifySecrets(func(secrets []domain.Secret) (bool, error) {
+		changed := false
+		for i := range secrets {
+			if j := slices.Index(secrets[i].Tags, tag); j >= 0 {
+				tags := slices.Clone(secrets[i].Tags)
+				tags[j] = renamed[0]
+				// Renaming to a tag the secret already has merges the two
+				secrets[i].Tags, _ = normalizeTags(tags)
+				changed = true
+			}
+		}
+		if !changed {
+			return false, fmt.Errorf("tag '%s' not found", tag)
+		}
+		return true, nil
+	})
+}
+
+// Folders returns every folder in use, parents included, sorted
+func (s *SecretsService) Folders() ([]string, error) {
+	secrets, err := s.LoadAllSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return FoldersOf(secrets.Secrets), nil
+}
+
+// FoldersOf returns the folders the secrets are in, parents included, sorted
+func FoldersOf(secrets []domain.Secret) []string {
+	var folders []string
+	for _, secret := range secrets {
+		for folder := secret.Folder; folder != ""; {
+			if !slices.Contains(folders, folder) {
+				folders = append(folders, folder)
+			}
+			i := strings.LastIndex(folder, "/")
+			if i < 0 {
+				break
+			}
+			folder = folder[:i]
+		}
+	}
+	slices.Sort(folders)
+	return folders
+}
+
+// Tags returns every tag in use, sorted
+func (s *SecretsService) Tags() ([]string, error) {
+	secrets, err := s.LoadAllSecrets()
+	if err != nil {
+		return nil, err
+	}
+	return TagsOf(secrets.Secrets), nil
+}
+
+// TagsOf returns the tags the secrets carry, sorted
+func TagsOf(secrets []domain.Secret) []string {
+	var tags []string
+	for _, secret := range secrets {
+		for _, tag := range secret.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// SecretQuery filters secrets by what is typed in the search box: tag:name and folder:path terms,
+// and text the secret name must contain. All terms must match.
+type SecretQuery struct {
+	Text      []string
+	Tags      []string
+	Folder    string
+	HasFolder bool // Folder is set, empty meaning the top level only
+}
+
+// ParseSecretQuery splits a search like "tag:prod folder:aws/ db" into its terms
+func ParseSecretQuery(query string) SecretQuery {
+	var q SecretQuery
+	for _, term := range strings.Fields(query) {
+		switch {
+		case strings.HasPrefix(term, "tag:"):
+			q.Tags = append(q.Tags, strings.TrimPrefix(term, "tag:"))
+		case strings.HasPrefix(term, "folder:"):
+			// A bad folder cannot match anything, so it is kept as typed
+			folder, err := NormalizeFolder(strings.TrimPrefix(term, "folder:"))
+			if err != nil {
+				folder = term
+			}
+			q.Folder, q.HasFolder = folder, true
+		default:
+			q.Text = append(q.Text, strings.ToLower(term))
+		}
+	}
+	return q
+}
+
+// Matches reports whether secret matches every term of the query. A folder term matches the folders below it too,
+// except folder:/ which matches the top level only.
+func (q SecretQuery) Matches(secret domain.Secret) bool {
+	name := strings.ToLower(secret.SecretName)
+	for _, text := range q.Text {
+		if !strings.Contains(name, text) {
+			return false
+		}
+	}
+	for _, tag := range q.Tags {
+		if !slices.ContainsFunc(secret.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			return false
+		}
+	}
+	if q.HasFolder {
+		if q.Folder == "" {
+			return secret.Folder == ""
+		}
+		return inFolder(secret.Folder, q.Folder)
+	}
+	return true
+}
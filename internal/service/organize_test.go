@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"testing"
+)
+
+func TestOrganize(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Assigns folders and tags", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		for _, name := range []string{"db", "bucket", "laptop"} {
+			tc.Require.NoError(svc.SaveNewSecret(name, "value"), errCreateSecret)
+		}
+		tc.Require.NoError(svc.SetSecretFolder("db", "/aws//prod/"))
+		tc.Require.NoError(svc.SetSecretFolder("bucket", "aws/staging"))
+		tc.Require.NoError(svc.SetSecretTags("db", []string{"prod", " db ", "prod"}))
+		tc.Require.NoError(svc.SetSecretTags("bucket", []string{"staging"}))
+
+		secret, err := svc.GetSecret("db")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal("aws/prod", secret.Folder)
+		tc.Assert.Equal([]string{"db", "prod"}, secret.Tags)
+
+		folders, err := svc.Folders()
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{"aws", "aws/prod", "aws/staging"}, folders)
+		tags, err := svc.Tags()
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{"db", "prod", "staging"}, tags)
+
+		tc.Assert.ErrorIs(svc.SetSecretFolder("db", "aws/../etc"), service.ErrInvalidSecret)
+		tc.Assert.ErrorIs(svc.SetSecretTags("db", []string{"two words"}), service.ErrInvalidSecret)
+		tc.Assert.Error(svc.SetSecretFolder(nonExistentName, "aws"))
+	})
+
+	helpers.WithUnitTestCase(t, "Renames folders and tags", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		for _, name := range []string{"db", "bucket", "other"} {
+			tc.Require.NoError(svc.SaveNewSecret(name, "value"), errCreateSecret)
+		}
+		tc.Require.NoError(svc.SetSecretFolder("db", "aws/prod"))
+		tc.Require.NoError(svc.SetSecretFolder("bucket", "aws"))
+		tc.Require.NoError(svc.SetSecretFolder("other", "awsome"))
+		tc.Require.NoError(svc.SetSecretTags("db", []string{"prod", "production"}))
+
+		tc.Require.NoError(svc.RenameFolder("aws", "cloud/amazon"))
+		tc.Require.NoError(svc.RenameTag("production", "prod"))
+
+		folders, err := svc.Folders()
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{"awsome", "cloud", "cloud/amazon", "cloud/amazon/prod"}, folders)
+		secret, err := svc.GetSecret("db")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal([]string{"prod"}, secret.Tags, "Renaming onto an existing tag merges them")
+
+		tc.Assert.Error(svc.RenameFolder("missing", "x"))
+		tc.Assert.Error(svc.RenameTag("missing", "x"))
+	})
+
+	helpers.WithUnitTestCase(t, "Parses search filters", func(tc *helpers.UnitTestCase) {
+		db := domain.Secret{SecretName: "Prod-DB", Folder: "aws/prod", Tags: []string{"prod", "db"}}
+		root := domain.Secret{SecretName: "wifi"}
+
+		cases := []struct {
+			query string
+			db    bool
+			root  bool
+		}{
+			{"", true, true},
+			{"db", true, false},
+			{"tag:prod", true, false},
+			{"tag:PROD tag:db prod", true, false},
+			{"tag:prod tag:other", false, false},
+			{"folder:aws/", true, false},
+			{"folder:aws/prod db", true, false},
+			{"folder:aw", false, false},
+			{"folder:/", false, true},
+		}
+		for _, c := range cases {
+			query := service.ParseSecretQuery(c.query)
+			tc.Assert.Equal(c.db, query.Matches(db), "%q on %s", c.query, db.SecretName)
+			tc.Assert.Equal(c.root, query.Matches(root), "%q on %s", c.query, root.SecretName)
+		}
+	})
+}
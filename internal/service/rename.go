@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"strings"
+)
+
+// RenameSecret gives the named secret a new name and keeps its versions. Versions are sealed with
+// the name, so each is re-encrypted, and a version recording the old name is added.
+func (s *SecretsService) RenameSecret(name, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("%w: the name cannot be empty", ErrInvalidSecret)
+	}
+	if newName == name {
+		return nil
+	}
+
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return err
+	}
+	index := activeSecretIndex(secretsData.Secrets, name)
+	if index < 0 {
+		return fmt.Errorf("secret '%s' not found", name)
+	}
+	if err := checkNameFree(secretsData.Secrets, newName); err != nil {
+		return err
+	}
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
+	}
+
+	source := &secretsData.Secrets[index]
+	renamed := source.Clone()
+	renamed.SecretName = newName
+	dataKey, err := s.dataKey(source, s.crypto.GetKey())
+	if err != nil {
+		return err
+	}
+	if err := s.wrapDataKey(&renamed, dataKey, s.crypto.GetKey()); err != nil {
+		return err
+	}
+	if err := s.resealVersions(source, &renamed); err != nil {
+		return err
+	}
+
+	spec, err := LookupSecretType(renamed.Type)
+	if err != nil {
+		return err
+	}
+	var content secretContent
+	if current := renamed.GetCurrentVersion(); current != nil {
+		if content, err = s.versionContent(&renamed, current); err != nil {
+			return err
+		}
+	}
+	version, err := s.sealVersion(&renamed, spec, renamed.NextVersion(), content)
+	if err != nil {
+		return err
+	}
+	version.RenamedFrom = name
+	renamed.Versions = append(renamed.Versions, version)
+	renamed.CurrentVersion = version.Version
+
+	secretsData.Secrets[index] = renamed
+	return s.writeSecrets(secretsData)
+}
+
+// DuplicateSecret copies the named secret with all its versions, folder, tags and attachments
+// to a new secret. The copy gets its own data key, so the two are independent from then on.
+func (s *SecretsService) DuplicateSecret(name, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return fmt.Errorf("%w: the name cannot be empty", ErrInvalidSecret)
+	}
+
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return err
+	}
+	index := activeSecretIndex(secretsData.Secrets, name)
+	if index < 0 {
+		return fmt.Errorf("secret '%s' not found", name)
+	}
+	if err := checkNameFree(secretsData.Secrets, newName); err != nil {
+		return err
+	}
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
+	}
+
+	source := &secretsData.Secrets[index]
+	duplicate := source.Clone()
+	duplicate.SecretName = newName
+	if _, err := s.newDataKey(&duplicate, s.crypto.GetKey()); err != nil {
+		return err
+	}
+	if err := s.resealVersions(source, &duplicate); err != nil {
+		return err
+	}
+	copied, err := s.copyAttachments(source, &duplicate)
+	if err != nil {
+		return err
+	}
+
+	secretsData.Secrets = append(secretsData.Secrets, duplicate)
+	if err := s.writeSecrets(secretsData); err != nil {
+		// Nothing references the copies if the duplicate was not written
+		s.deleteAttachments(domain.Secret{Versions: []domain.SecretVersion{{Attachments: copied}}})
+		return err
+	}
+	return nil
+}
+
+// activeSecretIndex returns the index of the named secret that is not in the trash, or -1
+func activeSecretIndex(secrets []domain.Secret, name string) int {
+	for i := range secrets {
+		if secrets[i].SecretName == name && !secrets[i].Trashed() {
+			return i
+		}
+	}
+	return -1
+}
+
+// resealVersions re-encrypts every version of source for target, a clone of it with another name
+// or data key. Version numbers, dates and attachment references are kept.
+func (s *SecretsService) resealVersions(source, target *domain.Secret) error {
+	spec, err := LookupSecretType(source.Type)
+	if err != nil {
+		return err
+	}
+	for i := range source.Versions {
+		version := &source.Versions[i]
+		content, err := s.versionContent(source, version)
+		if err != nil {
+			return err
+		}
+		sealed, err := s.sealVersion(target, spec, version.Version, content)
+		if err != nil {
+			return err
+		}
+		target.Versions[i].SecretValueEnc = sealed.SecretValueEnc
+		target.Versions[i].Fields = sealed.Fields
+		target.Versions[i].CustomFields = sealed.CustomFields
+	}
+	return nil
+}
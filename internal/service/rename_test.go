@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"testing"
+)
+
+func TestRenameAndDuplicate(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Renames a secret with its history", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewTypedSecret("db", domain.SecretTypeLogin, map[string]string{
+			domain.FieldUsername: "admin",
+			domain.FieldPassword: "first",
+		}), errCreateSecret)
+		tc.Require.NoError(svc.AddCustomField("db", domain.CustomField{Label: "port", Value: "5432"}))
+		tc.Require.NoError(svc.SetSecretFolder("db", "aws"))
+		attachment, err := svc.AddAttachment("db", "ca.pem", "", []byte("certificate"))
+		tc.Require.NoError(err)
+
+		tc.Require.NoError(svc.RenameSecret("db", " prod-db "))
+		_, err = svc.GetSecret("db")
+		tc.Assert.Error(err, "The old name is gone")
+
+		secret, err := svc.GetSecret("prod-db")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal("aws", secret.Folder)
+		tc.Assert.Equal(4, secret.CurrentVersion, "The rename is recorded as a version")
+		tc.Assert.Equal("db", secret.GetCurrentVersion().RenamedFrom)
+		old, err := svc.GetSecretFieldsByVersion(secret, 1)
+		tc.Require.NoError(err, "Older versions still open under the new name")
+		tc.Assert.Equal("first", old[domain.FieldPassword])
+		custom, err := svc.GetCustomFields(secret)
+		tc.Require.NoError(err)
+		tc.Assert.Equal("5432", custom[0].Value)
+		data, err := svc.ExtractAttachment(secret, attachment.ID)
+		tc.Require.NoError(err)
+		tc.Assert.Equal("certificate", string(data))
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects renames onto taken names", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		for _, name := range []string{"first", "second", "deleted"} {
+			tc.Require.NoError(svc.SaveNewSecret(name, "value"), errCreateSecret)
+		}
+		tc.Require.NoError(svc.DeleteSecret("deleted"))
+
+		tc.Assert.ErrorContains(svc.RenameSecret("first", "second"), "already exists")
+		tc.Assert.ErrorContains(svc.RenameSecret("first", "deleted"), "in the trash")
+		tc.Assert.ErrorIs(svc.RenameSecret("first", " "), service.ErrInvalidSecret)
+		tc.Assert.Error(svc.RenameSecret(nonExistentName, "third"))
+		tc.Assert.Error(svc.RenameSecret("deleted", "third"), "Secrets in the trash are restored before renaming")
+		tc.Assert.ErrorContains(svc.DuplicateSecret("first", "second"), "already exists")
+		tc.Assert.NoError(svc.RenameSecret("first", "first"), "Keeping the name changes nothing")
+	})
+
+	helpers.WithUnitTestCase(t, "Duplicates a secret with its history", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewSecret("token", "value1"), errCreateSecret)
+		tc.Require.NoError(svc.UpdateSecret("token", "value2", ""))
+		tc.Require.NoError(svc.SetSecretTags("token", []string{"ci"}))
+		attachment, err := svc.AddAttachment("token", "notes.txt", "", []byte("rotate monthly"))
+		tc.Require.NoError(err)
+
+		tc.Require.NoError(svc.DuplicateSecret("token", "token-staging"))
+		original, err := svc.GetSecret("token")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		duplicate, err := svc.GetSecret("token-staging")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Len(duplicate.Versions, len(original.Versions))
+		tc.Assert.Equal([]string{"ci"}, duplicate.Tags)
+		tc.Assert.NotEqual(original.WrappedKey, duplicate.WrappedKey, "The copy has its own data key")
+		value, err := svc.GetSecretValueByVersion(duplicate, 1)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value1", value)
+
+		copied := duplicate.GetCurrentVersion().Attachments
+		tc.Require.Len(copied, 1)
+		tc.Assert.NotEqual(attachment.ID, copied[0].ID, "The copy has its own attachments")
+		tc.Require.NoError(svc.DeleteSecret("token"))
+		tc.Require.NoError(svc.PurgeSecret("token"))
+		data, err := svc.ExtractAttachment(duplicate, copied[0].ID)
+		tc.Require.NoError(err, "Purging the original keeps the attachments of the copy")
+		tc.Assert.Equal("rotate monthly", string(data))
+	})
+}
@@ -0,0 +1,6 @@
+package service
+
+import "errors"
+
+// ErrSchemaTooNew is returned when the secrets file was written by a newer version of the password manager
+var ErrSchemaTooNew = errors.New("secrets file was written by a newer version of the password manager")
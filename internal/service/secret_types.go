@@ -0,0 +1,299 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrInvalidSecret is returned when the fields of a secret do not fit its type
+var ErrInvalidSecret = errors.New("invalid secret")
+
+// FieldSpec describes one named field of a secret type
+type FieldSpec struct {
+	Name      string
+	Label     string
+	Required  bool
+	Sensitive bool // Hidden until revealed
+	Multiline bool
+}
+
+// SecretTypeSpec describes a secret type: its fields, the one used as the secret's value,
+// and the checks its fields must pass beyond being required.
+type SecretTypeSpec struct {
+	Type     domain.SecretType
+	Label    string
+	Fields   []FieldSpec
+	Primary  string
+	Validate func(fields map[string]string) error
+}
+
+// Structured reports whether the type keeps its fields encrypted one by one rather than a single value
+func (t SecretTypeSpec) Structured() bool {
+	return len(t.Fields) != 1 || t.Fields[0].Name != domain.FieldValue
+}
+
+// Field returns the spec of the named field
+func (t SecretTypeSpec) Field(name string) (FieldSpec, bool) {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FieldSpec{}, false
+}
+
+// check validates fields against the type and drops empty optional ones
+func (t SecretTypeSpec) check(fields map[string]string) (map[string]string, error) {
+	checked := make(map[string]string, len(fields))
+	for name, value := range fields {
+		if _, ok := t.Field(name); !ok {
+			return nil, fmt.Errorf("%w: %s has no field '%s'", ErrInvalidSecret, t.Label, name)
+		}
+		if value != "" {
+			checked[name] = value
+		}
+	}
+	for _, field := range t.Fields {
+		if field.Required && strings.TrimSpace(checked[field.Name]) == "" {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidSecret, field.Label)
+		}
+	}
+	if t.Validate != nil {
+		if err := t.Validate(checked); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSecret, err.Error())
+		}
+	}
+	if !t.Structured() {
+		// A single value is kept even when empty
+		checked[domain.FieldValue] = fields[domain.FieldValue]
+	}
+	return checked, nil
+}
+
+var (
+	secretTypesMu    sync.RWMutex
+	secretTypes      = map[domain.SecretType]SecretTypeSpec{}
+	secretTypesOrder []domain.SecretType
+)
+
+func init() {
+	valueField := []FieldSpec{{Name: domain.FieldValue, Label: "Value", Sensitive: true}}
+	notesField := FieldSpec{Name: domain.FieldNotes, Label: "Notes", Multiline: true}
+
+	mustRegisterSecretType(SecretTypeSpec{Type: domain.SecretTypeKeyValue, Label: "Key/Value", Fields: valueField, Primary: domain.FieldValue})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeLogin,
+		Label:   "Login",
+		Primary: domain.FieldPassword,
+		Fields: []FieldSpec{
+			{Name: domain.FieldUsername, Label: "Username"},
+			{Name: domain.FieldPassword, Label: "Password", Required: true, Sensitive: true},
+			{Name: domain.FieldURL, Label: "URL"},
+			notesField,
+		},
+		Validate: validateLogin,
+	})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeCreditCard,
+		Label:   "Credit Card",
+		Primary: domain.FieldCardNumber,
+		Fields: []FieldSpec{
+			{Name: domain.FieldCardholder, Label: "Cardholder"},
+			{Name: domain.FieldCardNumber, Label: "Card Number", Required: true, Sensitive: true},
+			{Name: domain.FieldExpiry, Label: "Expiry (MM/YY)", Required: true},
+			{Name: domain.FieldCVV, Label: "CVV", Sensitive: true},
+			notesField,
+		},
+		Validate: validateCreditCard,
+	})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeSSHKey,
+		Label:   "SSH Key",
+		Primary: domain.FieldPrivateKey,
+		Fields: []FieldSpec{
+			{Name: domain.FieldPrivateKey, Label: "Private Key", Required: true, Sensitive: true, Multiline: true},
+			{Name: domain.FieldPublicKey, Label: "Public Key", Multiline: true},
+			{Name: domain.FieldPassphrase, Label: "Passphrase", Sensitive: true},
+			notesField,
+		},
+		Validate: validateSSHKey,
+	})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeSecureNote,
+		Label:   "Secure Note",
+		Primary: domain.FieldNote,
+		Fields:  []FieldSpec{{Name: domain.FieldNote, Label: "Note", Required: true, Sensitive: true, Multiline: true}},
+	})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeAPIToken,
+		Label:   "API Token",
+		Primary: domain.FieldToken,
+		Fields: []FieldSpec{
+			{Name: domain.FieldToken, Label: "Token", Required: true, Sensitive: true},
+			{Name: domain.FieldScopes, Label: "Scopes"},
+			{Name: domain.FieldURL, Label: "URL"},
+			notesField,
+		},
+		Validate: func(fields map[string]string) error { return validateURL(fields[domain.FieldURL]) },
+	})
+	mustRegisterSecretType(SecretTypeSpec{
+		Type:    domain.SecretTypeJSON,
+		Label:   "JSON",
+		Fields:  valueField,
+		Primary: domain.FieldValue,
+		Validate: func(fields map[string]string) error {
+			if !json.Valid([]byte(fields[domain.FieldValue])) {
+				return errors.New("value is not valid JSON")
+			}
+			return nil
+		},
+	})
+	mustRegisterSecretType(SecretTypeSpec{Type: domain.SecretTypeOther, Label: "Other", Fields: valueField, Primary: domain.FieldValue})
+}
+
+// RegisterSecretType adds a secret type to the registry
+func RegisterSecretType(t SecretTypeSpec) error {
+	if t.Type == "" || len(t.Fields) == 0 {
+		return fmt.Errorf("invalid secret type definition")
+	}
+	if _, ok := t.Field(t.Primary); !ok {
+		return fmt.Errorf("secret type %s has no primary field %q", t.Type, t.Primary)
+	}
+
+	secretTypesMu.Lock()
+	defer secretTypesMu.Unlock()
+	if _, ok := secretTypes[t.Type]; ok {
+		return fmt.Errorf("secret type %s already registered", t.Type)
+	}
+	secretTypes[t.Type] = t
+	secretTypesOrder = append(secretTypesOrder, t.Type)
+	return nil
+}
+
+func mustRegisterSecretType(t SecretTypeSpec) {
+	if err := RegisterSecretType(t); err != nil {
+		panic(err)
+	}
+}
+
+// LookupSecretType returns the registered secret type. Secrets written before types were
+// recorded have no type and hold a single value.
+func LookupSecretType(secretType domain.SecretType) (SecretTypeSpec, error) {
+	if secretType == "" {
+		secretType = domain.SecretTypeKeyValue
+	}
+	secretTypesMu.RLock()
+	defer secretTypesMu.RUnlock()
+	t, ok := secretTypes[secretType]
+	if !ok {
+		return SecretTypeSpec{}, fmt.Errorf("unsupported secret type: %s", secretType)
+	}
+	return t, nil
+}
+
+// SecretTypes returns the registered secret types in registration order
+func SecretTypes() []SecretTypeSpec {
+	secretTypesMu.RLock()
+	defer secretTypesMu.RUnlock()
+	types := make([]SecretTypeSpec, 0, len(secretTypesOrder))
+	for _, secretType := range secretTypesOrder {
+		types = append(types, secretTypes[secretType])
+	}
+	return types
+}
+
+func validateURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("URL %q must be absolute, like https://example.com", raw)
+	}
+	return nil
+}
+
+func validateLogin(fields map[string]string) error {
+	return validateURL(fields[domain.FieldURL])
+}
+
+func validateCreditCard(fields map[string]string) error {
+	number := strings.NewReplacer(" ", "", "-", "").Replace(fields[domain.FieldCardNumber])
+	if len(number) < 12 || len(number) > 19 || strings.Trim(number, "0123456789") != "" {
+		return errors.New("card number must be 12 to 19 digits")
+	}
+	if !luhnValid(number) {
+		return errors.New("card number fails its checksum")
+	}
+
+	month, year, found := strings.Cut(fields[domain.FieldExpiry], "/")
+	m, err := strconv.Atoi(month)
+	if !found || err != nil || m < 1 || m > 12 || (len(year) != 2 && len(year) != 4) || strings.Trim(year, "0123456789") != "" {
+		return errors.New("expiry must be MM/YY")
+	}
+
+	if cvv := fields[domain.FieldCVV]; cvv != "" && (len(cvv) < 3 || len(cvv) > 4 || strings.Trim(cvv, "0123456789") != "") {
+		return errors.New("CVV must be 3 or 4 digits")
+	}
+	return nil
+}
+
+// luhnValid checks the Luhn checksum every card number carries in its last digit
+func luhnValid(number string) bool {
+	sum := 0
+	for i := range number {
+		digit := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+func validateSSHKey(fields map[string]string) error {
+	privateKey := []byte(fields[domain.FieldPrivateKey])
+	key, err := ssh.ParseRawPrivateKey(privateKey)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing) && fields[domain.FieldPassphrase] != "":
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(privateKey, []byte(fields[domain.FieldPassphrase]))
+		if err != nil {
+			return fmt.Errorf("private key does not open with the passphrase: %s", err.Error())
+		}
+	case errors.As(err, &missing):
+		// Kept encrypted without its passphrase, so it cannot be checked further
+	case err != nil:
+		return fmt.Errorf("private key is not a valid SSH key: %s", err.Error())
+	}
+
+	if fields[domain.FieldPublicKey] == "" {
+		return nil
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(fields[domain.FieldPublicKey]))
+	if err != nil {
+		return fmt.Errorf("public key is not a valid SSH key: %s", err.Error())
+	}
+	if key == nil {
+		return nil
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return fmt.Errorf("private key is not a valid SSH key: %s", err.Error())
+	}
+	if string(signer.PublicKey().Marshal()) != string(publicKey.Marshal()) {
+		return errors.New("public key does not belong to the private key")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSecretTypes(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Stores every field of a login", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		login := map[string]string{
+			domain.FieldUsername: "alice",
+			domain.FieldPassword: "hunter2",
+			domain.FieldURL:      "https://example.com/login",
+		}
+		tc.Require.NoError(svc.SaveNewTypedSecret("example", domain.SecretTypeLogin, login), errCreateSecret)
+
+		secret, err := svc.GetSecret("example")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(domain.SecretTypeLogin, secret.Type)
+		tc.Assert.Empty(secret.Versions[0].SecretValueEnc)
+		tc.Assert.Len(secret.Versions[0].Fields, 3, "Empty optional fields are not stored")
+		tc.Assert.NotContains(secret.Versions[0].Fields[domain.FieldPassword], "hunter2")
+
+		fields, err := svc.GetSecretFields(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(login, fields)
+		value, err := svc.GetSecretValue(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("hunter2", value, "The password is the value of a login")
+	})
+
+	helpers.WithUnitTestCase(t, "Seals each field with its name", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewTypedSecret("example", domain.SecretTypeLogin, map[string]string{
+			domain.FieldUsername: "alice",
+			domain.FieldPassword: "hunter2",
+		}), errCreateSecret)
+
+		secret, err := svc.GetSecret("example")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		swapped := secret.Clone()
+		fields := swapped.Versions[0].Fields
+		fields[domain.FieldUsername], fields[domain.FieldPassword] = fields[domain.FieldPassword], fields[domain.FieldUsername]
+		_, err = svc.GetSecretFields(&swapped)
+		tc.Assert.Error(err, "A field moved to another name must not decrypt")
+	})
+
+	helpers.WithUnitTestCase(t, "Updates fields as a new version", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewTypedSecret("token", domain.SecretTypeAPIToken, map[string]string{
+			domain.FieldToken:  "abc",
+			domain.FieldScopes: "read",
+		}), errCreateSecret)
+		tc.Require.NoError(svc.UpdateSecretFields("token", map[string]string{
+			domain.FieldToken:  "def",
+			domain.FieldScopes: "read, write",
+		}, "widen the scopes"))
+
+		secret, err := svc.GetSecret("token")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(2, secret.CurrentVersion)
+		old, err := svc.GetSecretFieldsByVersion(secret, 1)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("abc", old[domain.FieldToken])
+		value, err := svc.GetSecretValueByVersion(secret, 2)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("def", value)
+
+		err = svc.UpdateSecret("token", "ghi", "")
+		tc.Assert.ErrorIs(err, service.ErrInvalidSecret, "A typed secret is not a single value")
+	})
+
+	helpers.WithUnitTestCase(t, "Plain secrets keep a single value", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewSecret("plain", "value1"), errCreateSecret)
+
+		secret, err := svc.GetSecret("plain")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(domain.SecretTypeKeyValue, secret.Type)
+		tc.Assert.Nil(secret.Versions[0].Fields)
+		fields, err := svc.GetSecretFields(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal(map[string]string{domain.FieldValue: "value1"}, fields)
+	})
+
+	helpers.WithUnitTestCase(t, "Validates fields per type", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		invalid := []struct {
+			secretType domain.SecretType
+			fields     map[string]string
+		}{
+			{domain.SecretTypeLogin, map[string]string{domain.FieldUsername: "alice"}},
+			{domain.SecretTypeLogin, map[string]string{domain.FieldPassword: "x", domain.FieldURL: "example.com"}},
+			{domain.SecretTypeLogin, map[string]string{domain.FieldPassword: "x", domain.FieldToken: "y"}},
+			{domain.SecretTypeCreditCard, map[string]string{domain.FieldCardNumber: "4111 1111 1111 1112", domain.FieldExpiry: "12/30"}},
+			{domain.SecretTypeCreditCard, map[string]string{domain.FieldCardNumber: "4111 1111 1111 1111", domain.FieldExpiry: "13/30"}},
+			{domain.SecretTypeCreditCard, map[string]string{domain.FieldCardNumber: "4111 1111 1111 1111", domain.FieldExpiry: "12/30", domain.FieldCVV: "12"}},
+			{domain.SecretTypeSSHKey, map[string]string{domain.FieldPrivateKey: "not a key"}},
+			{domain.SecretTypeSecureNote, map[string]string{domain.FieldNote: " "}},
+			{domain.SecretTypeJSON, map[string]string{domain.FieldValue: "{"}},
+		}
+		for _, secret := range invalid {
+			err := svc.SaveNewTypedSecret("invalid", secret.secretType, secret.fields)
+			tc.Assert.ErrorIs(err, service.ErrInvalidSecret, "%s %v should be rejected", secret.secretType, secret.fields)
+		}
+
+		tc.Assert.NoError(svc.SaveNewTypedSecret("card", domain.SecretTypeCreditCard, map[string]string{
+			domain.FieldCardNumber: "4111 1111 1111 1111",
+			domain.FieldExpiry:     "12/30",
+			domain.FieldCVV:        "123",
+		}))
+		tc.Assert.ErrorContains(svc.SaveNewTypedSecret("unknown", "bogus", nil), "unsupported secret type")
+	})
+
+	helpers.WithUnitTestCase(t, "Checks an SSH key pair", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		tc.Require.NoError(err)
+		block, err := ssh.MarshalPrivateKey(privateKey, "")
+		tc.Require.NoError(err)
+		sshPublicKey, err := ssh.NewPublicKey(publicKey)
+		tc.Require.NoError(err)
+		_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+		tc.Require.NoError(err)
+		otherPublicKey, err := ssh.NewPublicKey(otherKey.Public())
+		tc.Require.NoError(err)
+
+		private := string(pem.EncodeToMemory(block))
+		err = svc.SaveNewTypedSecret("mismatch", domain.SecretTypeSSHKey, map[string]string{
+			domain.FieldPrivateKey: private,
+			domain.FieldPublicKey:  string(ssh.MarshalAuthorizedKey(otherPublicKey)),
+		})
+		tc.Assert.ErrorIs(err, service.ErrInvalidSecret)
+		tc.Assert.NoError(svc.SaveNewTypedSecret("deploy", domain.SecretTypeSSHKey, map[string]string{
+			domain.FieldPrivateKey: private,
+			domain.FieldPublicKey:  strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))),
+		}))
+	})
+}
@@ -5,13 +5,21 @@ import (
 	"fmt"
 	"go-password-manager/internal/domain"
 	"go-password-manager/internal/logger"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 // CryptoProvider defines the contract for cryptographic operations that the SecretsService needs.
+// The WithAD variants bind a ciphertext to associated data that must match on decrypt.
 type CryptoProvider interface {
 	Encrypt(data, key []byte) ([]byte, error)
 	Decrypt(data, key []byte) ([]byte, error)
+	EncryptWithAD(data, key, ad []byte) ([]byte, error)
+	DecryptWithAD(data, key, ad []byte) ([]byte, error)
 	GetKey() []byte
 }
 
@@ -21,34 +29,96 @@ type StorageProvider interface {
 	WriteSecrets(secrets domain.SecretsFile) error
 }
 
-// SecretsService manages secret operations with encryption
+// SecretsService manages secret operations with encryption.
+// It is safe for concurrent use: getters share a read lock and mutations are serialized.
 type SecretsService struct {
 	crypto  CryptoProvider
 	storage StorageProvider
+	author  string // Recorded as UpdatedBy of the versions written through this service
+	mu      sync.RWMutex
+
+	cacheMu      sync.Mutex
+	cache        *domain.SecretsFile
+	cacheStamp   string
+	writtenStamp string // Stamp after the last write through this service
+}
+
+// Option configures a SecretsService
+type Option func(*SecretsService)
+
+// WithAuthor sets who new versions are recorded as written by, instead of DefaultAuthor
+func WithAuthor(author string) Option {
+	return func(s *SecretsService) {
+		if author = strings.TrimSpace(author); author != "" {
+			s.author = author
+		}
+	}
 }
 
 // NewSecretsService creates a new secrets service
-func NewSecretsService(crypto CryptoProvider, storage StorageProvider) *SecretsService {
-	return &SecretsService{
+func NewSecretsService(crypto CryptoProvider, storage StorageProvider, options ...Option) *SecretsService {
+	s := &SecretsService{
 		crypto:  crypto,
 		storage: storage,
+		author:  DefaultAuthor(),
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
-// LoadAllSecrets loads all secrets with nested versions from the file
+// DefaultAuthor identifies the OS user and host, like alice@laptop, or is empty when neither is known
+func DefaultAuthor() string {
+	var name string
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if name == "" {
+		name = os.Getenv("USER")
+	}
+	// Windows reports users as DOMAIN\user
+	if i := strings.LastIndex(name, "\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	host, _ := os.Hostname()
+	switch {
+	case name != "" && host != "":
+		return name + "@" + host
+	case name != "":
+		return name
+	default:
+		return host
+	}
+}
+
+// LoadAllSecrets loads all secrets with nested versions from the file, leaving out those in the trash
 func (s *SecretsService) LoadAllSecrets() (domain.SecretsFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	logger.Debug("Loading all secrets")
-	return s.storage.ReadSecrets()
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	secretsData.Secrets = slices.DeleteFunc(secretsData.Secrets, func(secret domain.Secret) bool { return secret.Trashed() })
+	return secretsData, nil
 }
 
 func (s *SecretsService) GetSecret(name string) (*domain.Secret, error) {
-	secrets, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getSecret(name)
+}
+
+func (s *SecretsService) getSecret(name string) (*domain.Secret, error) {
+	secrets, err := s.readSecrets()
 	if err != nil {
 		return nil, err
 	}
 
 	for i, secret := range secrets.Secrets {
-		if secret.SecretName == name {
+		if secret.SecretName == name && !secret.Trashed() {
 			return &secrets.Secrets[i], nil
 		}
 	}
@@ -57,48 +127,139 @@ func (s *SecretsService) GetSecret(name string) (*domain.Secret, error) {
 }
 
 func (s *SecretsService) SaveNewSecret(name, value string) error {
-	secretsData, err := s.storage.ReadSecrets()
+	return s.SaveNewTypedSecret(name, domain.SecretTypeKeyValue, map[string]string{domain.FieldValue: value})
+}
+
+// SaveNewTypedSecret creates a secret of the given type from its named fields, see SecretTypes
+func (s *SecretsService) SaveNewTypedSecret(name string, secretType domain.SecretType, fields map[string]string) error {
+	spec, err := LookupSecretType(secretType)
+	if err != nil {
+		return err
+	}
+	fields, err = spec.check(fields)
 	if err != nil {
 		return err
 	}
 
-	// Check if secret with the same name already exists
-	for _, secret := range secretsData.Secrets {
-		if secret.SecretName == name {
-			return fmt.Errorf("secret '%s' already exists", name)
-		}
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	encryptedValue, err := s.crypto.Encrypt([]byte(value), s.crypto.GetKey())
+	secretsData, err := s.readSecrets()
 	if err != nil {
 		return err
 	}
 
+	if err := checkNameFree(secretsData.Secrets, name); err != nil {
+		return err
+	}
+
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
+	}
+
 	newSecret := domain.Secret{
-		SecretName: name,
-		Versions: []domain.SecretVersion{
-			{
-				Version:        1,
-				SecretValueEnc: string(encryptedValue),
-				UpdatedAt:      time.Now().Format(time.RFC3339),
-			},
-		},
+		SecretName:     name,
+		Type:           spec.Type,
 		CurrentVersion: 1,
 	}
+	if _, err := s.newDataKey(&newSecret, s.crypto.GetKey()); err != nil {
+		return err
+	}
+	version, err := s.sealVersion(&newSecret, spec, 1, secretContent{fields: fields})
+	if err != nil {
+		return err
+	}
+	newSecret.Versions = []domain.SecretVersion{version}
 
 	secretsData.Secrets = append(secretsData.Secrets, newSecret)
-	return s.storage.WriteSecrets(secretsData)
+	return s.writeSecrets(secretsData)
 }
 
-func (s *SecretsService) UpdateSecret(name, newValue string) error {
-	secretsData, err := s.storage.ReadSecrets()
+// checkNameFree fails when a secret in use or in the trash already has name
+func checkNameFree(secrets []domain.Secret, name string) error {
+	for _, secret := range secrets {
+		switch {
+		case secret.SecretName != name:
+		case secret.Trashed():
+			return fmt.Errorf("secret '%s' is in the trash, restore or purge it first", name)
+		default:
+			return fmt.Errorf("secret '%s' already exists", name)
+		}
+	}
+	return nil
+}
+
+// sealVersion encrypts checked content as the given version of secret
+func (s *SecretsService) sealVersion(secret *domain.Secret, spec SecretTypeSpec, version int, content secretContent) (domain.SecretVersion, error) {
+	sealed := domain.SecretVersion{
+		Version:   version,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+		UpdatedBy: s.author,
+	}
+	customFields, err := s.encryptCustomFields(secret, version, content.custom, s.crypto.GetKey())
+	if err != nil {
+		return domain.SecretVersion{}, err
+	}
+	sealed.CustomFields = customFields
+	sealed.Attachments = content.attachments
+
+	if !spec.Structured() {
+		encryptedValue, err := s.encryptVersion(secret, version, []byte(content.fields[domain.FieldValue]), s.crypto.GetKey())
+		if err != nil {
+			return domain.SecretVersion{}, err
+		}
+		sealed.SecretValueEnc = string(encryptedValue)
+		return sealed, nil
+	}
+
+	encryptedFields, err := s.encryptFields(secret, version, content.fields, s.crypto.GetKey())
+	if err != nil {
+		return domain.SecretVersion{}, err
+	}
+	sealed.Fields = encryptedFields
+	return sealed, nil
+}
+
+// UpdateSecret adds a version of a single value secret. note, which may be empty, says why it changed.
+func (s *SecretsService) UpdateSecret(name, newValue, note string) error {
+	return s.updateSecret(name, note, func(_ *domain.Secret, spec SecretTypeSpec, content *secretContent) error {
+		if spec.Structured() {
+			return fmt.Errorf("%w: '%s' is a %s, update its fields instead", ErrInvalidSecret, name, spec.Label)
+		}
+		content.fields = map[string]string{domain.FieldValue: newValue}
+		return nil
+	})
+}
+
+// UpdateSecretFields adds a version of a typed secret with all of its fields replaced, see UpdateSecret
+func (s *SecretsService) UpdateSecretFields(name string, fields map[string]string, note string) error {
+	return s.updateSecret(name, note, func(_ *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		content.fields = fields
+		return nil
+	})
+}
+
+// updateSecret adds a version to the named secret with its current content as changed by change,
+// annotated with note. Whatever change leaves alone, such as the custom fields when the value
+// changes, is carried over.
+func (s *SecretsService) updateSecret(name, note string, change func(secret *domain.Secret, spec SecretTypeSpec, content *secretContent) error) error {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
 	if err != nil {
 		return err
 	}
 
 	var secretToUpdate *domain.Secret
 	for i := range secretsData.Secrets {
-		if secretsData.Secrets[i].SecretName == name {
+		if secretsData.Secrets[i].SecretName == name && !secretsData.Secrets[i].Trashed() {
 			secretToUpdate = &secretsData.Secrets[i]
 			break
 		}
@@ -108,48 +269,63 @@ func (s *SecretsService) UpdateSecret(name, newValue string) error {
 		return fmt.Errorf("secret '%s' not found", name)
 	}
 
-	encryptedValue, err := s.crypto.Encrypt([]byte(newValue), s.crypto.GetKey())
+	spec, err := LookupSecretType(secretToUpdate.Type)
 	if err != nil {
 		return err
 	}
-
-	newVersion := domain.SecretVersion{
-		Version:        secretToUpdate.CurrentVersion + 1,
-		SecretValueEnc: string(encryptedValue),
-		UpdatedAt:      time.Now().Format(time.RFC3339),
+	if err := s.upgradeSecrets(&secretsData); err != nil {
+		return err
 	}
 
-	secretToUpdate.Versions = append(secretToUpdate.Versions, newVersion)
-	secretToUpdate.CurrentVersion++
-
-	return s.storage.WriteSecrets(secretsData)
-}
+	var content secretContent
+	if current := secretToUpdate.GetCurrentVersion(); current != nil {
+		if content, err = s.versionContent(secretToUpdate, current); err != nil {
+			return err
+		}
+	}
+	if err := change(secretToUpdate, spec, &content); err != nil {
+		return err
+	}
+	if content.fields, err = spec.check(content.fields); err != nil {
+		return err
+	}
+	if err := checkCustomFields(content.custom); err != nil {
+		return err
+	}
 
-func (s *SecretsService) DeleteSecret(name string) error {
-	data, err := s.storage.ReadSecrets()
+	newVersion, err := s.sealVersion(secretToUpdate, spec, secretToUpdate.NextVersion(), content)
 	if err != nil {
 		return err
 	}
+	newVersion.Note = strings.TrimSpace(note)
 
-	var newSecrets []domain.Secret
-	var found bool
-	for _, secret := range data.Secrets {
-		if secret.SecretName != name {
-			newSecrets = append(newSecrets, secret)
-		} else {
-			found = true
-		}
-	}
+	secretToUpdate.Versions = append(secretToUpdate.Versions, newVersion)
+	secretToUpdate.CurrentVersion = newVersion.Version
 
-	if !found {
-		return nil // Idempotent delete
-	}
-	data.Secrets = newSecrets
+	return s.writeSecrets(secretsData)
+}
 
-	return s.storage.WriteSecrets(data)
+// DeleteSecret moves the named secret to the trash, see RestoreSecret and PurgeSecret.
+// Deleting a secret that does not exist does nothing.
+func (s *SecretsService) DeleteSecret(name string) error {
+	return s.modifySecrets(func(secrets []domain.Secret) (bool, error) {
+		for i := range secrets {
+			if secrets[i].SecretName == name && !secrets[i].Trashed() {
+				secrets[i].DeletedAt = time.Now().Format(time.RFC3339)
+				return true, nil
+			}
+		}
+		return false, nil // Idempotent delete
+	})
 }
 
 func (s *SecretsService) GetSecretValue(secret *domain.Secret) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secretValue(secret)
+}
+
+func (s *SecretsService) secretValue(secret *domain.Secret) (string, error) {
 	var currentVersion *domain.SecretVersion
 	for i := range secret.Versions {
 		if secret.Versions[i].Version == secret.CurrentVersion {
@@ -161,54 +337,108 @@ func (s *SecretsService) GetSecretValue(secret *domain.Secret) (string, error) {
 	if currentVersion == nil {
 		return "", fmt.Errorf("no current version found for secret '%s'", secret.SecretName)
 	}
-	plainBytes, err := s.crypto.Decrypt([]byte(currentVersion.SecretValueEnc), s.crypto.GetKey())
+	bound, err := s.associatedDataBound()
 	if err != nil {
 		return "", err
 	}
-	return string(plainBytes), nil
+	return s.versionValue(secret, currentVersion, bound)
+}
+
+// versionValue opens the value of a version, which is the primary field of a typed secret
+func (s *SecretsService) versionValue(secret *domain.Secret, version *domain.SecretVersion, bound bool) (string, error) {
+	if version.Fields == nil {
+		plainBytes, err := s.decryptVersion(secret, version, s.crypto.GetKey(), bound)
+		if err != nil {
+			return "", err
+		}
+		return string(plainBytes), nil
+	}
+
+	spec, err := LookupSecretType(secret.Type)
+	if err != nil {
+		return "", err
+	}
+	fields, err := s.decryptFields(secret, version, s.crypto.GetKey(), bound)
+	if err != nil {
+		return "", err
+	}
+	return fields[spec.Primary], nil
+}
+
+// GetSecretFields returns the named fields of the current version of secret
+func (s *SecretsService) GetSecretFields(secret *domain.Secret) (map[string]string, error) {
+	return s.GetSecretFieldsByVersion(secret, secret.CurrentVersion)
+}
+
+// GetSecretFieldsByVersion returns the named fields of a version of secret.
+// Single value secrets return their value as the value field.
+func (s *SecretsService) GetSecretFieldsByVersion(secret *domain.Secret, versionNumber int) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range secret.Versions {
+		if secret.Versions[i].Version == versionNumber {
+			bound, err := s.associatedDataBound()
+			if err != nil {
+				return nil, err
+			}
+			return s.decryptFields(secret, &secret.Versions[i], s.crypto.GetKey(), bound)
+		}
+	}
+	return nil, fmt.Errorf("version %d not found for secret '%s'", versionNumber, secret.SecretName)
 }
 
 func (s *SecretsService) GetSecretValueByVersion(secret *domain.Secret, versionNumber int) (string, error) {
-	for _, version := range secret.Versions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, version := range secret.Versions {
 		if version.Version == versionNumber {
 			logger.Debug("Decrypting secret version:", fmt.Sprintf("%d", version.Version))
 
-			plainBytes, err := s.crypto.Decrypt([]byte(version.SecretValueEnc), s.crypto.GetKey())
+			bound, err := s.associatedDataBound()
 			if err != nil {
 				return "", err
 			}
-			return string(plainBytes), nil
+			return s.versionValue(secret, &secret.Versions[i], bound)
 		}
 	}
 	return "", fmt.Errorf("version %d not found for secret '%s'", versionNumber, secret.SecretName)
 }
 
+// RevertToVersion adds a version of the named secret with the content of an older version, noted
+// as reverted from it. Values are sealed with their version number, so the content is re-encrypted.
 func (s *SecretsService) RevertToVersion(secretName string, version int) error {
-	secrets, err := s.storage.ReadSecrets()
-	if err != nil {
-		return err
-	}
-
-	for i, sec := range secrets.Secrets {
-		if sec.SecretName == secretName {
-			secrets.Secrets[i].CurrentVersion = version
-			return s.storage.WriteSecrets(secrets)
+	return s.updateSecret(secretName, fmt.Sprintf("reverted from v%d", version), func(secret *domain.Secret, _ SecretTypeSpec, content *secretContent) error {
+		if version == secret.CurrentVersion {
+			return fmt.Errorf("version %d is already the current version of '%s'", version, secretName)
 		}
-	}
-
-	return fmt.Errorf("secret not found: %s", secretName)
+		for i := range secret.Versions {
+			if secret.Versions[i].Version == version {
+				reverted, err := s.versionContent(secret, &secret.Versions[i])
+				if err != nil {
+					return err
+				}
+				*content = reverted
+				return nil
+			}
+		}
+		return fmt.Errorf("version %d not found for secret '%s'", version, secretName)
+	})
 }
 
 func (s *SecretsService) GetCurrentVersionValue(name string) (string, error) {
-	secret, err := s.GetSecret(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secret, err := s.getSecret(name)
 	if err != nil {
 		return "", err
 	}
-	return s.GetSecretValue(secret)
+	return s.secretValue(secret)
 }
 
 func (s *SecretsService) GetRaw() ([]byte, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return nil, err
 	}
@@ -216,27 +446,41 @@ func (s *SecretsService) GetRaw() ([]byte, error) {
 }
 
 func (s *SecretsService) GetTotalSecrets() (int, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return 0, err
 	}
-	return len(secretsFile.Secrets), nil
+	total := 0
+	for _, secret := range secretsFile.Secrets {
+		if !secret.Trashed() {
+			total++
+		}
+	}
+	return total, nil
 }
 
 func (s *SecretsService) GetTotalVersions() (int, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return 0, err
 	}
 	totalVersions := 0
 	for _, secret := range secretsFile.Secrets {
-		totalVersions += len(secret.Versions)
+		if !secret.Trashed() {
+			totalVersions += len(secret.Versions)
+		}
 	}
 	return totalVersions, nil
 }
 
 func (s *SecretsService) GetLastUpdated() (string, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return "", err
 	}
@@ -244,7 +488,9 @@ func (s *SecretsService) GetLastUpdated() (string, error) {
 }
 
 func (s *SecretsService) GetAppVersion() (string, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return "", err
 	}
@@ -252,7 +498,9 @@ func (s *SecretsService) GetAppVersion() (string, error) {
 }
 
 func (s *SecretsService) GetAppUser() (string, error) {
-	secretsFile, err := s.storage.ReadSecrets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsFile, err := s.readSecrets()
 	if err != nil {
 		return "", err
 	}
@@ -45,12 +45,24 @@ func (m *mockCryptoService) Decrypt(data, key []byte) ([]byte, error) {
 	return crypto.Decrypt(string(data), key)
 }
 
+func (m *mockCryptoService) EncryptWithAD(data, key, ad []byte) ([]byte, error) {
+	s, err := crypto.EncryptWithOptions(data, key, crypto.EncryptOptions{AdditionalData: ad})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func (m *mockCryptoService) DecryptWithAD(data, key, ad []byte) ([]byte, error) {
+	return crypto.DecryptWithAD(string(data), key, ad)
+}
+
 func (m *mockCryptoService) GetKey() []byte {
 	return m.key
 }
 
-// setupTestService creates a new SecretsService for testing, with a temporary file.
-func setupTestService(t *testing.T) *service.SecretsService {
+// setupTestStorage creates a FileStorage backed by an empty secrets file in a temporary directory.
+func setupTestStorage(t *testing.T) service.StorageService {
 	tempDir := t.TempDir()
 	testFile := filepath.Join(tempDir, testSecretsFile)
 
@@ -70,8 +82,13 @@ func setupTestService(t *testing.T) *service.SecretsService {
 		t.Fatalf("Failed to write empty secrets file: %v", err)
 	}
 
+	return storage.NewFileStorage(testFile, "1.0.0", testdata.TestUsers.UnitTestUser.Name)
+}
+
+// setupTestService creates a new SecretsService for testing, with a temporary file.
+func setupTestService(t *testing.T) *service.SecretsService {
 	cryptoService := newMockCryptoService([]byte(testdata.TestEncryptionKey))
-	storageService := storage.NewFileStorage(testFile, "1.0.0", testdata.TestUsers.UnitTestUser.Name)
+	storageService := setupTestStorage(t)
 
 	svc := service.NewSecretsService(cryptoService, storageService)
 
@@ -101,7 +118,7 @@ func TestSecretsService(t *testing.T) {
 		err := svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "initial-value")
 		tc.Require.NoError(err, errCreateSecret)
 
-		err = svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "updated-value")
+		err = svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "updated-value", "")
 		tc.Require.NoError(err, "Expected no error editing secret")
 
 		fileData, err := svc.LoadAllSecrets()
@@ -165,7 +182,7 @@ func TestSecretsService(t *testing.T) {
 
 		err := svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1")
 		tc.Require.NoError(err, errCreateSecret)
-		err = svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2")
+		err = svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", "")
 		tc.Require.NoError(err, "updating secret failed")
 		secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
 		tc.Require.NoError(err, errGettingSecretFailed)
@@ -180,6 +197,50 @@ func TestSecretsService(t *testing.T) {
 		tc.Assert.Equal("value2", value, secretValueShouldMatch)
 	})
 
+	helpers.WithUnitTestCase(t, "RecordsAuthorAndNote", func(tc *helpers.UnitTestCase) {
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), setupTestStorage(t), service.WithAuthor("alice@laptop"))
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"), errCreateSecret)
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", "  rotated after the leak "))
+		secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal("alice@laptop", secret.Versions[0].UpdatedBy)
+		tc.Assert.Empty(secret.Versions[0].Note)
+		tc.Assert.Equal("alice@laptop", secret.Versions[1].UpdatedBy)
+		tc.Assert.Equal("rotated after the leak", secret.Versions[1].Note)
+
+		plain := setupTestService(t)
+		tc.Require.NoError(plain.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"), errCreateSecret)
+		secret, err = plain.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(service.DefaultAuthor(), secret.Versions[0].UpdatedBy, "Without an identity the OS user and host are recorded")
+	})
+
+	helpers.WithUnitTestCase(t, "RevertToVersion", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+
+		tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"), errCreateSecret)
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+		tc.Require.NoError(svc.RevertToVersion(testdata.TestSecrets.Simple.Name, 1))
+		secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(3, secret.CurrentVersion, "Reverting adds a version")
+		tc.Assert.Equal("reverted from v1", secret.GetCurrentVersion().Note)
+		value, err := svc.GetSecretValue(secret)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value1", value, secretValueShouldMatch)
+
+		tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value4", ""))
+		secret, err = svc.GetSecret(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Equal(4, secret.CurrentVersion)
+		tc.Assert.Len(secret.Versions, 4, "Every version keeps its own number")
+
+		tc.Assert.Error(svc.RevertToVersion(testdata.TestSecrets.Simple.Name, 99), "Expected error for invalid version")
+		tc.Assert.Error(svc.RevertToVersion(testdata.TestSecrets.Simple.Name, 4), "The current version cannot be reverted to")
+		tc.Assert.Error(svc.RevertToVersion(nonExistentName, 1))
+	})
+
 	helpers.WithUnitTestCase(t, "GetSecretValueInvalidVersion", func(tc *helpers.UnitTestCase) {
 		svc := setupTestService(t)
 
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageBackends(t *testing.T) {
+	for _, backend := range []string{storage.TypeFile, storage.TypeMemory, storage.TypeDirectory, storage.TypeJournal, storage.TypeGit} {
+		helpers.WithUnitTestCase(t, backend, func(tc *helpers.UnitTestCase) {
+			dir := t.TempDir()
+			key := []byte(testdata.TestEncryptionKey)
+			store, err := storage.New(backend, storage.BackendConfig{
+				SecretsFile: filepath.Join(dir, testSecretsFile),
+				Directory:   filepath.Join(dir, "vault"),
+				AppVersion:  "1.0.0",
+				AppUser:     testdata.TestUsers.UnitTestUser.Name,
+				Crypto:      newMockCryptoService(key),
+			})
+			tc.Require.NoError(err)
+			svc := service.NewSecretsService(newMockCryptoService(key), store)
+
+			tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+			tc.Require.NoError(svc.SaveNewSecret(testdata.TestSecrets.Special.Name, testdata.TestSecrets.Special.Value))
+			tc.Require.NoError(svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+			tc.Require.NoError(svc.DeleteSecret(testdata.TestSecrets.Special.Name))
+
+			value, err := svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+			tc.Require.NoError(err, errGetSecretValue)
+			tc.Assert.Equal("value2", value, secretValueShouldMatch)
+
+			total, err := svc.GetTotalSecrets()
+			tc.Require.NoError(err)
+			tc.Assert.Equal(1, total)
+
+			attachment, err := svc.AddAttachment(testdata.TestSecrets.Simple.Name, "ca.pem", "", []byte("certificate"))
+			tc.Require.NoError(err)
+			secret, err := svc.GetSecret(testdata.TestSecrets.Simple.Name)
+			tc.Require.NoError(err, errGettingSecretFailed)
+			data, err := svc.ExtractAttachment(secret, attachment.ID)
+			tc.Require.NoError(err)
+			tc.Assert.Equal("certificate", string(data))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/logger"
+	"reflect"
+)
+
+// ErrSyncConflict is returned when secrets were changed differently on both sides and no resolution was given
+var ErrSyncConflict = errors.New("secrets were changed on both sides")
+
+// ErrRemoteChanged is returned by Remote.Push when the remote changed since it was pulled
+var ErrRemoteChanged = errors.New("remote vault changed since it was pulled")
+
+// ErrSyncIncompatible is returned when the remote vault is encrypted differently from the local one
+var ErrSyncIncompatible = errors.New("remote vault was written with another key or format")
+
+// ErrSyncRolledBack is returned when the remote serves an older vault than the one of the last sync
+var ErrSyncRolledBack = errors.New("remote vault is older than the last sync")
+
+// maxSyncAttempts bounds how often a sync starts over because the remote changed during it
+const maxSyncAttempts = 3
+
+// Remote is the other side of a sync. Pull returns the remote vault, nil if nothing was pushed yet,
+// and a revision that Push uses to refuse overwriting a change made in between with ErrRemoteChanged.
+type Remote interface {
+	Pull() (data *domain.SecretsFile, revision string, err error)
+	Push(data domain.SecretsFile, revision string) (newRevision string, err error)
+}
+
+// SyncBase keeps the vault as of the last sync, the common ancestor of both sides for the next one.
+// ReadBase returns nil before the first sync.
+type SyncBase interface {
+	ReadBase() (*domain.SecretsFile, error)
+	WriteBase(data domain.SecretsFile) error
+}
+
+// Sync merges the vault with remote and pushes the result, using base to tell which side changed
+// what. Secrets changed on both sides are merged version by version when possible; the rest are
+// returned as conflicts with ErrSyncConflict and nothing is written until resolutions settles them.
+func (s *SecretsService) Sync(remote Remote, base SyncBase, resolutions map[string]Resolution) (domain.SyncResult, error) {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	defer unlock()
+
+	for attempt := 1; ; attempt++ {
+		result, err := s.sync(remote, base, resolutions)
+		if !errors.Is(err, ErrRemoteChanged) || attempt == maxSyncAttempts {
+			return result, err
+		}
+		logger.Info("Remote vault changed during sync, starting over")
+	}
+}
+
+func (s *SecretsService) sync(remote Remote, base SyncBase, resolutions map[string]Resolution) (domain.SyncResult, error) {
+	local, err := s.readSecrets()
+	if err != nil {
+		return domain.SyncResult{}, err
+	}
+	theirs, revision, err := remote.Pull()
+	if err != nil {
+		return domain.SyncResult{}, fmt.Errorf("failed to pull: %w", err)
+	}
+	common, err := base.ReadBase()
+	if err != nil {
+		return domain.SyncResult{}, fmt.Errorf("failed to read sync base: %w", err)
+	}
+
+	// The sync generation is sealed with the vault, so the remote cannot bring back an older copy
+	var synced uint64
+	if theirs != nil {
+		synced = theirs.SyncGeneration
+	}
+	if common != nil && synced < common.SyncGeneration {
+		return domain.SyncResult{}, fmt.Errorf("%w: generation %d, last synced %d", ErrSyncRolledBack, synced, common.SyncGeneration)
+	}
+
+	var result domain.SyncResult
+	merged := local.Clone()
+	if theirs != nil {
+		if len(local.Secrets) == 0 {
+			// An empty vault takes the format of the remote one
+			merged.KeyID, merged.AssociatedDataBound = theirs.KeyID, theirs.AssociatedDataBound
+		} else if theirs.KeyID != local.KeyID || theirs.AssociatedDataBound != local.AssociatedDataBound {
+			return domain.SyncResult{}, ErrSyncIncompatible
+		}
+		var baseSecrets []domain.Secret
+		if common != nil {
+			baseSecrets = common.Secrets
+		}
+		merged.Secrets, result.Conflicts = mergeSecrets(baseSecrets, local.Secrets, theirs.Secrets, resolutions)
+		if len(result.Conflicts) > 0 {
+			return result, ErrSyncConflict
+		}
+		result.Received = changedSecrets(local.Secrets, merged.Secrets)
+		result.Sent = changedSecrets(theirs.Secrets, merged.Secrets)
+	} else {
+		result.Sent = changedSecrets(nil, merged.Secrets)
+	}
+	if merged.Secrets == nil {
+		merged.Secrets = []domain.Secret{}
+	}
+
+	if len(result.Received) > 0 {
+		if err := s.writeSecrets(merged); err != nil {
+			return domain.SyncResult{}, err
+		}
+	}
+	if theirs == nil || len(result.Sent) > 0 {
+		pushed := merged.Clone()
+		pushed.Generation, pushed.MAC = 0, "" // Signatures only mean something on this machine
+		if common != nil {
+			synced = max(synced, common.SyncGeneration)
+		}
+		synced++
+		pushed.SyncGeneration = synced
+		if _, err := remote.Push(pushed, revision); err != nil {
+			return domain.SyncResult{}, fmt.Errorf("failed to push: %w", err)
+		}
+	}
+	merged.SyncGeneration = synced
+	if err := base.WriteBase(merged); err != nil {
+		return domain.SyncResult{}, fmt.Errorf("failed to save sync base: %w", err)
+	}
+	return result, nil
+}
+
+// changedSecrets returns the names of the secrets that differ between before and after
+func changedSecrets(before, after []domain.Secret) []string {
+	previous := secretsByName(before)
+	var changed []string
+	remaining := map[string]bool{}
+	for i := range after {
+		name := after[i].SecretName
+		remaining[name] = true
+		if !reflect.DeepEqual(previous[name], &after[i]) {
+			changed = append(changed, name)
+		}
+	}
+	for _, secret := range before {
+		if !remaining[secret.SecretName] {
+			changed = append(changed, secret.SecretName)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,172 @@
+package service_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syncMachine is one side of a sync: its own vault and sync base, sharing the remote
+type syncMachine struct {
+	svc       *service.SecretsService
+	remote    service.Remote
+	remoteDir string
+	base      service.SyncBase
+}
+
+func newSyncMachines(t *testing.T, tc *helpers.UnitTestCase) (*syncMachine, *syncMachine) {
+	key := []byte(testdata.TestEncryptionKey)
+	remoteDir := t.TempDir()
+	newMachine := func() *syncMachine {
+		remote, err := storage.NewRemote(remoteDir, "", newMockCryptoService(key))
+		tc.Require.NoError(err)
+		return &syncMachine{
+			svc:       service.NewSecretsService(newMockCryptoService(key), storage.NewMemoryStorage("1.0.0", testdata.TestUsers.UnitTestUser.Name)),
+			remote:    remote,
+			remoteDir: remoteDir,
+			base:      storage.NewSyncBase(filepath.Join(t.TempDir(), "sync-base"), newMockCryptoService(key)),
+		}
+	}
+	return newMachine(), newMachine()
+}
+
+func (m *syncMachine) sync(resolutions map[string]service.Resolution) error {
+	_, err := m.svc.Sync(m.remote, m.base, resolutions)
+	return err
+}
+
+func TestSync(t *testing.T) {
+	helpers.WithUnitTestCase(t, "CopiesToAnEmptyMachine", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+
+		result, err := laptop.svc.Sync(laptop.remote, laptop.base, nil)
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{testdata.TestSecrets.Simple.Name}, result.Sent)
+
+		result, err = desktop.svc.Sync(desktop.remote, desktop.base, nil)
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{testdata.TestSecrets.Simple.Name}, result.Received)
+		tc.Assert.Empty(result.Sent)
+
+		value, err := desktop.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value1", value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "RefusesAnOlderRemoteVault", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(laptop.sync(nil))
+		sealed := filepath.Join(laptop.remoteDir, "vault.sealed")
+		stale, err := os.ReadFile(sealed)
+		tc.Require.NoError(err)
+
+		tc.Require.NoError(laptop.svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		// The remote serves the first copy again, sealed with the right key
+		tc.Require.NoError(os.WriteFile(sealed, stale, 0600))
+		tc.Assert.ErrorIs(desktop.sync(nil), service.ErrSyncRolledBack)
+		tc.Assert.ErrorIs(laptop.sync(nil), service.ErrSyncRolledBack)
+
+		value, err := desktop.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value2", value, "The stale copy should not revert the secret")
+
+		tc.Require.NoError(os.Remove(sealed))
+		tc.Assert.ErrorIs(desktop.sync(nil), service.ErrSyncRolledBack, "An emptied remote should not start over")
+	})
+
+	helpers.WithUnitTestCase(t, "MergesChangesToDifferentSecrets", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		tc.Require.NoError(laptop.svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+		tc.Require.NoError(desktop.svc.SaveNewSecret(testdata.TestSecrets.Special.Name, testdata.TestSecrets.Special.Value))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+		tc.Require.NoError(laptop.sync(nil))
+
+		for _, machine := range []*syncMachine{laptop, desktop} {
+			value, err := machine.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+			tc.Require.NoError(err, errGetSecretValue)
+			tc.Assert.Equal("value2", value, secretValueShouldMatch)
+			value, err = machine.svc.GetCurrentVersionValue(testdata.TestSecrets.Special.Name)
+			tc.Require.NoError(err, errGetSecretValue)
+			tc.Assert.Equal(testdata.TestSecrets.Special.Value, value, secretValueShouldMatch)
+		}
+	})
+
+	helpers.WithUnitTestCase(t, "PropagatesDeletes", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		tc.Require.NoError(laptop.svc.DeleteSecret(testdata.TestSecrets.Simple.Name))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		total, err := desktop.svc.GetTotalSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(0, total, "The delete should not be undone by the desktop's copy")
+	})
+
+	helpers.WithUnitTestCase(t, "ReportsConflictsUntilResolved", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		tc.Require.NoError(laptop.svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "laptop", ""))
+		tc.Require.NoError(desktop.svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "desktop", ""))
+		tc.Require.NoError(laptop.sync(nil))
+
+		result, err := desktop.svc.Sync(desktop.remote, desktop.base, nil)
+		tc.Require.ErrorIs(err, service.ErrSyncConflict)
+		tc.Require.Len(result.Conflicts, 1)
+		tc.Assert.Equal(testdata.TestSecrets.Simple.Name, result.Conflicts[0].Name)
+		tc.Assert.NotNil(result.Conflicts[0].Local)
+		tc.Assert.NotNil(result.Conflicts[0].Remote)
+
+		value, err := desktop.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("desktop", value, "Nothing should be written while conflicts are open")
+
+		tc.Require.NoError(desktop.sync(map[string]service.Resolution{testdata.TestSecrets.Simple.Name: service.ResolveRemote}))
+		value, err = desktop.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("laptop", value, secretValueShouldMatch)
+	})
+
+	helpers.WithUnitTestCase(t, "ConflictsOnDeleteAgainstUpdate", func(tc *helpers.UnitTestCase) {
+		laptop, desktop := newSyncMachines(t, tc)
+		tc.Require.NoError(laptop.svc.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+		tc.Require.NoError(laptop.sync(nil))
+		tc.Require.NoError(desktop.sync(nil))
+
+		tc.Require.NoError(laptop.svc.DeleteSecret(testdata.TestSecrets.Simple.Name))
+		tc.Require.NoError(laptop.svc.PurgeSecret(testdata.TestSecrets.Simple.Name))
+		tc.Require.NoError(desktop.svc.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+		tc.Require.NoError(laptop.sync(nil))
+
+		result, err := desktop.svc.Sync(desktop.remote, desktop.base, nil)
+		tc.Require.ErrorIs(err, service.ErrSyncConflict)
+		tc.Require.Len(result.Conflicts, 1)
+		tc.Assert.Nil(result.Conflicts[0].Remote, "The secret was deleted on the remote")
+
+		tc.Require.NoError(desktop.sync(map[string]service.Resolution{testdata.TestSecrets.Simple.Name: service.ResolveLocal}))
+		tc.Require.NoError(laptop.sync(nil))
+		value, err := laptop.svc.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, "The kept secret should come back on the laptop")
+		tc.Assert.Equal("value2", value, secretValueShouldMatch)
+	})
+}
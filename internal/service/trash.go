@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"slices"
+	"time"
+)
+
+// ListTrash returns the deleted secrets waiting in the trash, most recently deleted first
+func (s *SecretsService) ListTrash() ([]domain.Secret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return nil, err
+	}
+	trash := slices.DeleteFunc(secretsData.Secrets, func(secret domain.Secret) bool { return !secret.Trashed() })
+	slices.SortStableFunc(trash, func(a, b domain.Secret) int { return deletedAt(b).Compare(deletedAt(a)) })
+	return trash, nil
+}
+
+// RestoreSecret takes the named secret out of the trash with all its versions
+func (s *SecretsService) RestoreSecret(name string) error {
+	return s.modifySecrets(func(secrets []domain.Secret) (bool, error) {
+		for i := range secrets {
+			if secrets[i].SecretName == name && secrets[i].Trashed() {
+				secrets[i].DeletedAt = ""
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("secret '%s' is not in the trash", name)
+	})
+}
+
+// PurgeSecret permanently removes the named secret from the trash, attachments included.
+// Backups and git history do not keep attachments, see ErrAttachmentPurged.
+func (s *SecretsService) PurgeSecret(name string) error {
+	purged, err := s.purge(func(secret domain.Secret) bool { return secret.SecretName == name })
+	if err != nil {
+		return err
+	}
+	if len(purged) == 0 {
+		return fmt.Errorf("secret '%s' is not in the trash", name)
+	}
+	return nil
+}
+
+// PurgeTrash permanently removes the secrets deleted more than olderThan ago, every one in the
+// trash when olderThan is 0, and returns their names
+func (s *SecretsService) PurgeTrash(olderThan time.Duration) ([]string, error) {
+	cutoff := time.Now().Add(-olderThan)
+	purged, err := s.purge(func(secret domain.Secret) bool {
+		// A deletion time that does not parse is kept until the trash is emptied
+		return olderThan == 0 || (!deletedAt(secret).IsZero() && deletedAt(secret).Before(cutoff))
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(purged))
+	for i, secret := range purged {
+		names[i] = secret.SecretName
+	}
+	return names, nil
+}
+
+// purge removes the trashed secrets matching match from the vault and returns them
+func (s *SecretsService) purge(match func(secret domain.Secret) bool) ([]domain.Secret, error) {
+	unlock, err := s.lockWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	secretsData, err := s.readSecrets()
+	if err != nil {
+		return nil, err
+	}
+	var kept, purged []domain.Secret
+	for _, secret := range secretsData.Secrets {
+		if secret.Trashed() && match(secret) {
+			purged = append(purged, secret)
+		} else {
+			kept = append(kept, secret)
+		}
+	}
+	if len(purged) == 0 {
+		return nil, nil
+	}
+
+	secretsData.Secrets = kept
+	if err := s.writeSecrets(secretsData); err != nil {
+		return nil, err
+	}
+	for _, secret := range purged {
+		s.purgeAttachments(secret)
+	}
+	return purged, nil
+}
+
+// deletedAt returns when secret was moved to the trash, zero when unknown
+func deletedAt(secret domain.Secret) time.Time {
+	t, err := time.Parse(time.RFC3339, secret.DeletedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Deleting moves the secret to the trash", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		tc.Require.NoError(svc.SaveNewSecret("db", "value1"), errCreateSecret)
+		tc.Require.NoError(svc.UpdateSecret("db", "value2", ""))
+		tc.Require.NoError(svc.SaveNewSecret("other", "value"), errCreateSecret)
+		tc.Require.NoError(svc.DeleteSecret("db"))
+		tc.Require.NoError(svc.DeleteSecret("db"), "Deleting twice does nothing")
+
+		secrets, err := svc.LoadAllSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Require.Len(secrets.Secrets, 1)
+		tc.Assert.Equal("other", secrets.Secrets[0].SecretName)
+		_, err = svc.GetSecret("db")
+		tc.Assert.Error(err, "A secret in the trash is not found")
+		tc.Assert.Error(svc.UpdateSecret("db", "value3", ""), "A secret in the trash cannot be changed")
+		tc.Assert.ErrorContains(svc.SaveNewSecret("db", "value"), "in the trash")
+		total, err := svc.GetTotalSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(1, total)
+
+		trash, err := svc.ListTrash()
+		tc.Require.NoError(err)
+		tc.Require.Len(trash, 1)
+		tc.Assert.Equal("db", trash[0].SecretName)
+		tc.Assert.NotEmpty(trash[0].DeletedAt)
+
+		tc.Require.NoError(svc.RestoreSecret("db"))
+		value, err := svc.GetCurrentVersionValue("db")
+		tc.Require.NoError(err, "A restored secret is back")
+		tc.Assert.Equal("value2", value, secretValueShouldMatch)
+		secret, err := svc.GetSecret("db")
+		tc.Require.NoError(err, errGettingSecretFailed)
+		tc.Assert.Len(secret.Versions, 2, "Restoring keeps every version")
+		tc.Assert.Error(svc.RestoreSecret("db"), "Only secrets in the trash can be restored")
+	})
+
+	helpers.WithUnitTestCase(t, "Purges secrets from the trash", func(tc *helpers.UnitTestCase) {
+		svc := setupTestService(t)
+		for _, name := range []string{"first", "second", "kept"} {
+			tc.Require.NoError(svc.SaveNewSecret(name, "value"), errCreateSecret)
+		}
+		tc.Require.NoError(svc.DeleteSecret("first"))
+		tc.Require.NoError(svc.DeleteSecret("second"))
+
+		tc.Assert.Error(svc.PurgeSecret("kept"), "Only secrets in the trash can be purged")
+		tc.Require.NoError(svc.PurgeSecret("first"))
+		trash, err := svc.ListTrash()
+		tc.Require.NoError(err)
+		tc.Require.Len(trash, 1)
+		tc.Assert.Equal("second", trash[0].SecretName)
+
+		purged, err := svc.PurgeTrash(time.Hour)
+		tc.Require.NoError(err)
+		tc.Assert.Empty(purged, "Secrets deleted within the retention stay")
+		purged, err = svc.PurgeTrash(0)
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{"second"}, purged)
+
+		tc.Require.NoError(svc.SaveNewSecret("first", "again"), "A purged name can be reused")
+		secrets, err := svc.LoadAllSecrets()
+		tc.Require.NoError(err, errLoadSecrets)
+		tc.Assert.Len(secrets.Secrets, 2)
+	})
+
+	helpers.WithUnitTestCase(t, "Purges secrets older than the retention", func(tc *helpers.UnitTestCase) {
+		storageService := setupTestStorage(t)
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), storageService)
+		for _, name := range []string{"old", "recent"} {
+			tc.Require.NoError(svc.SaveNewSecret(name, "value"), errCreateSecret)
+			tc.Require.NoError(svc.DeleteSecret(name))
+		}
+
+		// Backdate one deletion past the retention
+		data, err := storageService.ReadSecrets()
+		tc.Require.NoError(err)
+		data.Secrets[0].DeletedAt = time.Now().Add(-31 * 24 * time.Hour).Format(time.RFC3339)
+		tc.Require.NoError(storageService.WriteSecrets(data))
+
+		purged, err := svc.PurgeTrash(30 * 24 * time.Hour)
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]string{"old"}, purged)
+		trash, err := svc.ListTrash()
+		tc.Require.NoError(err)
+		tc.Require.Len(trash, 1)
+		tc.Assert.Equal("recent", trash[0].SecretName)
+	})
+}
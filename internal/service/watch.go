@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"go-password-manager/internal/domain"
+	"sync"
+)
+
+// ErrWatchUnsupported is returned when the storage cannot report changes
+var ErrWatchUnsupported = errors.New("storage does not support watching for changes")
+
+// Watcher is implemented by storages that notice writes made by other processes.
+// Watch calls onChange, from another goroutine, after the stored vault may have changed until stop is called.
+type Watcher interface {
+	Watch(onChange func()) (stop func(), err error)
+}
+
+// Watch calls onChange, from another goroutine, whenever the vault is changed by someone other
+// than this service. Writes made through this service are not reported.
+func (s *SecretsService) Watch(onChange func(domain.VaultChange)) (stop func(), err error) {
+	watcher, ok := s.storage.(Watcher)
+	if !ok {
+		return nil, ErrWatchUnsupported
+	}
+
+	s.mu.RLock()
+	last, lastErr := s.readSecrets()
+	s.mu.RUnlock()
+
+	var watchMu sync.Mutex
+	return watcher.Watch(func() {
+		watchMu.Lock()
+		defer watchMu.Unlock()
+
+		s.mu.RLock()
+		own := s.isOwnWrite()
+		current, err := s.readSecrets()
+		s.mu.RUnlock()
+
+		if err != nil {
+			lastErr = err
+			onChange(domain.VaultChange{Err: err})
+			return
+		}
+		changed := changedSecrets(last.Secrets, current.Secrets)
+		unknown := lastErr != nil
+		last, lastErr = current, nil
+
+		switch {
+		case unknown:
+			// What changed since the last successful read is unknown
+			onChange(domain.VaultChange{})
+		case own, len(changed) == 0:
+		default:
+			onChange(domain.VaultChange{Secrets: changed})
+		}
+	})
+}
+
+// isOwnWrite reports whether the stored vault is still the one this service wrote last
+func (s *SecretsService) isOwnWrite() bool {
+	detector, ok := s.storage.(ChangeDetector)
+	if !ok {
+		return false
+	}
+	stamp, err := detector.Stamp()
+	if err != nil {
+		return false
+	}
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+	return stamp == s.writtenStamp
+}
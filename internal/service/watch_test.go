@@ -0,0 +1,53 @@
+package service_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	helpers.WithUnitTestCase(t, "UnsupportedStorage", func(tc *helpers.UnitTestCase) {
+		svc := service.NewSecretsService(newMockCryptoService([]byte(testdata.TestEncryptionKey)), storage.NewMemoryStorage("1.0.0", testdata.TestUsers.UnitTestUser.Name))
+		_, err := svc.Watch(func(domain.VaultChange) {})
+		tc.Assert.ErrorIs(err, service.ErrWatchUnsupported)
+	})
+
+	helpers.WithUnitTestCase(t, "ReportsOnlyChangesByOthers", func(tc *helpers.UnitTestCase) {
+		key := []byte(testdata.TestEncryptionKey)
+		path := filepath.Join(t.TempDir(), testSecretsFile)
+		window := service.NewSecretsService(newMockCryptoService(key), storage.NewFileStorage(path, "1.0.0", testdata.TestUsers.UnitTestUser.Name))
+		script := service.NewSecretsService(newMockCryptoService(key), storage.NewFileStorage(path, "1.0.0", testdata.TestUsers.UnitTestUser.Name))
+		tc.Require.NoError(window.SaveNewSecret(testdata.TestSecrets.Simple.Name, "value1"))
+
+		changes := make(chan domain.VaultChange, 10)
+		stop, err := window.Watch(func(change domain.VaultChange) { changes <- change })
+		tc.Require.NoError(err)
+		defer stop()
+
+		tc.Require.NoError(window.UpdateSecret(testdata.TestSecrets.Simple.Name, "value2", ""))
+		select {
+		case change := <-changes:
+			tc.Assert.Fail("The service's own write was reported", change.Secrets)
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		tc.Require.NoError(script.UpdateSecret(testdata.TestSecrets.Simple.Name, "value3", ""))
+		select {
+		case change := <-changes:
+			tc.Require.NoError(change.Err)
+			tc.Assert.Equal([]string{testdata.TestSecrets.Simple.Name}, change.Secrets)
+		case <-time.After(5 * time.Second):
+			tc.Assert.Fail("The external write was not reported")
+		}
+
+		value, err := window.GetCurrentVersionValue(testdata.TestSecrets.Simple.Name)
+		tc.Require.NoError(err, errGetSecretValue)
+		tc.Assert.Equal("value3", value, "The service should serve the external change")
+	})
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/service"
+	os "os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// attachmentsSuffix names the directory next to a secrets file that holds its attachments
+const attachmentsSuffix = ".attachments"
+
+// directoryAttachmentsDir holds the attachments inside a vault directory
+const directoryAttachmentsDir = "attachments"
+
+// attachmentDir keeps attachment blobs out of the secrets file, one file per attachment ID.
+// The blobs arrive encrypted, see service.AttachmentStore.
+type attachmentDir string
+
+func (dir attachmentDir) path(id string) (string, error) {
+	if id == "" || strings.Trim(id, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid attachment id: %s", id)
+	}
+	return filepath.Join(string(dir), id), nil
+}
+
+func (dir attachmentDir) put(id string, blob []byte) error {
+	path, err := dir.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(string(dir), 0700); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, blob, 0600)
+}
+
+func (dir attachmentDir) get(id string) ([]byte, error) {
+	path, err := dir.path(id)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", service.ErrAttachmentMissing, id)
+	}
+	return blob, err
+}
+
+func (dir attachmentDir) remove(id string) error {
+	path, err := dir.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PutAttachment stores an attachment next to the secrets file, see service.AttachmentStore
+func (fs *FileStorage) PutAttachment(id string, blob []byte) error {
+	return attachmentDir(fs.filePath+attachmentsSuffix).put(id, blob)
+}
+
+// GetAttachment reads an attachment stored next to the secrets file
+func (fs *FileStorage) GetAttachment(id string) ([]byte, error) {
+	return attachmentDir(fs.filePath + attachmentsSuffix).get(id)
+}
+
+// DeleteAttachment removes an attachment stored next to the secrets file
+func (fs *FileStorage) DeleteAttachment(id string) error {
+	return attachmentDir(fs.filePath + attachmentsSuffix).remove(id)
+}
+
+// PutAttachment stores an attachment in the vault directory, see service.AttachmentStore
+func (ds *DirectoryStorage) PutAttachment(id string, blob []byte) error {
+	return attachmentDir(filepath.Join(ds.dir, directoryAttachmentsDir)).put(id, blob)
+}
+
+// GetAttachment reads an attachment from the vault directory
+func (ds *DirectoryStorage) GetAttachment(id string) ([]byte, error) {
+	return attachmentDir(filepath.Join(ds.dir, directoryAttachmentsDir)).get(id)
+}
+
+// DeleteAttachment removes an attachment from the vault directory
+func (ds *DirectoryStorage) DeleteAttachment(id string) error {
+	return attachmentDir(filepath.Join(ds.dir, directoryAttachmentsDir)).remove(id)
+}
+
+// PutAttachment stores an attachment next to the journal, see service.AttachmentStore
+func (js *JournalStorage) PutAttachment(id string, blob []byte) error {
+	return attachmentDir(js.path+attachmentsSuffix).put(id, blob)
+}
+
+// GetAttachment reads an attachment stored next to the journal
+func (js *JournalStorage) GetAttachment(id string) ([]byte, error) {
+	return attachmentDir(js.path + attachmentsSuffix).get(id)
+}
+
+// DeleteAttachment removes an attachment stored next to the journal
+func (js *JournalStorage) DeleteAttachment(id string) error {
+	return attachmentDir(js.path + attachmentsSuffix).remove(id)
+}
+
+// memoryAttachments holds the attachments of a MemoryStorage
+type memoryAttachments struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+// PutAttachment keeps an attachment in memory, see service.AttachmentStore
+func (ms *MemoryStorage) PutAttachment(id string, blob []byte) error {
+	ms.attachments.mu.Lock()
+	defer ms.attachments.mu.Unlock()
+	if ms.attachments.blobs == nil {
+		ms.attachments.blobs = map[string][]byte{}
+	}
+	ms.attachments.blobs[id] = append([]byte(nil), blob...)
+	return nil
+}
+
+// GetAttachment returns an attachment kept in memory
+func (ms *MemoryStorage) GetAttachment(id string) ([]byte, error) {
+	ms.attachments.mu.Lock()
+	defer ms.attachments.mu.Unlock()
+	blob, ok := ms.attachments.blobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", service.ErrAttachmentMissing, id)
+	}
+	return append([]byte(nil), blob...), nil
+}
+
+// DeleteAttachment forgets an attachment kept in memory
+func (ms *MemoryStorage) DeleteAttachment(id string) error {
+	ms.attachments.mu.Lock()
+	defer ms.attachments.mu.Unlock()
+	delete(ms.attachments.blobs, id)
+	return nil
+}
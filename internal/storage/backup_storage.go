@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+	os "os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupPrefix     = "secrets-"
+	backupExtension  = ".json"
+	backupTimeLayout = "20060102T150405.000000000Z"
+)
+
+// Retention decides which backups are kept. A backup survives if any rule selects it.
+type Retention struct {
+	KeepLast   int // The most recent backups
+	KeepDaily  int // The newest backup of each of the last days that have one
+	KeepWeekly int // The newest backup of each of the last weeks that have one
+}
+
+// protectedStorage is implemented by the storages of this package. Backups are sealed and signed
+// with the crypto service and integrity provider the storage protects the vault with.
+type protectedStorage interface {
+	protection() (service.CryptoService, service.IntegrityProvider)
+}
+
+// BackupStorage snapshots the vault into a directory before every write. A backup is the vault
+// as read, in the secrets file format, so it is as encrypted and signed as the vault itself.
+type BackupStorage struct {
+	inner     service.StorageService
+	dir       string
+	retention Retention
+	crypto    service.CryptoService     // Seals backups when set
+	integrity service.IntegrityProvider // Signs backups when set
+	now       func() time.Time
+}
+
+// NewBackupStorage wraps a storage so that every write is preceded by a backup in dir.
+// The optional interfaces of inner, like service.Locker, are passed through.
+func NewBackupStorage(inner service.StorageService, dir string, retention Retention) (service.StorageService, error) {
+	if _, ok := inner.(*MemoryStorage); ok {
+		return nil, fmt.Errorf("memory storage saves nothing to back up")
+	}
+	protected, ok := inner.(protectedStorage)
+	if !ok {
+		return nil, fmt.Errorf("backups cannot tell how %T protects the vault", inner)
+	}
+	crypto, integrity := protected.protection()
+	return &BackupStorage{inner: inner, dir: dir, retention: retention, crypto: crypto, integrity: integrity, now: time.Now}, nil
+}
+
+func (bs *BackupStorage) ReadSecrets() (domain.SecretsFile, error) {
+	return bs.inner.ReadSecrets()
+}
+
+// WriteSecrets backs up the current vault, prunes old backups and writes data
+func (bs *BackupStorage) WriteSecrets(data domain.SecretsFile) error {
+	unlock, err := bs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := bs.inner.ReadSecrets()
+	if err == nil {
+		err = bs.snapshot(current)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to back up secrets: %w", err)
+	}
+	return bs.inner.WriteSecrets(data)
+}
+
+// Lock takes the exclusive lock of the wrapped storage, if it has one, see service.Locker
+func (bs *BackupStorage) Lock() (func(), error) {
+	if locker, ok := bs.inner.(service.Locker); ok {
+		return locker.Lock()
+	}
+	return func() {}, nil
+}
+
+// Stamp passes through the wrapped storage's stamp, see service.ChangeDetector
+func (bs *BackupStorage) Stamp() (string, error) {
+	detector, ok := bs.inner.(service.ChangeDetector)
+	if !ok {
+		return "", fmt.Errorf("%T cannot detect changes", bs.inner)
+	}
+	return detector.Stamp()
+}
+
+// Watch passes through to the wrapped storage, see service.Watcher
+func (bs *BackupStorage) Watch(onChange func()) (func(), error) {
+	watcher, ok := bs.inner.(service.Watcher)
+	if !ok {
+		return nil, service.ErrWatchUnsupported
+	}
+	return watcher.Watch(onChange)
+}
+
+// History passes through to the wrapped storage, see service.HistoryKeeper
+func (bs *BackupStorage) History() ([]domain.Commit, error) {
+	history, ok := bs.inner.(service.HistoryKeeper)
+	if !ok {
+		return nil, service.ErrHistoryUnsupported
+	}
+	return history.History()
+}
+
+// ReadSecretsAt passes through to the wrapped storage, see service.HistoryKeeper
+func (bs *BackupStorage) ReadSecretsAt(commit string) (domain.SecretsFile, error) {
+	history, ok := bs.inner.(service.HistoryKeeper)
+	if !ok {
+		return domain.SecretsFile{}, service.ErrHistoryUnsupported
+	}
+	return history.ReadSecretsAt(commit)
+}
+
+// PutAttachment passes through to the wrapped storage, see service.AttachmentStore
+func (bs *BackupStorage) PutAttachment(id string, blob []byte) error {
+	store, err := bs.attachmentStore()
+	if err != nil {
+		return err
+	}
+	return store.PutAttachment(id, blob)
+}
+
+// GetAttachment passes through to the wrapped storage, see service.AttachmentStore
+func (bs *BackupStorage) GetAttachment(id string) ([]byte, error) {
+	store, err := bs.attachmentStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAttachment(id)
+}
+
+// DeleteAttachment passes through to the wrapped storage, see service.AttachmentStore
+func (bs *BackupStorage) DeleteAttachment(id string) error {
+	store, err := bs.attachmentStore()
+	if err != nil {
+		return err
+	}
+	return store.DeleteAttachment(id)
+}
+
+func (bs *BackupStorage) attachmentStore() (service.AttachmentStore, error) {
+	store, ok := bs.inner.(service.AttachmentStore)
+	if !ok {
+		return nil, service.ErrAttachmentsUnsupported
+	}
+	return store, nil
+}
+
+// ListBackups returns the backups in the directory, newest first
+func (bs *BackupStorage) ListBackups() ([]domain.Backup, error) {
+	entries, err := os.ReadDir(bs.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []domain.Backup
+	for _, entry := range entries {
+		createdAt, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, domain.Backup{
+			ID:        strings.TrimSuffix(entry.Name(), backupExtension),
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// RestoreBackup verifies a backup and writes it as the current vault
+func (bs *BackupStorage) RestoreBackup(id string) error {
+	if _, ok := parseBackupName(id + backupExtension); !ok {
+		return fmt.Errorf("invalid backup id: %s", id)
+	}
+
+	unlock, err := bs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	raw, err := os.ReadFile(bs.backupPath(id))
+	if err != nil {
+		return err
+	}
+	doc, err := bs.decode(raw)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	// A vault that fails to open is what backups are restored for, the signature check still applies
+	current, readErr := bs.inner.ReadSecrets()
+	if readErr == nil && keyIDOf(doc) != current.KeyID {
+		return service.ErrBackupKeyRotated
+	}
+	if err := bs.verifyBackup(doc); err != nil {
+		return fmt.Errorf("backup %s: %w", id, err)
+	}
+	data, err := migrate(doc)
+	if err != nil {
+		return err
+	}
+
+	if readErr != nil {
+		logger.Warn("The replaced vault cannot be read and is not backed up:", readErr.Error())
+	} else if err := bs.snapshot(current); err != nil {
+		return fmt.Errorf("failed to back up secrets: %w", err)
+	}
+	logger.Info("Restoring backup", id)
+	return bs.inner.WriteSecrets(data)
+}
+
+// verifyBackup checks the signature of a backup. Older generations are expected here,
+// unsigned backups are only accepted while the vault itself has never been signed.
+func (bs *BackupStorage) verifyBackup(doc map[string]any) error {
+	if bs.integrity == nil {
+		return nil
+	}
+	if doc["mac"] != nil {
+		return verifyFileMAC(bs.integrity, doc)
+	}
+	stored, err := bs.integrity.Generation()
+	if err != nil {
+		return err
+	}
+	if stored > 0 {
+		return fmt.Errorf("%w: signature is missing", service.ErrVaultTampered)
+	}
+	return nil
+}
+
+// snapshot writes data into the backup directory. A vault without secrets has nothing to lose.
+func (bs *BackupStorage) snapshot(data domain.SecretsFile) error {
+	if len(data.Secrets) == 0 {
+		return nil
+	}
+	raw, err := bs.encode(data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(bs.dir, 0700); err != nil {
+		return err
+	}
+	id := backupPrefix + bs.now().UTC().Format(backupTimeLayout)
+	if err := fsutil.WriteFileAtomic(bs.backupPath(id), raw, 0600); err != nil {
+		return err
+	}
+	return bs.prune()
+}
+
+// encode signs data, keeping its generation, and serializes it like a secrets file
+func (bs *BackupStorage) encode(data domain.SecretsFile) ([]byte, error) {
+	data.MAC = ""
+	if bs.integrity != nil {
+		doc, err := documentOf(data)
+		if err != nil {
+			return nil, err
+		}
+		mac, err := fileMAC(bs.integrity, doc)
+		if err != nil {
+			return nil, err
+		}
+		data.MAC = base64.StdEncoding.EncodeToString(mac)
+	}
+	return encodeDocument(bs.crypto, data, data.KeyID)
+}
+
+// decode parses a plaintext or sealed backup into its generic form
+func (bs *BackupStorage) decode(raw []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := decodeDocument(bs.crypto, raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, checkSchema(doc)
+}
+
+// prune removes backups that no retention rule selects
+func (bs *BackupStorage) prune() error {
+	backups, err := bs.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	keep := bs.retention.retain(backups)
+	for _, backup := range backups {
+		if keep[backup.ID] {
+			continue
+		}
+		if err := os.Remove(bs.backupPath(backup.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bs *BackupStorage) backupPath(id string) string {
+	return filepath.Join(bs.dir, id+backupExtension)
+}
+
+// retain returns the IDs of the backups to keep, given backups sorted newest first
+func (r Retention) retain(backups []domain.Backup) map[string]bool {
+	keep := map[string]bool{}
+	for i := 0; i < len(backups) && i < r.KeepLast; i++ {
+		keep[backups[i].ID] = true
+	}
+
+	keepNewestPerPeriod(backups, r.KeepDaily, keep, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(backups, r.KeepWeekly, keep, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	return keep
+}
+
+// keepNewestPerPeriod marks the newest backup of each of the last count periods
+func keepNewestPerPeriod(backups []domain.Backup, count int, keep map[string]bool, period func(time.Time) string) {
+	seen := map[string]bool{}
+	for _, backup := range backups {
+		if len(seen) >= count {
+			return
+		}
+		p := period(backup.CreatedAt.Local())
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		keep[backup.ID] = true
+	}
+}
+
+// parseBackupName returns the creation time encoded in a backup file name
+func parseBackupName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExtension) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupExtension)
+	createdAt, err := time.Parse(backupTimeLayout, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
@@ -0,0 +1,215 @@
+package storage_test
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBackupStorage(tc *helpers.UnitTestCase, dir string, integrity service.IntegrityProvider, retention storage.Retention) service.StorageService {
+	inner := storage.NewFileStorageWithIntegrity(filepath.Join(dir, "secrets.json"), TestFileStorageVersion, TestFileStorageUser, integrity)
+	store, err := storage.NewBackupStorage(inner, filepath.Join(dir, "backups"), retention)
+	tc.Require.NoError(err)
+	return store
+}
+
+func secretsWith(names ...string) domain.SecretsFile {
+	data := domain.SecretsFile{AppVersion: TestFileStorageVersion, AppUser: TestFileStorageUser}
+	for _, name := range names {
+		data.Secrets = append(data.Secrets, domain.Secret{SecretName: name, CurrentVersion: 1})
+	}
+	return data
+}
+
+func TestBackupStorage(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Backs up the previous file on every write", func(tc *helpers.UnitTestCase) {
+		store := newBackupStorage(tc, t.TempDir(), &memoryIntegrity{}, storage.Retention{KeepLast: 10})
+		manager := store.(service.BackupManager)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		backups, err := manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Assert.Empty(backups, "There is nothing to back up before the first write")
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second", "third")))
+		backups, err = manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 2)
+		tc.Assert.True(backups[0].CreatedAt.After(backups[1].CreatedAt), "Backups should be listed newest first")
+		tc.Assert.Positive(backups[0].Size)
+	})
+
+	helpers.WithUnitTestCase(t, "Prunes backups beyond the retention", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := newBackupStorage(tc, dir, nil, storage.Retention{KeepLast: 2})
+
+		for i := 0; i < 5; i++ {
+			tc.Require.NoError(store.WriteSecrets(secretsWith(testdata.TestSecrets.Simple.Name)))
+		}
+		entries, err := os.ReadDir(filepath.Join(dir, "backups"))
+		tc.Require.NoError(err)
+		tc.Assert.Len(entries, 2)
+	})
+
+	helpers.WithUnitTestCase(t, "Restores a backup and backs up the replaced vault", func(tc *helpers.UnitTestCase) {
+		integrity := &memoryIntegrity{}
+		store := newBackupStorage(tc, t.TempDir(), integrity, storage.Retention{KeepLast: 10})
+		manager := store.(service.BackupManager)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		backups, err := manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 1)
+
+		tc.Require.NoError(manager.RestoreBackup(backups[0].ID))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err, "A restored backup should pass the integrity checks")
+		tc.Require.Len(readBack.Secrets, 1)
+		tc.Assert.Equal("first", readBack.Secrets[0].SecretName)
+		tc.Assert.Equal(uint64(3), integrity.generation, "Restoring is a new write, not a rollback")
+
+		backups, err = manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Assert.Len(backups, 2, "The replaced vault should be backed up")
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses tampered backups", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := newBackupStorage(tc, dir, &memoryIntegrity{}, storage.Retention{KeepLast: 10})
+		manager := store.(service.BackupManager)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("second")))
+		backups, err := manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 1)
+
+		path := filepath.Join(dir, "backups", backups[0].ID+".json")
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Require.NoError(os.WriteFile(path, []byte(strings.Replace(string(raw), "first", "forged", 1)), 0600))
+
+		err = manager.RestoreBackup(backups[0].ID)
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered)
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses backups from a rotated key", func(tc *helpers.UnitTestCase) {
+		store := newBackupStorage(tc, t.TempDir(), nil, storage.Retention{KeepLast: 10})
+		manager := store.(service.BackupManager)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		rotated := secretsWith("first")
+		rotated.KeyID = "new-key"
+		tc.Require.NoError(store.WriteSecrets(rotated))
+		backups, err := manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 1)
+
+		tc.Assert.ErrorIs(manager.RestoreBackup(backups[0].ID), service.ErrBackupKeyRotated)
+	})
+
+	helpers.WithUnitTestCase(t, "Backs up any storage sealed and signed like the vault", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		crypto := newSigningCrypto()
+		inner := storage.NewDirectoryStorage(filepath.Join(dir, "vault"), TestFileStorageVersion, TestFileStorageUser, crypto)
+		store, err := storage.NewBackupStorage(inner, filepath.Join(dir, "backups"), storage.Retention{KeepLast: 10})
+		tc.Require.NoError(err)
+		manager := store.(service.BackupManager)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("second")))
+		backups, err := manager.ListBackups()
+		tc.Require.NoError(err)
+		tc.Require.Len(backups, 1)
+		raw, err := os.ReadFile(filepath.Join(dir, "backups", backups[0].ID+".json"))
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), "first", "The backup should be sealed like the vault")
+
+		tc.Require.NoError(manager.RestoreBackup(backups[0].ID))
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.Len(readBack.Secrets, 1)
+		tc.Assert.Equal("first", readBack.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Passes through what the wrapped storage supports", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		inner := storage.NewJournalStorage(filepath.Join(dir, "secrets.json"), TestFileStorageVersion, TestFileStorageUser, nil, 0)
+		store, err := storage.NewBackupStorage(inner, filepath.Join(dir, "backups"), storage.Retention{KeepLast: 10})
+		tc.Require.NoError(err)
+
+		unlock, err := store.(service.Locker).Lock()
+		tc.Require.NoError(err)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")), "Writes nest inside the lock like the wrapped storage's do")
+		unlock()
+
+		tc.Require.NoError(store.(service.AttachmentStore).PutAttachment("a1", []byte("blob")))
+		blob, err := store.(service.AttachmentStore).GetAttachment("a1")
+		tc.Require.NoError(err)
+		tc.Assert.Equal([]byte("blob"), blob)
+
+		_, err = store.(service.HistoryKeeper).History()
+		tc.Assert.ErrorIs(err, service.ErrHistoryUnsupported)
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses the memory storage", func(tc *helpers.UnitTestCase) {
+		_, err := storage.NewBackupStorage(storage.NewMemoryStorage(TestFileStorageVersion, TestFileStorageUser), t.TempDir(), storage.Retention{KeepLast: 10})
+		tc.Assert.Error(err)
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects invalid backup IDs", func(tc *helpers.UnitTestCase) {
+		store := newBackupStorage(tc, t.TempDir(), nil, storage.Retention{KeepLast: 10})
+		tc.Assert.Error(store.(service.BackupManager).RestoreBackup("../secrets"))
+	})
+}
+
+// hourlyBackups returns count backups taken every hour before now, newest first
+func hourlyBackups(now time.Time, count int) []domain.Backup {
+	backups := make([]domain.Backup, count)
+	for i := range backups {
+		backups[i] = domain.Backup{ID: fmt.Sprintf("b%d", i), CreatedAt: now.Add(-time.Duration(i) * time.Hour)}
+	}
+	return backups
+}
+
+func TestBackupRetention(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+
+	helpers.WithUnitTestCase(t, "KeepLast keeps the newest backups", func(tc *helpers.UnitTestCase) {
+		keep := storage.RetainBackups(storage.Retention{KeepLast: 3}, hourlyBackups(now, 10))
+		tc.Assert.Equal(map[string]bool{"b0": true, "b1": true, "b2": true}, keep)
+	})
+
+	helpers.WithUnitTestCase(t, "KeepDaily keeps the newest backup of each day", func(tc *helpers.UnitTestCase) {
+		// Hourly backups from noon back: today has 13, the days before 24 each
+		keep := storage.RetainBackups(storage.Retention{KeepDaily: 3}, hourlyBackups(now, 72))
+		tc.Assert.Equal(map[string]bool{"b0": true, "b13": true, "b37": true}, keep)
+	})
+
+	helpers.WithUnitTestCase(t, "KeepWeekly keeps the newest backup of each week", func(tc *helpers.UnitTestCase) {
+		backups := []domain.Backup{
+			{ID: "friday", CreatedAt: now},
+			{ID: "monday", CreatedAt: now.AddDate(0, 0, -4)},
+			{ID: "last-week", CreatedAt: now.AddDate(0, 0, -7)},
+			{ID: "two-weeks-ago", CreatedAt: now.AddDate(0, 0, -14)},
+		}
+		keep := storage.RetainBackups(storage.Retention{KeepWeekly: 2}, backups)
+		tc.Assert.Equal(map[string]bool{"friday": true, "last-week": true}, keep)
+	})
+
+	helpers.WithUnitTestCase(t, "Rules combine", func(tc *helpers.UnitTestCase) {
+		keep := storage.RetainBackups(storage.Retention{KeepLast: 1, KeepDaily: 2}, hourlyBackups(now, 48))
+		tc.Assert.Equal(map[string]bool{"b0": true, "b13": true}, keep)
+	})
+}
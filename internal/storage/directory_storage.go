@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/service"
+	os "os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	directoryIndexFile  = "vault.json"
+	directorySecretsDir = "secrets"
+	secretFileExtension = ".json"
+)
+
+// directoryIndex is the vault.json of a directory vault: the file metadata and the order of the secrets
+type directoryIndex struct {
+	domain.SecretsFile
+	Secrets []string `json:"secrets"` // File IDs in order, shadows the embedded secrets
+}
+
+// DirectoryStorage keeps the vault in a directory with one file per secret, so a change only
+// touches the files of the secrets involved and diffs and sync tools stay small for large vaults:
+//
+//	vault.json          metadata, signature and the order of the secrets
+//	secrets/<id>.json   one secret with all its versions, see secretFileID
+//
+// Secret files are never overwritten: a changed secret is written to a new file and the atomic
+// replacement of the index commits the write, so a crash leaves the previous vault intact.
+// When a crypto service is given every file is sealed like NewEncryptedFileStorage does.
+type DirectoryStorage struct {
+	dir        string
+	appVersion string
+	appUser    string
+	crypto     service.CryptoService
+	integrity  service.IntegrityProvider
+	lock       *vaultLock
+	readFile   func(path string) ([]byte, error) // Reads a file of the vault, os.ReadFile unless reading history
+}
+
+// NewDirectoryStorage creates a directory storage rooted at dir. crypto may be nil to keep the
+// files readable; secret values are encrypted either way.
+func NewDirectoryStorage(dir, appVersion, appUser string, crypto service.CryptoService, opts ...Option) service.StorageService {
+	ds := &DirectoryStorage{
+		dir:        dir,
+		appVersion: appVersion,
+		appUser:    appUser,
+		crypto:     crypto,
+		lock:       newVaultLock(filepath.Join(dir, "vault"), opts),
+		readFile:   os.ReadFile,
+	}
+	if integrity, ok := crypto.(service.IntegrityProvider); ok {
+		ds.integrity = integrity
+	}
+	return ds
+}
+
+func (ds *DirectoryStorage) ReadSecrets() (domain.SecretsFile, error) {
+	unlock, err := ds.acquire(false)
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	defer unlock()
+
+	doc, err := ds.load()
+	if os.IsNotExist(err) {
+		if ds.integrity != nil {
+			if err := verifyMissingFile(ds.integrity); err != nil {
+				return domain.SecretsFile{}, err
+			}
+		}
+		return domain.SecretsFile{
+			SchemaVersion: domain.CurrentSchemaVersion,
+			AppVersion:    ds.appVersion,
+			AppUser:       ds.appUser,
+			Secrets:       []domain.Secret{},
+		}, nil
+	}
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+
+	if ds.integrity != nil {
+		if err := verifyFile(ds.integrity, doc, 0); err != nil {
+			return domain.SecretsFile{}, err
+		}
+	}
+	return migrate(doc)
+}
+
+// WriteSecrets writes the files of changed secrets, then the index, then removes the files the
+// index no longer lists
+func (ds *DirectoryStorage) WriteSecrets(data domain.SecretsFile) error {
+	unlock, err := ds.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	previous, err := ds.readIndex()
+	if errors.Is(err, service.ErrSchemaTooNew) {
+		return err
+	}
+	previousFiles := secretFilesOf(previous)
+
+	data.SchemaVersion = domain.CurrentSchemaVersion
+	data.LastUpdated = time.Now().Format(time.RFC3339)
+	if ds.integrity != nil {
+		if err := signFile(ds.integrity, &data); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(ds.dir, directorySecretsDir), 0700); err != nil {
+		return err
+	}
+
+	index := directoryIndex{SecretsFile: data}
+	index.SecretsFile.Secrets = nil
+	if data.Secrets != nil {
+		index.Secrets = make([]string, 0, len(data.Secrets))
+	}
+	names := map[string]bool{}
+	referenced := map[string]bool{}
+	for _, secret := range data.Secrets {
+		nameID := secretNameID(secret.SecretName)
+		if names[nameID] {
+			return fmt.Errorf("duplicate secret name '%s'", secret.SecretName)
+		}
+		names[nameID] = true
+
+		id, err := ds.writeSecret(previousFiles[nameID], secret, data.KeyID)
+		if err != nil {
+			return err
+		}
+		referenced[id] = true
+		index.Secrets = append(index.Secrets, id)
+	}
+
+	raw, err := encodeDocument(ds.crypto, index, data.KeyID)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(ds.dir, directoryIndexFile), raw, 0600); err != nil {
+		return err
+	}
+	if err := ds.removeUnreferenced(referenced); err != nil {
+		return err
+	}
+
+	if ds.integrity != nil {
+		return ds.integrity.SetGeneration(data.Generation)
+	}
+	return nil
+}
+
+// Stamp identifies the current state of the vault, see service.ChangeDetector.
+// The index is rewritten on every write, so its state covers the whole directory.
+func (ds *DirectoryStorage) Stamp() (string, error) {
+	return fileStamp(filepath.Join(ds.dir, directoryIndexFile), ds.integrity)
+}
+
+// Lock takes the exclusive lock, see service.Locker
+func (ds *DirectoryStorage) Lock() (func(), error) {
+	return ds.acquire(true)
+}
+
+// protection returns what the files are sealed and signed with, see BackupStorage
+func (ds *DirectoryStorage) protection() (service.CryptoService, service.IntegrityProvider) {
+	return ds.crypto, ds.integrity
+}
+
+// acquire takes the vault lock, which lives inside the directory
+func (ds *DirectoryStorage) acquire(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(ds.dir, 0700); err != nil {
+		return nil, err
+	}
+	return ds.lock.acquire(exclusive)
+}
+
+// load reads the index and the secrets it lists into the generic form of the secrets file,
+// without verifying or migrating it
+func (ds *DirectoryStorage) load() (map[string]any, error) {
+	doc, err := ds.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	ids, ok := doc["secrets"].([]any)
+	if !ok {
+		// The index of a vault without secrets lists none
+		return doc, nil
+	}
+	secrets := make([]any, 0, len(ids))
+	for _, id := range ids {
+		id, ok := id.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid secret file ID %v", service.ErrVaultTampered, id)
+		}
+		secret, err := ds.readSecret(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file %s: %w", id, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	doc["secrets"] = secrets
+	return doc, nil
+}
+
+// readIndex reads vault.json, whose secrets are the file IDs of the secrets, see directoryIndex
+func (ds *DirectoryStorage) readIndex() (map[string]any, error) {
+	raw, err := ds.readFile(filepath.Join(ds.dir, directoryIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := decodeDocument(ds.crypto, raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, checkSchema(doc)
+}
+
+func (ds *DirectoryStorage) readSecret(id string) (map[string]any, error) {
+	raw, err := ds.readFile(ds.secretPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var secret map[string]any
+	if err := decodeDocument(ds.crypto, raw, &secret); err != nil {
+		return nil, err
+	}
+	// A file moved to another name would otherwise replace that secret
+	if name, _ := secret["secretName"].(string); secretNameID(name) != nameIDOf(id) {
+		return nil, fmt.Errorf("%w: secret file %s holds '%s'", service.ErrVaultTampered, id, name)
+	}
+	return secret, nil
+}
+
+// writeSecret stores secret in a new file unless the file previousID already holds the same
+// contents, and returns the ID of the file holding it
+func (ds *DirectoryStorage) writeSecret(previousID string, secret domain.Secret, keyID string) (string, error) {
+	if previousID != "" {
+		if raw, err := os.ReadFile(ds.secretPath(previousID)); err == nil && ds.holds(raw, secret, keyID) {
+			return previousID, nil
+		}
+	}
+
+	raw, err := encodeDocument(ds.crypto, secret, keyID)
+	if err != nil {
+		return "", err
+	}
+	id := secretFileID(secret.SecretName, raw)
+	return id, fsutil.WriteFileAtomic(ds.secretPath(id), raw, 0600)
+}
+
+// holds reports whether raw is secret, stored the way this storage would write it now
+func (ds *DirectoryStorage) holds(raw []byte, secret domain.Secret, keyID string) bool {
+	container, sealed := parseContainer(raw)
+	if sealed != (ds.crypto != nil) || (sealed && container.KeyID != keyID) {
+		return false
+	}
+	var existing domain.Secret
+	if err := decodeDocument(ds.crypto, raw, &existing); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(existing, secret)
+}
+
+// removeUnreferenced deletes the files of secrets that are no longer in the index
+func (ds *DirectoryStorage) removeUnreferenced(referenced map[string]bool) error {
+	entries, err := os.ReadDir(filepath.Join(ds.dir, directorySecretsDir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, secretFileExtension) {
+			continue
+		}
+		if referenced[strings.TrimSuffix(name, secretFileExtension)] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ds.dir, directorySecretsDir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ds *DirectoryStorage) secretPath(id string) string {
+	return filepath.Join(ds.dir, directorySecretsDir, id+secretFileExtension)
+}
+
+// secretFileID names a file of a secret: the ID of its name followed by a hash of the file, so
+// every change gets a new file. Vaults written before kept the ID of the name alone.
+func secretFileID(name string, raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return secretNameID(name) + "-" + hex.EncodeToString(sum[:8])
+}
+
+// secretNameID identifies a secret without putting its name on disk
+func secretNameID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:16])
+}
+
+// nameIDOf returns the ID of the name of the secret in the file id
+func nameIDOf(id string) string {
+	nameID, _, _ := strings.Cut(id, "-")
+	return nameID
+}
+
+// secretFilesOf maps the name IDs of the secrets listed by an index to their files
+func secretFilesOf(index map[string]any) map[string]string {
+	files := map[string]string{}
+	ids, _ := index["secrets"].([]any)
+	for _, id := range ids {
+		if id, ok := id.(string); ok {
+			files[nameIDOf(id)] = id
+		}
+	}
+	return files
+}
@@ -0,0 +1,190 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// signingCrypto seals files and signs the vault, like the real crypto service
+type signingCrypto struct {
+	containerCrypto
+	memoryIntegrity
+}
+
+func newSigningCrypto() *signingCrypto {
+	return &signingCrypto{containerCrypto: containerCrypto{key: []byte(testdata.TestEncryptionKey)}}
+}
+
+func secretFiles(tc *helpers.UnitTestCase, dir string) map[string]os.FileInfo {
+	entries, err := os.ReadDir(filepath.Join(dir, "secrets"))
+	tc.Require.NoError(err)
+	files := map[string]os.FileInfo{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		tc.Require.NoError(err)
+		files[entry.Name()] = info
+	}
+	return files
+}
+
+func TestDirectoryStorage(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Stores one sealed file per secret", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto())
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second", "third")))
+		files := secretFiles(tc, dir)
+		tc.Assert.Len(files, 3)
+
+		for name := range files {
+			raw, err := os.ReadFile(filepath.Join(dir, "secrets", name))
+			tc.Require.NoError(err)
+			tc.Assert.NotContains(string(raw), "first")
+			tc.Assert.NotContains(string(raw), "second")
+		}
+		index, err := os.ReadFile(filepath.Join(dir, "vault.json"))
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(index), TestFileStorageUser, "The index should be sealed too")
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.Len(readBack.Secrets, 3)
+		tc.Assert.Equal([]string{"first", "second", "third"}, []string{
+			readBack.Secrets[0].SecretName, readBack.Secrets[1].SecretName, readBack.Secrets[2].SecretName,
+		}, "The order of the secrets should be kept")
+		tc.Assert.Equal(domain.CurrentSchemaVersion, readBack.SchemaVersion)
+	})
+
+	helpers.WithUnitTestCase(t, "Only rewrites changed secrets", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto())
+
+		data := secretsWith("first", "second")
+		tc.Require.NoError(store.WriteSecrets(data))
+		before := secretFiles(tc, dir)
+
+		data.Secrets[1].CurrentVersion = 2
+		tc.Require.NoError(store.WriteSecrets(data))
+		after := secretFiles(tc, dir)
+
+		changed := 0
+		for name, info := range after {
+			if !os.SameFile(before[name], info) {
+				changed++
+			}
+		}
+		tc.Assert.Equal(1, changed, "Only the updated secret's file should be replaced")
+	})
+
+	helpers.WithUnitTestCase(t, "Removes the files of deleted secrets", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, nil)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("second")))
+		tc.Assert.Len(secretFiles(tc, dir), 1)
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.Len(readBack.Secrets, 1)
+		tc.Assert.Equal("second", readBack.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Keeps the previous vault when a write stops before the index", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto())
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+
+		// The duplicate fails the write after the changed secret's file was written
+		changed := secretsWith("first", "second", "first")
+		changed.Secrets[0].CurrentVersion = 2
+		tc.Require.Error(store.WriteSecrets(changed))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err, "The old index should still find its secret files")
+		tc.Assert.Equal(1, readBack.Secrets[0].CurrentVersion)
+	})
+
+	helpers.WithUnitTestCase(t, "Reads secret files named before their contents were hashed", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, nil)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		// Rename the file to the ID of the name alone, as earlier versions did
+		raw, err := os.ReadFile(filepath.Join(dir, "vault.json"))
+		tc.Require.NoError(err)
+		for name := range secretFiles(tc, dir) {
+			id := strings.TrimSuffix(name, ".json")
+			legacy, _, _ := strings.Cut(id, "-")
+			tc.Require.NoError(os.Rename(filepath.Join(dir, "secrets", name), filepath.Join(dir, "secrets", legacy+".json")))
+			raw = []byte(strings.Replace(string(raw), id, legacy, 1))
+		}
+		tc.Require.NoError(os.WriteFile(filepath.Join(dir, "vault.json"), raw, 0600))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.Len(readBack.Secrets, 1)
+		tc.Assert.Equal("first", readBack.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Detects secret files swapped on disk", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, nil)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+
+		var names []string
+		for name := range secretFiles(tc, dir) {
+			names = append(names, filepath.Join(dir, "secrets", name))
+		}
+		first, err := os.ReadFile(names[0])
+		tc.Require.NoError(err)
+		tc.Require.NoError(os.WriteFile(names[1], first, 0600))
+
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered)
+	})
+
+	helpers.WithUnitTestCase(t, "Detects secret files replaced by another copy", func(tc *helpers.UnitTestCase) {
+		dir, otherDir := t.TempDir(), t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto())
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		// A copy of the same secret, sealed with the same key but with other contents
+		other := storage.NewDirectoryStorage(otherDir, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto())
+		forged := secretsWith("first")
+		forged.Secrets[0].CurrentVersion = 7
+		tc.Require.NoError(other.WriteSecrets(forged))
+
+		var raw []byte
+		for name := range secretFiles(tc, otherDir) {
+			var err error
+			raw, err = os.ReadFile(filepath.Join(otherDir, "secrets", name))
+			tc.Require.NoError(err)
+		}
+		for name := range secretFiles(tc, dir) {
+			tc.Require.NoError(os.WriteFile(filepath.Join(dir, "secrets", name), raw, 0600))
+		}
+
+		_, err := store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered)
+	})
+
+	helpers.WithUnitTestCase(t, "Fails when a secret file is missing", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := storage.NewDirectoryStorage(dir, TestFileStorageVersion, TestFileStorageUser, nil)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		for name := range secretFiles(tc, dir) {
+			tc.Require.NoError(os.Remove(filepath.Join(dir, "secrets", name)))
+		}
+		_, err := store.ReadSecrets()
+		tc.Assert.ErrorContains(err, "secret file")
+	})
+}
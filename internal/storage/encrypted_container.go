@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+)
+
+// containerFormat identifies a secrets file that is sealed as a whole
+const (
+	containerFormat  = "go-password-manager/encrypted"
+	containerVersion = 1
+)
+
+// ErrEncryptedContainer is returned when an encrypted secrets file is opened without a crypto service
+var ErrEncryptedContainer = errors.New("secrets file is encrypted, enable storage.encrypt_metadata to open it")
+
+// containerHeader is the plaintext part of an encrypted secrets file. It only says how to open
+// the payload: the key ID names the key file, which in turn holds the KDF parameters.
+type containerHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	KeyID   string `json:"keyId,omitempty"`
+}
+
+// encryptedContainer is the on-disk layout of an encrypted secrets file
+type encryptedContainer struct {
+	containerHeader
+	Payload string `json:"payload"`
+}
+
+// NewEncryptedFileStorage creates a file storage that seals the entire secrets file, including
+// secret names, types and timestamps, with the active key of crypto.
+// Plaintext files are still read and are encrypted on the next write.
+// If crypto is also an IntegrityProvider, rolled-back files are detected as well.
+func NewEncryptedFileStorage(filePath, appVersion, appUser string, crypto service.CryptoService, opts ...Option) service.StorageService {
+	fs := newFileStorage(filePath, appVersion, appUser, opts)
+	fs.crypto = crypto
+	if integrity, ok := crypto.(service.IntegrityProvider); ok {
+		fs.integrity = integrity
+	}
+	return fs
+}
+
+// parseContainer reports whether raw is an encrypted container
+func parseContainer(raw []byte) (encryptedContainer, bool) {
+	var container encryptedContainer
+	if err := json.Unmarshal(raw, &container); err != nil || container.Format != containerFormat {
+		return encryptedContainer{}, false
+	}
+	return container, true
+}
+
+// associatedData binds the payload to its header so the header cannot be altered
+func (h containerHeader) associatedData() []byte {
+	// Marshalling a struct of strings and ints cannot fail
+	ad, _ := json.Marshal(h)
+	return ad
+}
+
+func (fs *FileStorage) sealContainer(data domain.SecretsFile) ([]byte, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return sealDocument(fs.crypto, plaintext, data.KeyID)
+}
+
+func (fs *FileStorage) openContainer(container encryptedContainer) (map[string]any, error) {
+	plaintext, err := openDocument(fs.crypto, container)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(plaintext)
+}
+
+// sealDocument encrypts a JSON document into a container with the active key of crypto
+func sealDocument(crypto service.CryptoService, plaintext []byte, keyID string) ([]byte, error) {
+	header := containerHeader{Format: containerFormat, Version: containerVersion, KeyID: keyID}
+	payload, err := crypto.EncryptWithAD(plaintext, crypto.GetKey(), header.associatedData())
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(encryptedContainer{containerHeader: header, Payload: string(payload)}, "", "  ")
+}
+
+// openDocument decrypts the JSON document in a container
+func openDocument(crypto service.CryptoService, container encryptedContainer) ([]byte, error) {
+	if crypto == nil {
+		return nil, ErrEncryptedContainer
+	}
+	if container.Version != containerVersion {
+		return nil, fmt.Errorf("unsupported encrypted secrets file version %d", container.Version)
+	}
+
+	plaintext, err := crypto.DecryptWithAD([]byte(container.Payload), crypto.GetKey(), container.containerHeader.associatedData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file: %w", err)
+	}
+	return plaintext, nil
+}
+
+// encodeDocument serializes v as indented JSON, or as a container when crypto is set
+func encodeDocument(crypto service.CryptoService, v any, keyID string) ([]byte, error) {
+	if crypto == nil {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return sealDocument(crypto, plaintext, keyID)
+}
+
+// decodeDocument parses a plaintext or sealed document into v, see unmarshalDocument
+func decodeDocument(crypto service.CryptoService, raw []byte, v any) error {
+	if container, ok := parseContainer(raw); ok {
+		plaintext, err := openDocument(crypto, container)
+		if err != nil {
+			return err
+		}
+		raw = plaintext
+	}
+	return unmarshalDocument(raw, v)
+}
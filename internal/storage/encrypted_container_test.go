@@ -0,0 +1,94 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/crypto"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// containerCrypto seals containers with the crypto package and a fixed key
+type containerCrypto struct {
+	key []byte
+}
+
+func (c *containerCrypto) GetKey() []byte { return c.key }
+
+func (c *containerCrypto) Encrypt(data, key []byte) ([]byte, error) {
+	return c.EncryptWithAD(data, key, nil)
+}
+
+func (c *containerCrypto) Decrypt(data, key []byte) ([]byte, error) {
+	return c.DecryptWithAD(data, key, nil)
+}
+
+func (c *containerCrypto) EncryptWithAD(data, key, ad []byte) ([]byte, error) {
+	s, err := crypto.EncryptWithOptions(data, key, crypto.EncryptOptions{AdditionalData: ad})
+	return []byte(s), err
+}
+
+func (c *containerCrypto) DecryptWithAD(data, key, ad []byte) ([]byte, error) {
+	return crypto.DecryptWithAD(string(data), key, ad)
+}
+
+func TestEncryptedContainer(t *testing.T) {
+	secretsData := domain.SecretsFile{
+		SchemaVersion: domain.CurrentSchemaVersion,
+		AppVersion:    TestFileStorageVersion,
+		AppUser:       TestFileStorageUser,
+		Secrets: []domain.Secret{{
+			SecretName:     testdata.TestSecrets.Simple.Name,
+			Type:           domain.SecretTypeKeyValue,
+			CurrentVersion: 1,
+			Versions:       []domain.SecretVersion{{Version: 1, SecretValueEnc: "ciphertext"}},
+		}},
+	}
+
+	helpers.WithUnitTestCase(t, "Hides metadata and reads it back", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewEncryptedFileStorage(path, TestFileStorageVersion, TestFileStorageUser, &containerCrypto{key: []byte(testdata.TestEncryptionKey)})
+
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), testdata.TestSecrets.Simple.Name, "Secret names should not be stored in cleartext")
+		tc.Assert.NotContains(string(raw), TestFileStorageUser, "App user should not be stored in cleartext")
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		readBack.LastUpdated = ""
+		tc.Assert.Equal(secretsData, readBack)
+	})
+
+	helpers.WithUnitTestCase(t, "Reads plaintext files and encrypts them on write", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		tc.Require.NoError(storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser).WriteSecrets(secretsData))
+
+		store := storage.NewEncryptedFileStorage(path, TestFileStorageVersion, TestFileStorageUser, &containerCrypto{key: []byte(testdata.TestEncryptionKey)})
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(secretsData.Secrets, readBack.Secrets)
+
+		tc.Require.NoError(store.WriteSecrets(readBack))
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.False(strings.Contains(string(raw), testdata.TestSecrets.Simple.Name), "File should be encrypted after the next write")
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses to open with the wrong key or without encryption", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		tc.Require.NoError(storage.NewEncryptedFileStorage(path, TestFileStorageVersion, TestFileStorageUser, &containerCrypto{key: []byte(testdata.TestEncryptionKey)}).WriteSecrets(secretsData))
+
+		_, err := storage.NewEncryptedFileStorage(path, TestFileStorageVersion, TestFileStorageUser, &containerCrypto{key: []byte(testdata.DifferentEncryptionKey)}).ReadSecrets()
+		tc.Assert.Error(err, "Expected error with the wrong key")
+
+		_, err = storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser).ReadSecrets()
+		tc.Assert.ErrorIs(err, storage.ErrEncryptedContainer)
+	})
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+)
+
+// RetainBackups exposes the retention rules to the external tests
+func RetainBackups(r Retention, backups []domain.Backup) map[string]bool {
+	return r.retain(backups)
+}
+
+// AcquireLock takes the vault lock of a file storage the way its reads and writes do
+func AcquireLock(store service.StorageService, exclusive bool) (func(), error) {
+	return store.(*FileStorage).lock.acquire(exclusive)
+}
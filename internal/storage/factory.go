@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"fmt"
+	"go-password-manager/internal/service"
+	os "os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in storage backend names, as used in storage.type
+const (
+	TypeFile      = "file"
+	TypeMemory    = "memory"
+	TypeDirectory = "directory"
+	TypeJournal   = "journal"
+	TypeGit       = "git"
+)
+
+// DefaultType is used when no storage type is configured
+const DefaultType = TypeFile
+
+// BackendConfig holds everything a backend may need to open the vault
+type BackendConfig struct {
+	SecretsFile     string                // Path of the secrets file, for file based backends
+	Directory       string                // Root of the vault, for directory based backends
+	AppVersion      string                // Recorded in new vaults
+	AppUser         string                // Recorded in new vaults
+	Crypto          service.CryptoService // Seals files and, if it is an IntegrityProvider, signs them
+	EncryptMetadata bool                  // Seal the whole secrets file, not just the values; also keeps names out of commit messages
+	CompactAfter    int                   // Journal entries to keep before writing a new snapshot
+	Options         []Option
+}
+
+// Backend creates a storage of one type. Exists, when set, reports whether a vault was already
+// saved with cfg; it is checked before a new vault key is created.
+type Backend struct {
+	Name   string
+	New    func(cfg BackendConfig) (service.StorageService, error)
+	Exists func(cfg BackendConfig) (bool, error)
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{}
+)
+
+func init() {
+	mustRegisterBackend(Backend{Name: TypeFile, New: newFileBackend, Exists: func(cfg BackendConfig) (bool, error) {
+		return secretsFileExists(cfg, "", backupSuffix)
+	}})
+	mustRegisterBackend(Backend{Name: TypeMemory, New: func(cfg BackendConfig) (service.StorageService, error) {
+		return NewMemoryStorage(cfg.AppVersion, cfg.AppUser), nil
+	}})
+	mustRegisterBackend(Backend{Name: TypeDirectory, New: func(cfg BackendConfig) (service.StorageService, error) {
+		if cfg.Directory == "" {
+			return nil, fmt.Errorf("directory storage needs a directory")
+		}
+		return NewDirectoryStorage(cfg.Directory, cfg.AppVersion, cfg.AppUser, cfg.Crypto, cfg.Options...), nil
+	}, Exists: directoryExists})
+	mustRegisterBackend(Backend{Name: TypeJournal, New: func(cfg BackendConfig) (service.StorageService, error) {
+		if cfg.SecretsFile == "" {
+			return nil, fmt.Errorf("journal storage needs a secrets file")
+		}
+		return NewJournalStorage(cfg.SecretsFile, cfg.AppVersion, cfg.AppUser, cfg.Crypto, cfg.CompactAfter, cfg.Options...), nil
+	}, Exists: func(cfg BackendConfig) (bool, error) {
+		return secretsFileExists(cfg, snapshotSuffix, journalSuffix)
+	}})
+	mustRegisterBackend(Backend{Name: TypeGit, New: func(cfg BackendConfig) (service.StorageService, error) {
+		if cfg.Directory == "" {
+			return nil, fmt.Errorf("git storage needs a directory")
+		}
+		return NewGitStorage(cfg.Directory, cfg.AppVersion, cfg.AppUser, cfg.Crypto, cfg.EncryptMetadata, cfg.Options...)
+	}, Exists: directoryExists})
+}
+
+// RegisterBackend adds a storage backend to the registry
+func RegisterBackend(b Backend) error {
+	if b.Name == "" || b.New == nil {
+		return fmt.Errorf("invalid storage backend definition")
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[b.Name]; ok {
+		return fmt.Errorf("storage backend %s already registered", b.Name)
+	}
+	backends[b.Name] = b
+	return nil
+}
+
+func mustRegisterBackend(b Backend) {
+	if err := RegisterBackend(b); err != nil {
+		panic(err)
+	}
+}
+
+// New creates the storage backend with the given type name
+func New(name string, cfg BackendConfig) (service.StorageService, error) {
+	if name == "" {
+		name = DefaultType
+	}
+	backendsMu.RLock()
+	b, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage type: %s (available: %s)", name, strings.Join(backendNames(), ", "))
+	}
+	return b.New(cfg)
+}
+
+// Exists reports whether a vault of the given storage type was already saved with cfg.
+// Backends that cannot tell report false.
+func Exists(name string, cfg BackendConfig) (bool, error) {
+	if name == "" {
+		name = DefaultType
+	}
+	backendsMu.RLock()
+	b, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return false, fmt.Errorf("unsupported storage type: %s (available: %s)", name, strings.Join(backendNames(), ", "))
+	}
+	if b.Exists == nil {
+		return false, nil
+	}
+	return b.Exists(cfg)
+}
+
+func backendNames() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newFileBackend picks the file storage flavour matching the configuration
+func newFileBackend(cfg BackendConfig) (service.StorageService, error) {
+	if cfg.SecretsFile == "" {
+		return nil, fmt.Errorf("file storage needs a secrets file")
+	}
+	if cfg.Crypto != nil && cfg.EncryptMetadata {
+		return NewEncryptedFileStorage(cfg.SecretsFile, cfg.AppVersion, cfg.AppUser, cfg.Crypto, cfg.Options...), nil
+	}
+	if integrity, ok := cfg.Crypto.(service.IntegrityProvider); ok {
+		return NewFileStorageWithIntegrity(cfg.SecretsFile, cfg.AppVersion, cfg.AppUser, integrity, cfg.Options...), nil
+	}
+	return NewFileStorage(cfg.SecretsFile, cfg.AppVersion, cfg.AppUser, cfg.Options...), nil
+}
+
+// secretsFileExists reports whether the secrets file exists with one of the given suffixes
+func secretsFileExists(cfg BackendConfig, suffixes ...string) (bool, error) {
+	if cfg.SecretsFile == "" {
+		return false, nil
+	}
+	paths := make([]string, len(suffixes))
+	for i, suffix := range suffixes {
+		paths[i] = cfg.SecretsFile + suffix
+	}
+	return anyFileExists(paths...)
+}
+
+func directoryExists(cfg BackendConfig) (bool, error) {
+	if cfg.Directory == "" {
+		return false, nil
+	}
+	return anyFileExists(filepath.Join(cfg.Directory, directoryIndexFile))
+}
+
+// anyFileExists reports whether one of paths exists
+func anyFileExists(paths ...string) (bool, error) {
+	for _, path := range paths {
+		_, err := os.Stat(path)
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
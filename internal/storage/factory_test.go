@@ -0,0 +1,104 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageFactory(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Creates every built-in backend", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		cfg := storage.BackendConfig{
+			SecretsFile: filepath.Join(dir, "secrets.json"),
+			Directory:   filepath.Join(dir, "vault"),
+			AppVersion:  TestFileStorageVersion,
+			AppUser:     TestFileStorageUser,
+		}
+
+		for name, expected := range map[string]any{
+			"":                    &storage.FileStorage{},
+			storage.TypeFile:      &storage.FileStorage{},
+			storage.TypeMemory:    &storage.MemoryStorage{},
+			storage.TypeDirectory: &storage.DirectoryStorage{},
+			storage.TypeJournal:   &storage.JournalStorage{},
+			storage.TypeGit:       &storage.GitStorage{},
+		} {
+			store, err := storage.New(name, cfg)
+			tc.Require.NoError(err, name)
+			tc.Assert.IsType(expected, store, name)
+
+			tc.Require.NoError(store.WriteSecrets(secretsWith("first")), name)
+			readBack, err := store.ReadSecrets()
+			tc.Require.NoError(err, name)
+			tc.Require.Len(readBack.Secrets, 1, name)
+			tc.Assert.Equal("first", readBack.Secrets[0].SecretName, name)
+		}
+	})
+
+	helpers.WithUnitTestCase(t, "Reports saved vaults", func(tc *helpers.UnitTestCase) {
+		for _, name := range []string{storage.TypeFile, storage.TypeDirectory, storage.TypeJournal, storage.TypeMemory} {
+			dir := t.TempDir()
+			cfg := storage.BackendConfig{
+				SecretsFile: filepath.Join(dir, "secrets.json"),
+				Directory:   filepath.Join(dir, "vault"),
+				AppVersion:  TestFileStorageVersion,
+				AppUser:     TestFileStorageUser,
+			}
+			exists, err := storage.Exists(name, cfg)
+			tc.Require.NoError(err, name)
+			tc.Assert.False(exists, name)
+
+			store, err := storage.New(name, cfg)
+			tc.Require.NoError(err, name)
+			tc.Require.NoError(store.WriteSecrets(secretsWith("first")), name)
+			exists, err = storage.Exists(name, cfg)
+			tc.Require.NoError(err, name)
+			tc.Assert.Equal(name != storage.TypeMemory, exists, name)
+		}
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects unknown types", func(tc *helpers.UnitTestCase) {
+		_, err := storage.New("cloud", storage.BackendConfig{})
+		tc.Assert.ErrorContains(err, "unsupported storage type: cloud")
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects duplicate backends", func(tc *helpers.UnitTestCase) {
+		err := storage.RegisterBackend(storage.Backend{
+			Name: storage.TypeMemory,
+			New: func(storage.BackendConfig) (service.StorageService, error) {
+				return storage.NewMemoryStorage(TestFileStorageVersion, TestFileStorageUser), nil
+			},
+		})
+		tc.Assert.ErrorContains(err, "already registered")
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Returns copies of the stored vault", func(tc *helpers.UnitTestCase) {
+		store := storage.NewMemoryStorage(TestFileStorageVersion, TestFileStorageUser)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		readBack.Secrets[0].SecretName = "changed"
+
+		again, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal("first", again.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Stamp changes on every write", func(tc *helpers.UnitTestCase) {
+		store := storage.NewMemoryStorage(TestFileStorageVersion, TestFileStorageUser)
+		detector := store.(service.ChangeDetector)
+
+		before, err := detector.Stamp()
+		tc.Require.NoError(err)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		after, err := detector.Stamp()
+		tc.Require.NoError(err)
+		tc.Assert.NotEqual(before, after)
+	})
+}
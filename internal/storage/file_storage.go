@@ -2,53 +2,212 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/logger"
 	"go-password-manager/internal/service"
 	os "os"
 	"time"
 )
 
+// backupSuffix names the previous good copy of the secrets file, kept next to it
+const backupSuffix = ".bak"
+
 type FileStorage struct {
 	filePath   string
 	appVersion string
 	appUser    string
+	crypto     service.CryptoService     // Seals the whole file when set, see NewEncryptedFileStorage
+	integrity  service.IntegrityProvider // Signs and verifies the file when set
+	lock       *vaultLock
+}
+
+func NewFileStorage(filePath, appVersion, appUser string, opts ...Option) service.StorageService {
+	return newFileStorage(filePath, appVersion, appUser, opts)
 }
 
-func NewFileStorage(filePath, appVersion, appUser string) service.StorageService {
-	return &FileStorage{
+func newFileStorage(filePath, appVersion, appUser string, opts []Option) *FileStorage {
+	fs := &FileStorage{
 		filePath:   filePath,
 		appVersion: appVersion,
 		appUser:    appUser,
+		lock:       newVaultLock(filePath, opts),
 	}
+	return fs
 }
 
 func (fs *FileStorage) ReadSecrets() (domain.SecretsFile, error) {
+	unlock, err := fs.rlock()
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	defer unlock()
+
 	if _, err := os.Stat(fs.filePath); os.IsNotExist(err) {
+		if fs.integrity != nil {
+			if err := verifyMissingFile(fs.integrity); err != nil {
+				return domain.SecretsFile{}, err
+			}
+		}
 		return domain.SecretsFile{
-			AppVersion: fs.appVersion,
-			AppUser:    fs.appUser,
-			Secrets:    []domain.Secret{},
+			SchemaVersion: domain.CurrentSchemaVersion,
+			AppVersion:    fs.appVersion,
+			AppUser:       fs.appUser,
+			Secrets:       []domain.Secret{},
 		}, nil
 	}
 
-	file, err := os.Open(fs.filePath)
-	if err != nil {
+	doc, err := fs.readFile(fs.filePath)
+	if errors.Is(err, service.ErrSchemaTooNew) {
+		// The previous copy would silently discard whatever the newer version wrote
 		return domain.SecretsFile{}, err
 	}
-	defer file.Close()
+	if err != nil {
+		backup, backupErr := fs.readFile(fs.filePath + backupSuffix)
+		if backupErr != nil {
+			return domain.SecretsFile{}, err
+		}
+		logger.Warn("Secrets file is unreadable, using the previous copy:", err.Error())
+		// The previous copy is one write behind the recorded generation
+		if fs.integrity != nil {
+			if err := verifyFile(fs.integrity, backup, 1); err != nil {
+				return domain.SecretsFile{}, err
+			}
+		}
+		return migrate(backup)
+	}
 
-	var data domain.SecretsFile
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return domain.SecretsFile{}, err
+	// The signature covers the file as written, so it is checked before migrating
+	if fs.integrity != nil {
+		if err := verifyFile(fs.integrity, doc, 0); err != nil {
+			return domain.SecretsFile{}, err
+		}
 	}
-	return data, nil
+	return migrate(doc)
 }
 
 func (fs *FileStorage) WriteSecrets(data domain.SecretsFile) error {
+	unlock, err := fs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data.SchemaVersion = domain.CurrentSchemaVersion
 	data.LastUpdated = time.Now().Format(time.RFC3339)
-	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	if fs.integrity != nil {
+		if err := signFile(fs.integrity, &data); err != nil {
+			return err
+		}
+	}
+	jsonBytes, err := fs.encode(data)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(fs.filePath, jsonBytes, 0600)
+
+	if err := fs.backupCurrent(); err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(fs.filePath, jsonBytes, 0600); err != nil {
+		return err
+	}
+
+	// Recorded after the file so a crash in between never looks like a rollback
+	if fs.integrity != nil {
+		return fs.integrity.SetGeneration(data.Generation)
+	}
+	return nil
+}
+
+// Stamp identifies the current state of the secrets file, see service.ChangeDetector
+func (fs *FileStorage) Stamp() (string, error) {
+	return fileStamp(fs.filePath, fs.integrity)
+}
+
+// protection returns what the file is sealed and signed with, see BackupStorage
+func (fs *FileStorage) protection() (service.CryptoService, service.IntegrityProvider) {
+	return fs.crypto, fs.integrity
+}
+
+// fileStamp identifies the state of the file at path, which is rewritten on every write
+func fileStamp(path string, integrity service.IntegrityProvider) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	stamp := fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()) + fileIdentity(info)
+	if integrity != nil {
+		// The generation also changes when two writes land within the same mtime tick
+		generation, err := integrity.Generation()
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf(":%d", generation)
+	}
+	return stamp, nil
+}
+
+// readFile reads and decodes a secrets file without verifying or migrating it
+func (fs *FileStorage) readFile(path string) (map[string]any, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.decode(raw)
+}
+
+// backupCurrent keeps the file about to be replaced as the previous copy, if it is readable.
+// Files with an older schema are also kept separately, see backupSchema.
+func (fs *FileStorage) backupCurrent() error {
+	raw, err := os.ReadFile(fs.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	current, err := fs.decode(raw)
+	if errors.Is(err, service.ErrSchemaTooNew) {
+		return err
+	}
+	if err != nil {
+		// Never replace a good previous copy with a broken one
+		return nil
+	}
+	if schema, _ := schemaOf(current); schema < domain.CurrentSchemaVersion {
+		if err := fs.backupSchema(raw, schema); err != nil {
+			return err
+		}
+	}
+	return fsutil.WriteFileAtomic(fs.filePath+backupSuffix, raw, 0600)
+}
+
+// decode parses a plaintext secrets file or an encrypted container into its generic form,
+// which is verified and migrated before it becomes a domain.SecretsFile
+func (fs *FileStorage) decode(raw []byte) (map[string]any, error) {
+	var doc map[string]any
+	var err error
+	if container, ok := parseContainer(raw); ok {
+		doc, err = fs.openContainer(container)
+	} else {
+		doc, err = parseDocument(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, checkSchema(doc)
+}
+
+// encode serializes data as plaintext JSON, or as an encrypted container when enabled
+func (fs *FileStorage) encode(data domain.SecretsFile) ([]byte, error) {
+	if fs.crypto != nil {
+		return fs.sealContainer(data)
+	}
+	return json.MarshalIndent(data, "", "  ")
 }
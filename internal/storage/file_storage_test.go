@@ -6,6 +6,7 @@ import (
 	"go-password-manager/internal/storage"
 	"go-password-manager/tests/helpers"
 	os "os"
+	"path/filepath"
 	"testing"
 )
 
@@ -20,14 +21,16 @@ func TestSecretStorage(t *testing.T) {
 	helpers.WithUnitTestCase(t, "Writes and Reads secrets when they exist", func(tc *helpers.UnitTestCase) {
 		t.Cleanup(func() {
 			_ = os.RemoveAll(TestFileStoragePath)
+			_ = os.RemoveAll(TestFileStoragePath + ".lock")
 		})
 
 		storage := storage.NewFileStorage(TestFileStoragePath, TestFileStorageVersion, TestFileStorageUser)
 
 		secretsData := domain.SecretsFile{
-			AppVersion: TestFileStorageVersion,
-			AppUser:    TestFileStorageUser,
-			Secrets:    []domain.Secret{},
+			SchemaVersion: domain.CurrentSchemaVersion,
+			AppVersion:    TestFileStorageVersion,
+			AppUser:       TestFileStorageUser,
+			Secrets:       []domain.Secret{},
 		}
 
 		// Simulate saving a secret
@@ -63,6 +66,7 @@ func TestSecretStorage(t *testing.T) {
 	helpers.WithUnitTestCase(t, "Handles Errors with Read Secrets", func(tc *helpers.UnitTestCase) {
 		t.Cleanup(func() {
 			_ = os.RemoveAll(TestFileStoragePath)
+			_ = os.RemoveAll(TestFileStoragePath + ".lock")
 		})
 
 		os.WriteFile(TestFileStoragePath, []byte("invalid json"), 0644)
@@ -82,4 +86,45 @@ func TestSecretStorage(t *testing.T) {
 		tc.Assert.Error(err)
 	})
 
+	helpers.WithUnitTestCase(t, "Falls back to the previous copy when the file is corrupt", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "secrets.json")
+		storage := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+
+		first := domain.SecretsFile{Secrets: []domain.Secret{{SecretName: "first"}}}
+		second := domain.SecretsFile{Secrets: []domain.Secret{{SecretName: "second"}}}
+		tc.Require.NoError(storage.WriteSecrets(first))
+		tc.Require.NoError(storage.WriteSecrets(second))
+
+		entries, err := os.ReadDir(dir)
+		tc.Require.NoError(err)
+		tc.Assert.Len(entries, 3, "Only the file, its previous copy and the lock file should remain")
+
+		// Simulate a write that was cut short
+		tc.Require.NoError(os.WriteFile(path, []byte(`{"secrets": [`), 0600))
+
+		data, err := storage.ReadSecrets()
+		tc.Require.NoError(err, "Expected the previous copy to be used")
+		tc.Assert.Equal("first", data.Secrets[0].SecretName)
+
+		// A broken file must not replace the good previous copy
+		tc.Require.NoError(storage.WriteSecrets(domain.SecretsFile{Secrets: []domain.Secret{{SecretName: "third"}}}))
+		tc.Require.NoError(os.WriteFile(path, []byte("garbage"), 0600))
+		data, err = storage.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal("first", data.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Previous copy passes integrity checks", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		storage := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, &memoryIntegrity{})
+
+		tc.Require.NoError(storage.WriteSecrets(domain.SecretsFile{Secrets: []domain.Secret{{SecretName: "first"}}}))
+		tc.Require.NoError(storage.WriteSecrets(domain.SecretsFile{Secrets: []domain.Secret{{SecretName: "second"}}}))
+		tc.Require.NoError(os.WriteFile(path, []byte("garbage"), 0600))
+
+		data, err := storage.ReadSecrets()
+		tc.Require.NoError(err, "The previous copy is one generation behind and should be accepted")
+		tc.Assert.Equal("first", data.Secrets[0].SecretName)
+	})
 }
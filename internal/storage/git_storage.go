@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	os "os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitIgnore keeps the lock file and interrupted writes out of the repository
+const gitIgnore = "*.lock\n.*.tmp-*\n"
+
+// maxDescribedChanges is how many changes a commit message lists before it only counts them
+const maxDescribedChanges = 3
+
+// GitStorage keeps a directory vault in a git repository and commits every write, so the log of
+// the repository is the history of the vault. It runs the local git binary and never talks to a remote.
+type GitStorage struct {
+	*DirectoryStorage
+	git       string
+	hideNames bool
+}
+
+// NewGitStorage creates a git backed storage in dir, initializing the repository when needed.
+// With hideNames commit messages refer to secrets by their file ID instead of their name.
+func NewGitStorage(dir, appVersion, appUser string, crypto service.CryptoService, hideNames bool, opts ...Option) (service.StorageService, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git storage needs git: %w", err)
+	}
+	gs := &GitStorage{
+		DirectoryStorage: NewDirectoryStorage(dir, appVersion, appUser, crypto, opts...).(*DirectoryStorage),
+		git:              git,
+		hideNames:        hideNames,
+	}
+	if err := gs.init(); err != nil {
+		return nil, fmt.Errorf("failed to initialize git repository: %w", err)
+	}
+	return gs, nil
+}
+
+// WriteSecrets writes data like the directory storage and commits the result
+func (gs *GitStorage) WriteSecrets(data domain.SecretsFile) error {
+	unlock, err := gs.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var previous domain.SecretsFile
+	doc, err := gs.load()
+	if err == nil {
+		previous, err = migrate(doc)
+	}
+	described := err == nil || os.IsNotExist(err)
+	if err := gs.DirectoryStorage.WriteSecrets(data); err != nil {
+		return err
+	}
+
+	message := "update vault"
+	if described {
+		message = gs.describe(previous, data)
+	}
+	return gs.commit(message)
+}
+
+// History returns the commits of the vault, newest first
+func (gs *GitStorage) History() ([]domain.Commit, error) {
+	if _, err := gs.run("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return nil, nil // Nothing committed yet
+	}
+	out, err := gs.run("log", "--format=%H%x1f%an%x1f%aI%x1f%s")
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []domain.Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of commit %s: %w", fields[0], err)
+		}
+		commits = append(commits, domain.Commit{ID: fields[0], Author: fields[1], CreatedAt: createdAt, Message: fields[3]})
+	}
+	return commits, nil
+}
+
+// ReadSecretsAt returns the vault as it was at commit, which may be any revision git understands.
+// The signature is checked, but not the generation: an older vault is the point.
+func (gs *GitStorage) ReadSecretsAt(commit string) (domain.SecretsFile, error) {
+	unlock, err := gs.acquire(false)
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	defer unlock()
+
+	out, err := gs.run("rev-parse", "--verify", "--quiet", commit+"^{commit}")
+	if err != nil {
+		return domain.SecretsFile{}, fmt.Errorf("unknown commit %s", commit)
+	}
+	id := strings.TrimSpace(string(out))
+
+	var currentKeyID string
+	if current, err := gs.readIndex(); err == nil {
+		currentKeyID = keyIDOf(current)
+	}
+
+	past := *gs.DirectoryStorage
+	past.readFile = func(path string) ([]byte, error) {
+		rel, err := filepath.Rel(gs.dir, path)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := gs.run("show", id+":"+filepath.ToSlash(rel))
+		if err != nil {
+			return nil, &os.PathError{Op: "show", Path: rel, Err: os.ErrNotExist}
+		}
+		return raw, nil
+	}
+
+	// A sealed vault does not even open once its key is gone, so look at the header first
+	if raw, err := past.readFile(filepath.Join(gs.dir, directoryIndexFile)); err == nil {
+		if container, sealed := parseContainer(raw); sealed && container.KeyID != currentKeyID {
+			return domain.SecretsFile{}, service.ErrCommitKeyRotated
+		}
+	}
+	doc, err := past.load()
+	if os.IsNotExist(err) {
+		return domain.SecretsFile{}, fmt.Errorf("commit %s holds no vault", commit)
+	}
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	if keyIDOf(doc) != currentKeyID {
+		return domain.SecretsFile{}, service.ErrCommitKeyRotated
+	}
+
+	if gs.integrity != nil {
+		if err := verifyFileMAC(gs.integrity, doc); err != nil {
+			return domain.SecretsFile{}, err
+		}
+	}
+	return migrate(doc)
+}
+
+// init creates the repository, its ignore file and, if git has none, an identity to commit with
+func (gs *GitStorage) init() error {
+	if err := os.MkdirAll(gs.dir, 0700); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(gs.dir, ".git")); os.IsNotExist(err) {
+		if _, err := gs.run("init", "--quiet"); err != nil {
+			return err
+		}
+	}
+
+	ignorePath := filepath.Join(gs.dir, ".gitignore")
+	if _, err := os.Stat(ignorePath); os.IsNotExist(err) {
+		if err := os.WriteFile(ignorePath, []byte(gitIgnore), 0600); err != nil {
+			return err
+		}
+	}
+
+	if _, err := gs.run("config", "user.name"); err != nil {
+		if _, err := gs.run("config", "user.name", gs.appUser); err != nil {
+			return err
+		}
+	}
+	if _, err := gs.run("config", "user.email"); err != nil {
+		if _, err := gs.run("config", "user.email", gs.appUser+"@localhost"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit records everything in the vault directory, unless nothing changed
+func (gs *GitStorage) commit(message string) error {
+	if _, err := gs.run("add", "--all"); err != nil {
+		return err
+	}
+	status, err := gs.run("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(status)) == 0 {
+		return nil
+	}
+	if _, err := gs.run("commit", "--quiet", "--no-gpg-sign", "--message", message); err != nil {
+		return fmt.Errorf("failed to commit vault: %w", err)
+	}
+	return nil
+}
+
+// describe generates the commit message for the change from before to after
+func (gs *GitStorage) describe(before, after domain.SecretsFile) string {
+	if before.KeyID != "" && before.KeyID != after.KeyID {
+		return "rotate vault key"
+	}
+
+	changes := diffSecrets(before.Secrets, after.Secrets)
+	switch {
+	case len(changes) == 0:
+		return "update vault"
+	case len(changes) > maxDescribedChanges:
+		return fmt.Sprintf("update %d secrets", len(changes))
+	}
+
+	trashed := map[string]bool{}
+	for _, secret := range before.Secrets {
+		trashed[secret.SecretName] = secret.Trashed()
+	}
+	// A rename deletes the old name and creates the new one, whose current version names the old one
+	deleted := map[string]bool{}
+	for _, change := range changes {
+		deleted[change.Name] = change.Op == journalDelete
+	}
+	renamedTo := map[string]string{}
+	for _, change := range changes {
+		if change.Op == journalCreate && deleted[renamedFrom(change.Secret)] {
+			renamedTo[renamedFrom(change.Secret)] = change.Name
+		}
+	}
+	display := func(name string) string {
+		if gs.hideNames {
+			return secretNameID(name)
+		}
+		return name
+	}
+
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		name := display(change.Name)
+		switch change.Op {
+		case journalCreate:
+			if !deleted[renamedFrom(change.Secret)] {
+				parts = append(parts, "add secret "+name)
+			}
+		case journalUpdate:
+			switch {
+			case change.Secret.Trashed() && !trashed[change.Name]:
+				parts = append(parts, "trash secret "+name)
+			case !change.Secret.Trashed() && trashed[change.Name]:
+				parts = append(parts, "restore secret "+name)
+			default:
+				part := fmt.Sprintf("update secret %s to v%d", name, change.Secret.CurrentVersion)
+				// Notes can say as much as names, so they are left out with them
+				if current := change.Secret.GetCurrentVersion(); current != nil && current.Note != "" && !gs.hideNames {
+					part += " (" + current.Note + ")"
+				}
+				parts = append(parts, part)
+			}
+		case journalRevert:
+			parts = append(parts, fmt.Sprintf("revert secret %s to v%d", name, change.CurrentVersion))
+		case journalDelete:
+			if newName, ok := renamedTo[change.Name]; ok {
+				parts = append(parts, fmt.Sprintf("rename secret %s to %s", name, display(newName)))
+			} else {
+				parts = append(parts, "delete secret "+name)
+			}
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renamedFrom returns the name secret had before the rename that added its current version, if any
+func renamedFrom(secret *domain.Secret) string {
+	if current := secret.GetCurrentVersion(); current != nil {
+		return current.RenamedFrom
+	}
+	return ""
+}
+
+// run executes git in the vault directory and returns its output
+func (gs *GitStorage) run(args ...string) ([]byte, error) {
+	cmd := exec.Command(gs.git, append([]string{"-C", gs.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
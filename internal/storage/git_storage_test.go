@@ -0,0 +1,154 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	os "os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func newGitStorage(tc *helpers.UnitTestCase, dir string, crypto service.CryptoService, hideNames bool) *storage.GitStorage {
+	store, err := storage.NewGitStorage(dir, TestFileStorageVersion, TestFileStorageUser, crypto, hideNames)
+	tc.Require.NoError(err)
+	return store.(*storage.GitStorage)
+}
+
+func commitMessages(tc *helpers.UnitTestCase, store *storage.GitStorage) []string {
+	commits, err := store.History()
+	tc.Require.NoError(err)
+	messages := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		messages = append(messages, commit.Message)
+	}
+	return messages
+}
+
+func TestGitStorage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	helpers.WithUnitTestCase(t, "Commits every change", func(tc *helpers.UnitTestCase) {
+		store := newGitStorage(tc, t.TempDir(), nil, false)
+
+		history, err := store.History()
+		tc.Require.NoError(err)
+		tc.Assert.Empty(history, "A new repository has no history")
+
+		data := secretsWith("first", "second")
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0].CurrentVersion = 4
+		data.Secrets[0].WrappedKey = "rewrapped"
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0].CurrentVersion = 5
+		data.Secrets[0].Versions = []domain.SecretVersion{{Version: 5, Note: "rotated after the leak"}}
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0].CurrentVersion = 2
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[1].DeletedAt = "2024-01-01T00:00:00Z"
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[1].DeletedAt = ""
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets = data.Secrets[:1]
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0] = domain.Secret{SecretName: "renamed", CurrentVersion: 1, Versions: []domain.SecretVersion{{Version: 1, RenamedFrom: "first"}}}
+		tc.Require.NoError(store.WriteSecrets(data))
+
+		tc.Assert.Equal([]string{
+			"rename secret first to renamed",
+			"delete secret second",
+			"restore secret second",
+			"trash secret second",
+			"revert secret first to v2",
+			"update secret first to v5 (rotated after the leak)",
+			"update secret first to v4",
+			"add secret first, add secret second",
+		}, commitMessages(tc, store))
+
+		history, err = store.History()
+		tc.Require.NoError(err)
+		tc.Assert.NotEmpty(history[0].Author)
+		tc.Assert.False(history[0].CreatedAt.IsZero())
+	})
+
+	helpers.WithUnitTestCase(t, "Keeps the lock file out of the repository", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		store := newGitStorage(tc, dir, nil, false)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		out, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+		tc.Require.NoError(err)
+		tc.Assert.Empty(string(out), "The working tree should be clean after a write")
+	})
+
+	helpers.WithUnitTestCase(t, "Reads the vault as of a commit", func(tc *helpers.UnitTestCase) {
+		store := newGitStorage(tc, t.TempDir(), newSigningCrypto(), false)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("third")))
+
+		history, err := store.History()
+		tc.Require.NoError(err)
+		tc.Require.Len(history, 3)
+
+		past, err := store.ReadSecretsAt(history[1].ID)
+		tc.Require.NoError(err, "An older generation is expected when reading history")
+		tc.Require.Len(past.Secrets, 2)
+		tc.Assert.Equal("second", past.Secrets[1].SecretName)
+
+		past, err = store.ReadSecretsAt("HEAD~2")
+		tc.Require.NoError(err, "Any revision git understands should do")
+		tc.Assert.Len(past.Secrets, 1)
+
+		current, err := store.ReadSecrets()
+		tc.Require.NoError(err, "Reading history must not touch the working tree")
+		tc.Assert.Equal("third", current.Secrets[0].SecretName)
+
+		_, err = store.ReadSecretsAt("no-such-commit")
+		tc.Assert.ErrorContains(err, "unknown commit")
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses commits made with a rotated key", func(tc *helpers.UnitTestCase) {
+		store := newGitStorage(tc, t.TempDir(), nil, false)
+		data := secretsWith("first")
+		data.KeyID = "old"
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.KeyID = "new"
+		tc.Require.NoError(store.WriteSecrets(data))
+
+		tc.Assert.Equal("rotate vault key", commitMessages(tc, store)[0])
+		_, err := store.ReadSecretsAt("HEAD~1")
+		tc.Assert.ErrorIs(err, service.ErrCommitKeyRotated)
+	})
+
+	helpers.WithUnitTestCase(t, "Hides secret names from commit messages", func(tc *helpers.UnitTestCase) {
+		store := newGitStorage(tc, t.TempDir(), newSigningCrypto(), true)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		messages := commitMessages(tc, store)
+		tc.Require.Len(messages, 1)
+		tc.Assert.NotContains(messages[0], "first")
+	})
+
+	helpers.WithUnitTestCase(t, "Summarizes large changes", func(tc *helpers.UnitTestCase) {
+		store := newGitStorage(tc, t.TempDir(), nil, false)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("a", "b", "c", "d")))
+		tc.Assert.Equal([]string{"update 4 secrets"}, commitMessages(tc, store))
+	})
+
+	helpers.WithUnitTestCase(t, "Reuses an existing repository", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		tc.Require.NoError(newGitStorage(tc, dir, nil, false).WriteSecrets(secretsWith("first")))
+
+		reopened := newGitStorage(tc, dir, nil, false)
+		tc.Require.NoError(reopened.WriteSecrets(secretsWith("first", "second")))
+		tc.Assert.Len(commitMessages(tc, reopened), 2)
+
+		_, err := os.Stat(filepath.Join(dir, ".gitignore"))
+		tc.Assert.NoError(err)
+	})
+}
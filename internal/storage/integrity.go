@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"maps"
+	"strconv"
+)
+
+// NewFileStorageWithIntegrity creates a plaintext file storage that signs every write and
+// refuses to read a secrets file that was modified or replaced by an older copy.
+func NewFileStorageWithIntegrity(filePath, appVersion, appUser string, integrity service.IntegrityProvider, opts ...Option) service.StorageService {
+	fs := newFileStorage(filePath, appVersion, appUser, opts)
+	fs.integrity = integrity
+	return fs
+}
+
+// fileMAC computes the MAC over the canonical encoding of a secrets file document: its JSON with
+// sorted keys and without the MAC itself. It covers the file as written, so it is checked before
+// migrating and fields a newer schema renamed are still signed.
+func fileMAC(integrity service.IntegrityProvider, doc map[string]any) ([]byte, error) {
+	unsigned := maps.Clone(doc)
+	delete(unsigned, "mac")
+	canonical, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return integrity.MAC(canonical)
+}
+
+// signFile assigns the next generation to data and computes its MAC
+func signFile(integrity service.IntegrityProvider, data *domain.SecretsFile) error {
+	stored, err := integrity.Generation()
+	if err != nil {
+		return err
+	}
+	data.Generation = max(stored, data.Generation) + 1
+	data.MAC = ""
+
+	doc, err := documentOf(*data)
+	if err != nil {
+		return err
+	}
+	mac, err := fileMAC(integrity, doc)
+	if err != nil {
+		return err
+	}
+	data.MAC = base64.StdEncoding.EncodeToString(mac)
+	return nil
+}
+
+// verifyFile checks the MAC of a secrets file document and that it is at most lag writes older
+// than the last write
+func verifyFile(integrity service.IntegrityProvider, doc map[string]any, lag uint64) error {
+	stored, err := integrity.Generation()
+	if err != nil {
+		return err
+	}
+
+	if doc["mac"] == nil {
+		// Files written before integrity checks existed are accepted until the first signed write
+		if stored > 0 {
+			return fmt.Errorf("%w: signature is missing", service.ErrVaultTampered)
+		}
+		return nil
+	}
+
+	if err := verifyFileMAC(integrity, doc); err != nil {
+		return err
+	}
+	generation, err := generationOf(doc)
+	if err != nil {
+		return err
+	}
+	if generation+lag < stored {
+		return fmt.Errorf("%w: file generation %d, expected %d", service.ErrVaultRolledBack, generation, stored)
+	}
+	return nil
+}
+
+// verifyFileMAC checks that a secrets file document was signed with the current vault key
+func verifyFileMAC(integrity service.IntegrityProvider, doc map[string]any) error {
+	mac, _ := doc["mac"].(string)
+	actual, err := base64.StdEncoding.DecodeString(mac)
+	if err != nil {
+		return service.ErrVaultTampered
+	}
+	expected, err := fileMAC(integrity, doc)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, actual) {
+		return service.ErrVaultTampered
+	}
+	return nil
+}
+
+// generationOf returns the generation a secrets file document was written with
+func generationOf(doc map[string]any) (uint64, error) {
+	switch generation := doc["generation"].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		value, err := strconv.ParseUint(generation.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid generation %s", service.ErrVaultTampered, generation)
+		}
+		return value, nil
+	default:
+		return 0, fmt.Errorf("%w: invalid generation %v", service.ErrVaultTampered, generation)
+	}
+}
+
+// verifyMissingFile rejects a missing vault once it has been written
+func verifyMissingFile(integrity service.IntegrityProvider) error {
+	stored, err := integrity.Generation()
+	if err != nil {
+		return err
+	}
+	if stored > 0 {
+		return fmt.Errorf("%w: file is missing", service.ErrVaultTampered)
+	}
+	return nil
+}
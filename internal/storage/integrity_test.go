@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryIntegrity keeps the generation in memory and signs with a fixed key
+type memoryIntegrity struct {
+	generation uint64
+}
+
+func (m *memoryIntegrity) MAC(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, []byte(testdata.TestEncryptionKey))
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (m *memoryIntegrity) Generation() (uint64, error) { return m.generation, nil }
+
+func (m *memoryIntegrity) SetGeneration(generation uint64) error {
+	m.generation = generation
+	return nil
+}
+
+func TestFileIntegrity(t *testing.T) {
+	secretsData := domain.SecretsFile{
+		AppVersion: TestFileStorageVersion,
+		AppUser:    TestFileStorageUser,
+		Secrets:    []domain.Secret{{SecretName: testdata.TestSecrets.Simple.Name, CurrentVersion: 1}},
+	}
+
+	helpers.WithUnitTestCase(t, "Signs writes and verifies reads", func(tc *helpers.UnitTestCase) {
+		integrity := &memoryIntegrity{}
+		store := storage.NewFileStorageWithIntegrity(filepath.Join(t.TempDir(), "secrets.json"), TestFileStorageVersion, TestFileStorageUser, integrity)
+
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+		tc.Assert.Equal(uint64(2), integrity.generation, "Every write should advance the generation")
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(uint64(2), readBack.Generation)
+		tc.Assert.NotEmpty(readBack.MAC)
+	})
+
+	helpers.WithUnitTestCase(t, "Detects edits", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, &memoryIntegrity{})
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		edited := strings.Replace(string(raw), testdata.TestSecrets.Simple.Name, "renamed", 1)
+		tc.Require.NoError(os.WriteFile(path, []byte(edited), 0600))
+
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered)
+	})
+
+	helpers.WithUnitTestCase(t, "Detects rollbacks", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, &memoryIntegrity{})
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+		oldCopy, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+
+		tc.Require.NoError(store.WriteSecrets(secretsData))
+		tc.Require.NoError(os.WriteFile(path, oldCopy, 0600))
+
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultRolledBack)
+	})
+
+	helpers.WithUnitTestCase(t, "Accepts unsigned files only before the first signed write", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		tc.Require.NoError(storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser).WriteSecrets(secretsData))
+
+		integrity := &memoryIntegrity{}
+		store := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, integrity)
+		_, err := store.ReadSecrets()
+		tc.Require.NoError(err, "Unsigned files from older versions should be readable")
+
+		integrity.generation = 3
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered, "A stripped signature should be detected")
+
+		tc.Require.NoError(os.Remove(path))
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered, "A deleted file should be detected")
+	})
+}
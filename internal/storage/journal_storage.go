@@ -0,0 +1,547 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+	"io"
+	"maps"
+	os "os"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// DefaultCompactAfter is how many journal entries are kept before they are folded into the snapshot
+const DefaultCompactAfter = 100
+
+const (
+	snapshotSuffix = ".snapshot"
+	journalSuffix  = ".journal"
+)
+
+// Kinds of change recorded in the journal
+const (
+	journalCreate = "create"
+	journalUpdate = "update"
+	journalDelete = "delete"
+	journalRevert = "revert"
+)
+
+// journalChange is one change to one secret. Creates and updates carry the whole secret,
+// reverts only the version that became current.
+type journalChange struct {
+	Op             string         `json:"op"`
+	Name           string         `json:"name"`
+	Secret         *domain.Secret `json:"secret,omitempty"`
+	CurrentVersion int            `json:"currentVersion,omitempty"`
+}
+
+// journalEntry holds the changes of one write and the file metadata after it, including the signature.
+// An entry is applied completely or not at all.
+type journalEntry struct {
+	Seq     uint64             `json:"seq"`
+	Changes []journalChange    `json:"changes"`
+	File    domain.SecretsFile `json:"file"` // Metadata after the write, without the secrets
+}
+
+// journalLine is one line of the journal. The sequence number is readable without the key so
+// entries already folded into the snapshot can be skipped.
+type journalLine struct {
+	Seq   uint64          `json:"seq"`
+	Entry json.RawMessage `json:"entry"` // journalEntry, sealed when a crypto service is set
+}
+
+// journalSnapshot is the state of the vault up to and including entry Seq
+type journalSnapshot struct {
+	Seq  uint64             `json:"seq"`
+	File domain.SecretsFile `json:"file"`
+}
+
+// The journal is replayed on the generic form of the vault, so the state can be verified and
+// migrated as written, see migrate. These are the types above as read.
+type (
+	journalChangeDocument struct {
+		Op             string         `json:"op"`
+		Name           string         `json:"name"`
+		Secret         map[string]any `json:"secret,omitempty"`
+		CurrentVersion json.Number    `json:"currentVersion,omitempty"`
+	}
+	journalEntryDocument struct {
+		Seq     uint64                  `json:"seq"`
+		Changes []journalChangeDocument `json:"changes"`
+		File    map[string]any          `json:"file"`
+	}
+	journalSnapshotDocument struct {
+		Seq  uint64         `json:"seq"`
+		File map[string]any `json:"file"`
+	}
+)
+
+// JournalStorage appends every write to a journal as a list of changes instead of rewriting the
+// vault, and rebuilds the vault on load by replaying the journal over the last snapshot:
+//
+//	secrets.json.snapshot   the vault as of a journal entry
+//	secrets.json.journal    one line per write since then
+//
+// After compactAfter entries the journal is folded into a new snapshot.
+type JournalStorage struct {
+	path         string
+	appVersion   string
+	appUser      string
+	crypto       service.CryptoService
+	integrity    service.IntegrityProvider
+	lock         *vaultLock
+	compactAfter int
+}
+
+// journalState is the replayed vault and where the journal ends
+type journalState struct {
+	doc         map[string]any     // The vault as written, which the signature covers
+	file        domain.SecretsFile // The vault migrated to the current schema
+	snapshotSeq uint64
+	seq         uint64 // Sequence number of the last entry
+	entries     int    // Entries after the snapshot
+	validSize   int64  // Journal length up to the last complete entry
+	exists      bool
+}
+
+// NewJournalStorage creates a journaled storage next to path. crypto may be nil to keep the
+// entries readable; secret values are encrypted either way. compactAfter <= 0 uses DefaultCompactAfter.
+func NewJournalStorage(path, appVersion, appUser string, crypto service.CryptoService, compactAfter int, opts ...Option) service.StorageService {
+	if compactAfter <= 0 {
+		compactAfter = DefaultCompactAfter
+	}
+	js := &JournalStorage{
+		path:         path,
+		appVersion:   appVersion,
+		appUser:      appUser,
+		crypto:       crypto,
+		lock:         newVaultLock(path, opts),
+		compactAfter: compactAfter,
+	}
+	if integrity, ok := crypto.(service.IntegrityProvider); ok {
+		js.integrity = integrity
+	}
+	return js
+}
+
+func (js *JournalStorage) ReadSecrets() (domain.SecretsFile, error) {
+	unlock, err := js.lock.acquire(false)
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	defer unlock()
+
+	state, err := js.load()
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+	if js.integrity != nil {
+		if !state.exists {
+			if err := verifyMissingFile(js.integrity); err != nil {
+				return domain.SecretsFile{}, err
+			}
+		} else if err := verifyFile(js.integrity, state.doc, 0); err != nil {
+			return domain.SecretsFile{}, err
+		}
+	}
+	return state.file, nil
+}
+
+// WriteSecrets appends the difference between the stored vault and data as one journal entry
+func (js *JournalStorage) WriteSecrets(data domain.SecretsFile) error {
+	unlock, err := js.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := js.load()
+	if err != nil {
+		return err
+	}
+	return js.write(state, data)
+}
+
+// write appends data as the entry after state, or compacts the journal into a snapshot of it
+func (js *JournalStorage) write(state journalState, data domain.SecretsFile) error {
+	data = data.Clone()
+	if data.Secrets == nil {
+		// Replaying never produces a nil slice, so the signature must not cover one
+		data.Secrets = []domain.Secret{}
+	}
+	data.SchemaVersion = domain.CurrentSchemaVersion
+	data.LastUpdated = time.Now().Format(time.RFC3339)
+	if js.integrity != nil {
+		if err := signFile(js.integrity, &data); err != nil {
+			return err
+		}
+	}
+
+	entry := journalEntry{Seq: state.seq + 1, Changes: diffSecrets(state.file.Secrets, data.Secrets), File: data}
+	entry.File.Secrets = nil
+
+	// Entries have to open with the key of the snapshot, and the journal cannot express reordering.
+	// A vault written with an older schema is compacted so the journal is never replayed mixed.
+	replayed := applyChanges(state.file.Secrets, entry.Changes)
+	rekeyed := state.exists && data.KeyID != state.file.KeyID
+	schema, err := schemaOf(state.doc)
+	if err != nil {
+		return err
+	}
+	upgraded := state.exists && schema < domain.CurrentSchemaVersion
+	if rekeyed || upgraded || !reflect.DeepEqual(replayed, data.Secrets) || state.entries+1 >= js.compactAfter {
+		if err := js.compact(data, entry.Seq); err != nil {
+			return err
+		}
+	} else if err := js.append(entry, state.validSize); err != nil {
+		return err
+	}
+
+	if js.integrity != nil {
+		return js.integrity.SetGeneration(data.Generation)
+	}
+	return nil
+}
+
+// Compact folds the journal into a new snapshot
+func (js *JournalStorage) Compact() error {
+	unlock, err := js.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := js.load()
+	if err != nil || !state.exists {
+		return err
+	}
+	if js.integrity != nil {
+		if err := verifyFile(js.integrity, state.doc, 0); err != nil {
+			return err
+		}
+	}
+	if schema, err := schemaOf(state.doc); err != nil || schema < domain.CurrentSchemaVersion {
+		// The signature covers the vault as written, so the migrated vault is written and signed anew
+		if err != nil {
+			return err
+		}
+		return js.write(state, state.file)
+	}
+	return js.compact(state.file, state.seq)
+}
+
+// Stamp identifies the current state of the vault, see service.ChangeDetector
+func (js *JournalStorage) Stamp() (string, error) {
+	snapshot, err := fileStamp(js.path+snapshotSuffix, nil)
+	if err != nil {
+		return "", err
+	}
+	journal, err := fileStamp(js.path+journalSuffix, js.integrity)
+	if err != nil {
+		return "", err
+	}
+	return snapshot + "/" + journal, nil
+}
+
+// Lock takes the exclusive lock, see service.Locker
+func (js *JournalStorage) Lock() (func(), error) {
+	return js.lock.acquire(true)
+}
+
+// protection returns what the journal is sealed and signed with, see BackupStorage
+func (js *JournalStorage) protection() (service.CryptoService, service.IntegrityProvider) {
+	return js.crypto, js.integrity
+}
+
+// load reads the snapshot and replays the journal entries written after it
+func (js *JournalStorage) load() (journalState, error) {
+	state, err := js.replayAll()
+	if err != nil {
+		return journalState{}, err
+	}
+	if state.file, err = migrate(state.doc); err != nil {
+		return journalState{}, err
+	}
+	if state.file.Secrets == nil {
+		state.file.Secrets = []domain.Secret{}
+	}
+	return state, nil
+}
+
+// replayAll rebuilds the vault as written from the snapshot and the journal
+func (js *JournalStorage) replayAll() (journalState, error) {
+	empty, err := documentOf(domain.SecretsFile{
+		SchemaVersion: domain.CurrentSchemaVersion,
+		AppVersion:    js.appVersion,
+		AppUser:       js.appUser,
+		Secrets:       []domain.Secret{},
+	})
+	if err != nil {
+		return journalState{}, err
+	}
+	state := journalState{doc: empty}
+
+	raw, err := os.ReadFile(js.path + snapshotSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return journalState{}, err
+	}
+	if err == nil {
+		var snapshot journalSnapshotDocument
+		if err := decodeDocument(js.crypto, raw, &snapshot); err != nil {
+			return journalState{}, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if snapshot.File == nil {
+			return journalState{}, fmt.Errorf("snapshot holds no vault")
+		}
+		if err := checkSchema(snapshot.File); err != nil {
+			return journalState{}, err
+		}
+		state.doc = snapshot.File
+		if _, ok := state.doc["secrets"].([]any); !ok {
+			state.doc["secrets"] = []any{}
+		}
+		state.snapshotSeq, state.seq, state.exists = snapshot.Seq, snapshot.Seq, true
+	}
+
+	journal, err := os.Open(js.path + journalSuffix)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return journalState{}, err
+	}
+	defer journal.Close()
+
+	reader := bufio.NewReader(journal)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// The last write was interrupted and never completed, it is dropped by the next append
+				logger.Warn("Ignoring an incomplete journal entry")
+			}
+			return state, nil
+		}
+		if err != nil {
+			return journalState{}, err
+		}
+
+		if err := js.replay(&state, line); err != nil {
+			return journalState{}, fmt.Errorf("journal entry after %d: %w", state.seq, err)
+		}
+		state.validSize += int64(len(line))
+	}
+}
+
+// replay applies one journal line to state, skipping entries already in the snapshot
+func (js *JournalStorage) replay(state *journalState, line []byte) error {
+	var jl journalLine
+	if err := json.Unmarshal(line, &jl); err != nil {
+		return err
+	}
+	if jl.Seq <= state.snapshotSeq {
+		return nil
+	}
+	if jl.Seq != state.seq+1 {
+		return fmt.Errorf("%w: expected entry %d, found %d", service.ErrVaultTampered, state.seq+1, jl.Seq)
+	}
+
+	var entry journalEntryDocument
+	if err := decodeDocument(js.crypto, jl.Entry, &entry); err != nil {
+		return err
+	}
+	if entry.Seq != jl.Seq {
+		return fmt.Errorf("%w: entry %d is labelled %d", service.ErrVaultTampered, entry.Seq, jl.Seq)
+	}
+	if entry.File == nil {
+		return fmt.Errorf("%w: entry %d holds no vault", service.ErrVaultTampered, entry.Seq)
+	}
+	if err := checkSchema(entry.File); err != nil {
+		return err
+	}
+
+	// The changes were made to the vault as the entry's writer read it, migrated to its schema
+	schema, err := schemaOf(entry.File)
+	if err != nil {
+		return err
+	}
+	if err := upgradeDocument(state.doc, schema); err != nil {
+		return err
+	}
+	secrets, _ := state.doc["secrets"].([]any)
+	state.doc = entry.File
+	state.doc["secrets"] = applyDocumentChanges(secrets, entry.Changes)
+	state.seq = entry.Seq
+	state.entries++
+	state.exists = true
+	return nil
+}
+
+// append writes entry at the end of the journal, dropping an incomplete entry left by a crash
+func (js *JournalStorage) append(entry journalEntry, validSize int64) error {
+	sealed, err := encodeDocument(js.crypto, entry, entry.File.KeyID)
+	if err != nil {
+		return err
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, sealed); err != nil {
+		return err
+	}
+	line, err := json.Marshal(journalLine{Seq: entry.Seq, Entry: compacted.Bytes()})
+	if err != nil {
+		return err
+	}
+
+	journal, err := os.OpenFile(js.path+journalSuffix, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	if err := journal.Truncate(validSize); err != nil {
+		return err
+	}
+	if _, err := journal.WriteAt(append(line, '\n'), validSize); err != nil {
+		return err
+	}
+	return journal.Sync()
+}
+
+// compact replaces the snapshot with data as of entry seq and empties the journal.
+// A crash in between is harmless: entries up to seq are skipped when replaying.
+func (js *JournalStorage) compact(data domain.SecretsFile, seq uint64) error {
+	raw, err := encodeDocument(js.crypto, journalSnapshot{Seq: seq, File: data}, data.KeyID)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(js.path+snapshotSuffix, raw, 0600); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(js.path+journalSuffix, nil, 0600)
+}
+
+// diffSecrets returns the changes that turn before into after
+func diffSecrets(before, after []domain.Secret) []journalChange {
+	previous := make(map[string]domain.Secret, len(before))
+	for _, secret := range before {
+		previous[secret.SecretName] = secret
+	}
+
+	var changes []journalChange
+	remaining := make(map[string]bool, len(after))
+	for _, secret := range after {
+		remaining[secret.SecretName] = true
+		old, existed := previous[secret.SecretName]
+		switch {
+		case !existed:
+			changes = append(changes, journalChange{Op: journalCreate, Name: secret.SecretName, Secret: cloneSecret(secret)})
+		case reflect.DeepEqual(old, secret):
+		case onlyCurrentVersionChanged(old, secret):
+			changes = append(changes, journalChange{Op: journalRevert, Name: secret.SecretName, CurrentVersion: secret.CurrentVersion})
+		default:
+			changes = append(changes, journalChange{Op: journalUpdate, Name: secret.SecretName, Secret: cloneSecret(secret)})
+		}
+	}
+	for _, secret := range before {
+		if !remaining[secret.SecretName] {
+			changes = append(changes, journalChange{Op: journalDelete, Name: secret.SecretName})
+		}
+	}
+	return changes
+}
+
+// applyChanges returns secrets with changes applied, leaving secrets untouched
+func applyChanges(secrets []domain.Secret, changes []journalChange) []domain.Secret {
+	result := make([]domain.Secret, len(secrets))
+	copy(result, secrets)
+
+	for _, change := range changes {
+		index := -1
+		for i := range result {
+			if result[i].SecretName == change.Name {
+				index = i
+				break
+			}
+		}
+
+		switch change.Op {
+		case journalCreate, journalUpdate:
+			if change.Secret == nil {
+				continue
+			}
+			if index < 0 {
+				result = append(result, change.Secret.Clone())
+			} else {
+				result[index] = change.Secret.Clone()
+			}
+		case journalRevert:
+			if index >= 0 {
+				result[index].CurrentVersion = change.CurrentVersion
+			}
+		case journalDelete:
+			if index >= 0 {
+				result = append(result[:index], result[index+1:]...)
+			}
+		}
+	}
+	return result
+}
+
+// applyDocumentChanges is applyChanges on the generic form of the secrets
+func applyDocumentChanges(secrets []any, changes []journalChangeDocument) []any {
+	result := slices.Clone(secrets)
+	if result == nil {
+		result = []any{}
+	}
+
+	for _, change := range changes {
+		index := slices.IndexFunc(result, func(secret any) bool {
+			fields, _ := secret.(map[string]any)
+			return fields["secretName"] == change.Name
+		})
+
+		switch change.Op {
+		case journalCreate, journalUpdate:
+			if change.Secret == nil {
+				continue
+			}
+			if index < 0 {
+				result = append(result, change.Secret)
+			} else {
+				result[index] = change.Secret
+			}
+		case journalRevert:
+			if index < 0 {
+				continue
+			}
+			if secret, ok := result[index].(map[string]any); ok {
+				secret = maps.Clone(secret)
+				secret["currentVersion"] = change.CurrentVersion
+				result[index] = secret
+			}
+		case journalDelete:
+			if index >= 0 {
+				result = slices.Delete(result, index, index+1)
+			}
+		}
+	}
+	return result
+}
+
+func onlyCurrentVersionChanged(before, after domain.Secret) bool {
+	before.CurrentVersion = after.CurrentVersion
+	return reflect.DeepEqual(before, after)
+}
+
+func cloneSecret(secret domain.Secret) *domain.Secret {
+	clone := secret.Clone()
+	return &clone
+}
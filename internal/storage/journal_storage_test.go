@@ -0,0 +1,180 @@
+package storage_test
+
+import (
+	"encoding/json"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// journalOps returns the kinds of change of every line in a plaintext journal
+func journalOps(tc *helpers.UnitTestCase, path string) [][]string {
+	raw, err := os.ReadFile(path + ".journal")
+	tc.Require.NoError(err)
+
+	var ops [][]string
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		if line == "" {
+			continue
+		}
+		var parsed struct {
+			Entry struct {
+				Changes []struct {
+					Op string `json:"op"`
+				} `json:"changes"`
+			} `json:"entry"`
+		}
+		tc.Require.NoError(json.Unmarshal([]byte(line), &parsed))
+		var lineOps []string
+		for _, change := range parsed.Entry.Changes {
+			lineOps = append(lineOps, change.Op)
+		}
+		ops = append(ops, lineOps)
+	}
+	return ops
+}
+
+func TestJournalStorage(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Appends one entry per write and replays them", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 0)
+
+		data := secretsWith("first", "second")
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0].CurrentVersion = 2
+		data.Secrets[0].WrappedKey = "rewrapped"
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets[0].CurrentVersion = 1
+		tc.Require.NoError(store.WriteSecrets(data))
+		data.Secrets = data.Secrets[:1]
+		tc.Require.NoError(store.WriteSecrets(data))
+
+		tc.Assert.Equal([][]string{{"create", "create"}, {"update"}, {"revert"}, {"delete"}}, journalOps(tc, path))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.Len(readBack.Secrets, 1)
+		tc.Assert.Equal("first", readBack.Secrets[0].SecretName)
+		tc.Assert.Equal(1, readBack.Secrets[0].CurrentVersion)
+		tc.Assert.Equal("rewrapped", readBack.Secrets[0].WrappedKey)
+	})
+
+	helpers.WithUnitTestCase(t, "Compacts into a snapshot", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 3)
+
+		data := secretsWith("first")
+		for i := 1; i <= 4; i++ {
+			data.Secrets[0].CurrentVersion = i
+			tc.Require.NoError(store.WriteSecrets(data))
+		}
+
+		_, err := os.Stat(path + ".snapshot")
+		tc.Require.NoError(err, "The third entry should trigger a snapshot")
+		tc.Assert.Len(journalOps(tc, path), 1, "Only the write after the snapshot should be in the journal")
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(4, readBack.Secrets[0].CurrentVersion)
+	})
+
+	helpers.WithUnitTestCase(t, "Migrates a snapshot of an older schema", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		snapshot := `{"seq": 1, "file": ` + schema7File + `}`
+		tc.Require.NoError(os.WriteFile(path+".snapshot", []byte(snapshot), 0600))
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 0)
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal("rotated after the leak", data.Secrets[0].Versions[1].Note)
+
+		tc.Require.NoError(store.WriteSecrets(data))
+		raw, err := os.ReadFile(path + ".snapshot")
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), "changeNote", "The first write should compact the older schema away")
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal("rotated after the leak", readBack.Secrets[0].Versions[1].Note)
+	})
+
+	helpers.WithUnitTestCase(t, "Skips entries already in the snapshot", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 0)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		journal, err := os.ReadFile(path + ".journal")
+		tc.Require.NoError(err)
+
+		// A crash after the snapshot was written but before the journal was emptied
+		tc.Require.NoError(store.(*storage.JournalStorage).Compact())
+		tc.Require.NoError(os.WriteFile(path+".journal", journal, 0600))
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Len(readBack.Secrets, 2)
+	})
+
+	helpers.WithUnitTestCase(t, "Drops an interrupted write", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 0)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+
+		journal, err := os.OpenFile(path+".journal", os.O_APPEND|os.O_WRONLY, 0600)
+		tc.Require.NoError(err)
+		_, err = journal.WriteString(`{"seq":2,"entry":{"seq":2,"chan`)
+		tc.Require.NoError(err)
+		tc.Require.NoError(journal.Close())
+
+		readBack, err := store.ReadSecrets()
+		tc.Require.NoError(err, "An incomplete last line is an unfinished write")
+		tc.Assert.Len(readBack.Secrets, 1)
+
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+		readBack, err = store.ReadSecrets()
+		tc.Require.NoError(err, "The next write should replace the incomplete line")
+		tc.Assert.Len(readBack.Secrets, 2)
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses a corrupted journal", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, nil, 0)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+
+		lines := strings.SplitAfter(readFile(tc, path+".journal"), "\n")
+		tc.Require.NoError(os.WriteFile(path+".journal", []byte("garbage\n"+lines[1]), 0600))
+
+		_, err := store.ReadSecrets()
+		tc.Assert.Error(err)
+		tc.Assert.Error(store.WriteSecrets(secretsWith("third")), "Writes must not build on a damaged journal")
+	})
+
+	helpers.WithUnitTestCase(t, "Seals entries and detects dropped ones", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewJournalStorage(path, TestFileStorageVersion, TestFileStorageUser, newSigningCrypto(), 0)
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first")))
+		tc.Require.NoError(store.WriteSecrets(secretsWith("first", "second")))
+
+		journal := readFile(tc, path+".journal")
+		tc.Assert.NotContains(journal, "second", "Entries should be encrypted")
+
+		_, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+
+		lines := strings.SplitAfter(journal, "\n")
+		tc.Require.NoError(os.WriteFile(path+".journal", []byte(lines[0]), 0600))
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultRolledBack)
+	})
+}
+
+func readFile(tc *helpers.UnitTestCase, path string) string {
+	raw, err := os.ReadFile(path)
+	tc.Require.NoError(err)
+	return string(raw)
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"fmt"
+	"go-password-manager/internal/service"
+	os "os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLockTimeout is how long to wait for another process to release the vault
+const DefaultLockTimeout = 5 * time.Second
+
+const (
+	lockSuffix       = ".lock"
+	lockPollInterval = 50 * time.Millisecond
+)
+
+// Option configures how a storage locks the vault
+type Option func(*vaultLock)
+
+// WithLockTimeout sets how long reads and writes wait for another process to release the vault
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(l *vaultLock) {
+		l.timeout = timeout
+	}
+}
+
+// vaultLock is an advisory lock on a file next to the secrets file, shared by readers and
+// exclusive for writers. Locks taken while this process holds the exclusive lock nest inside it.
+type vaultLock struct {
+	path    string
+	timeout time.Duration
+
+	mu        sync.Mutex
+	file      *os.File
+	shared    int
+	exclusive int
+}
+
+func newVaultLock(secretsPath string, opts []Option) *vaultLock {
+	l := &vaultLock{path: secretsPath + lockSuffix, timeout: DefaultLockTimeout}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Lock takes the exclusive lock, see service.Locker
+func (fs *FileStorage) Lock() (func(), error) {
+	return fs.lock.acquire(true)
+}
+
+// rlock takes a shared lock for reading
+func (fs *FileStorage) rlock() (func(), error) {
+	return fs.lock.acquire(false)
+}
+
+func (l *vaultLock) acquire(exclusive bool) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if exclusive && l.exclusive == 0 {
+		// A shared lock cannot be converted without losing it when the conversion fails,
+		// so the readers in this process finish and the exclusive lock is taken afresh
+		if err := l.drainReaders(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case l.exclusive > 0, !exclusive && l.shared > 0:
+		// Already held strongly enough by this process
+	case exclusive:
+		if err := l.wait(true); err != nil {
+			return nil, err
+		}
+		l.writeOwner()
+	default:
+		if err := l.wait(false); err != nil {
+			return nil, err
+		}
+	}
+
+	if exclusive {
+		l.exclusive++
+	} else {
+		l.shared++
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { l.release(exclusive) }) }, nil
+}
+
+// wait polls for the OS lock until the timeout expires
+func (l *vaultLock) wait(exclusive bool) error {
+	if l.file == nil {
+		file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		l.file = file
+	}
+
+	deadline := time.Now().Add(l.timeout)
+	for {
+		acquired, err := tryLockFile(l.file, exclusive)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return l.lockedError()
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// drainReaders waits until no reader in this process holds the lock, releasing l.mu meanwhile
+func (l *vaultLock) drainReaders() error {
+	deadline := time.Now().Add(l.timeout)
+	for l.shared > 0 && l.exclusive == 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w by a reader in this process", service.ErrVaultLocked)
+		}
+		l.mu.Unlock()
+		time.Sleep(lockPollInterval)
+		l.mu.Lock()
+	}
+	return nil
+}
+
+func (l *vaultLock) release(exclusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if exclusive {
+		l.exclusive--
+		if l.exclusive == 0 {
+			l.clearOwner()
+			if l.shared > 0 {
+				// Readers in this process still need their lock
+				_ = downgradeFile(l.file)
+			}
+		}
+	} else {
+		l.shared--
+	}
+
+	if l.exclusive == 0 && l.shared == 0 && l.file != nil {
+		_ = unlockFile(l.file)
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+// writeOwner records this process as the exclusive holder so waiting processes can report it
+func (l *vaultLock) writeOwner() {
+	if err := l.file.Truncate(0); err == nil {
+		_, _ = l.file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+}
+
+func (l *vaultLock) clearOwner() {
+	_ = l.file.Truncate(0)
+}
+
+// lockedError names the process holding the lock when it is known
+func (l *vaultLock) lockedError() error {
+	raw, _ := os.ReadFile(l.path)
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(raw))); err == nil {
+		return fmt.Errorf("%w by PID %d", service.ErrVaultLocked, pid)
+	}
+	return fmt.Errorf("%w by another process", service.ErrVaultLocked)
+}
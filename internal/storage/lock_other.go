@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package storage
+
+import os "os"
+
+// tryLockFile always succeeds on platforms without file locking
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	return true, nil
+}
+
+func downgradeFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package storage_test
+
+import (
+	"fmt"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVaultLock(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Reports the process holding the lock", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		holder := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+		// A second storage has its own lock handle and behaves like another process
+		waiter := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+
+		unlock, err := holder.(service.Locker).Lock()
+		tc.Require.NoError(err)
+
+		_, err = waiter.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultLocked)
+		tc.Assert.Contains(err.Error(), fmt.Sprintf("vault is locked by PID %d", os.Getpid()))
+
+		unlock()
+		_, err = waiter.ReadSecrets()
+		tc.Assert.NoError(err, "Expected the lock to be available once released")
+	})
+
+	helpers.WithUnitTestCase(t, "Reads and writes nest inside the exclusive lock", func(tc *helpers.UnitTestCase) {
+		store := storage.NewFileStorage(filepath.Join(t.TempDir(), "secrets.json"), TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+
+		unlock, err := store.(service.Locker).Lock()
+		tc.Require.NoError(err)
+		defer unlock()
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.NoError(store.WriteSecrets(data))
+	})
+
+	helpers.WithUnitTestCase(t, "Keeps the shared lock when a writer cannot take the vault", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+		other := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+		third := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+
+		readUnlock, err := storage.AcquireLock(store, false)
+		tc.Require.NoError(err)
+		otherUnlock, err := storage.AcquireLock(other, false)
+		tc.Require.NoError(err)
+
+		_, err = storage.AcquireLock(store, true)
+		tc.Assert.ErrorIs(err, service.ErrVaultLocked, "A reader in this process holds the vault")
+		otherUnlock()
+		_, err = storage.AcquireLock(third, true)
+		tc.Assert.ErrorIs(err, service.ErrVaultLocked, "The reader should still hold its shared lock")
+
+		readUnlock()
+		writeUnlock, err := storage.AcquireLock(store, true)
+		tc.Require.NoError(err, "The exclusive lock is available once the readers are done")
+		_, err = storage.AcquireLock(third, false)
+		tc.Assert.ErrorIs(err, service.ErrVaultLocked)
+		writeUnlock()
+	})
+
+	helpers.WithUnitTestCase(t, "Readers keep their lock when the writer is done", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+		other := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser, storage.WithLockTimeout(100*time.Millisecond))
+
+		writeUnlock, err := storage.AcquireLock(store, true)
+		tc.Require.NoError(err)
+		readUnlock, err := storage.AcquireLock(store, false)
+		tc.Require.NoError(err)
+		writeUnlock()
+
+		_, err = storage.AcquireLock(other, true)
+		tc.Assert.ErrorIs(err, service.ErrVaultLocked, "The nested reader should still hold a shared lock")
+		otherUnlock, err := storage.AcquireLock(other, false)
+		tc.Require.NoError(err, "The lock should be shared again")
+		otherUnlock()
+		readUnlock()
+	})
+
+	helpers.WithUnitTestCase(t, "Concurrent writers do not lose updates", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		key := []byte(testdata.TestEncryptionKey)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for writer := 0; writer < 2; writer++ {
+			wg.Add(1)
+			go func(writer int) {
+				defer wg.Done()
+				svc := service.NewSecretsService(&containerCrypto{key: key}, storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser))
+				for i := 0; i < 10; i++ {
+					if err := svc.SaveNewSecret(fmt.Sprintf("writer-%d-%d", writer, i), "value"); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(writer)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			tc.Require.NoError(err)
+		}
+
+		data, err := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser).ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Len(data.Secrets, 20, "Every secret from both writers should be saved")
+	})
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package storage
+
+import (
+	"errors"
+	os "os"
+	"syscall"
+)
+
+// tryLockFile takes the flock on f without blocking. A failed conversion would drop the lock
+// already held through f, so f holds none when this is called, see vaultLock.acquire.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// downgradeFile turns the exclusive flock on f into a shared one. Nothing else can hold the
+// file meanwhile, so the conversion cannot fail.
+func downgradeFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
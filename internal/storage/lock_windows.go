@@ -0,0 +1,44 @@
+//go:build windows
+
+package storage
+
+import (
+	"errors"
+	os "os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffset places the locked byte range past the owner PID so it stays readable
+const lockOffset = 1 << 30
+
+// tryLockFile takes the lock on f without blocking. Windows cannot convert a lock in place,
+// so f holds none when this is called, see vaultLock.acquire.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	overlapped := &windows.Overlapped{Offset: lockOffset}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// downgradeFile turns the exclusive lock on f into a shared one without releasing the range
+// in between: a shared lock may overlap an exclusive one of the same handle, and the first
+// unlock then releases the exclusive lock.
+func downgradeFile(f *os.File) error {
+	overlapped := &windows.Overlapped{Offset: lockOffset}
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped); err != nil {
+		return err
+	}
+	return unlockFile(f)
+}
+
+func unlockFile(f *os.File) error {
+	overlapped := &windows.Overlapped{Offset: lockOffset}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, overlapped)
+}
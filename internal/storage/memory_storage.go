@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps the vault in memory only. It is meant for tests and throwaway sessions:
+// everything is lost when the process exits.
+type MemoryStorage struct {
+	appVersion string
+	appUser    string
+
+	mu     sync.Mutex
+	data   *domain.SecretsFile
+	writes int
+
+	attachments memoryAttachments
+}
+
+// NewMemoryStorage creates an empty in-memory storage
+func NewMemoryStorage(appVersion, appUser string) service.StorageService {
+	return &MemoryStorage{appVersion: appVersion, appUser: appUser}
+}
+
+func (ms *MemoryStorage) ReadSecrets() (domain.SecretsFile, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.data == nil {
+		return domain.SecretsFile{
+			SchemaVersion: domain.CurrentSchemaVersion,
+			AppVersion:    ms.appVersion,
+			AppUser:       ms.appUser,
+			Secrets:       []domain.Secret{},
+		}, nil
+	}
+	return ms.data.Clone(), nil
+}
+
+func (ms *MemoryStorage) WriteSecrets(data domain.SecretsFile) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	stored := data.Clone()
+	stored.SchemaVersion = domain.CurrentSchemaVersion
+	stored.LastUpdated = time.Now().Format(time.RFC3339)
+	ms.data = &stored
+	ms.writes++
+	return nil
+}
+
+// Stamp changes on every write, see service.ChangeDetector
+func (ms *MemoryStorage) Stamp() (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return strconv.Itoa(ms.writes), nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+	os "os"
+	"strconv"
+	"sync"
+)
+
+// Migration upgrades a secrets file document from schema From to From+1.
+// Documents are the generic JSON form of the file so fields can be renamed or restructured.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]any) error
+}
+
+// Migrations are registered only for schema changes that alter what is already stored, such as a
+// renamed or restructured field. Schemas that only add optional fields need none.
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]Migration{}
+)
+
+// RegisterMigration adds a schema upgrade step to the registry
+func RegisterMigration(m Migration) error {
+	if m.From < 0 || m.Apply == nil {
+		return fmt.Errorf("invalid migration definition")
+	}
+
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if _, ok := migrations[m.From]; ok {
+		return fmt.Errorf("migration from schema %d already registered", m.From)
+	}
+	migrations[m.From] = m
+	return nil
+}
+
+// parseDocument decodes a JSON secrets file into its generic form. Numbers keep their text,
+// so the document encodes back to the same values, see fileMAC.
+func parseDocument(raw []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := unmarshalDocument(raw, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("secrets file is empty")
+	}
+	return doc, nil
+}
+
+// unmarshalDocument is json.Unmarshal keeping the numbers of generic values as json.Number
+func unmarshalDocument(raw []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON document")
+	}
+	return nil
+}
+
+// documentOf returns the generic form of v as it is written to disk
+func documentOf(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return parseDocument(raw)
+}
+
+// schemaOf returns the schema a document was written with, 0 for files written before versioning
+func schemaOf(doc map[string]any) (int, error) {
+	switch version := doc["schemaVersion"].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		schema, err := strconv.Atoi(version.String())
+		if err != nil || schema < 0 {
+			return 0, fmt.Errorf("invalid schema version %s", version)
+		}
+		return schema, nil
+	default:
+		return 0, fmt.Errorf("invalid schema version %v", version)
+	}
+}
+
+// keyIDOf returns the UUID of the key the values of a document are encrypted with
+func keyIDOf(doc map[string]any) string {
+	keyID, _ := doc["keyId"].(string)
+	return keyID
+}
+
+// checkSchema refuses documents written with a schema this version does not know
+func checkSchema(doc map[string]any) error {
+	schema, err := schemaOf(doc)
+	if err != nil {
+		return err
+	}
+	if schema > domain.CurrentSchemaVersion {
+		return fmt.Errorf("%w: file schema %d, supported up to %d. Upgrade the password manager to open it",
+			service.ErrSchemaTooNew, schema, domain.CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// upgradeDocument applies the migrations from the schema of doc up to schema to, in place
+func upgradeDocument(doc map[string]any, to int) error {
+	from, err := schemaOf(doc)
+	if err != nil || from >= to {
+		return err
+	}
+
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	for version := from; version < to; version++ {
+		m, ok := migrations[version]
+		if !ok {
+			continue
+		}
+		logger.Debug(fmt.Sprintf("Migrating secrets file from schema %d: %s", version, m.Description))
+		if err := m.Apply(doc); err != nil {
+			return fmt.Errorf("migration from schema %d failed: %w", version, err)
+		}
+	}
+	doc["schemaVersion"] = json.Number(strconv.Itoa(to))
+	return nil
+}
+
+// migrate decodes a secrets file document after upgrading a copy of it to the current schema.
+// Migrations see the document as written, before fields unknown to domain.SecretsFile are dropped.
+// The file on disk is only replaced on the next write, after it has been backed up, see backupSchema.
+func migrate(doc map[string]any) (domain.SecretsFile, error) {
+	if err := checkSchema(doc); err != nil {
+		return domain.SecretsFile{}, err
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return domain.SecretsFile{}, err
+	}
+
+	if schema, _ := schemaOf(doc); schema < domain.CurrentSchemaVersion {
+		upgraded, err := parseDocument(raw)
+		if err != nil {
+			return domain.SecretsFile{}, err
+		}
+		if err := upgradeDocument(upgraded, domain.CurrentSchemaVersion); err != nil {
+			return domain.SecretsFile{}, err
+		}
+		if raw, err = json.Marshal(upgraded); err != nil {
+			return domain.SecretsFile{}, err
+		}
+	}
+
+	var data domain.SecretsFile
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return domain.SecretsFile{}, err
+	}
+	return data, nil
+}
+
+// backupSchema keeps a copy of a file written with an older schema before it is replaced.
+// The first copy of each schema is kept, so it is never overwritten by a later write.
+func (fs *FileStorage) backupSchema(raw []byte, schemaVersion int) error {
+	path := fmt.Sprintf("%s.schema-v%d%s", fs.filePath, schemaVersion, backupSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	logger.Info("Backing up secrets file before upgrading its schema:", path)
+	return fsutil.WriteFileAtomic(path, raw, 0600)
+}
@@ -0,0 +1,204 @@
+package storage_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRaw stores data as an older or newer version would have written it
+func writeRaw(tc *helpers.UnitTestCase, path string, data domain.SecretsFile) []byte {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	tc.Require.NoError(err)
+	tc.Require.NoError(os.WriteFile(path, raw, 0600))
+	return raw
+}
+
+// schema7File is a secrets file of schema 7 as if that schema had called the note of a version
+// changeNote, which noteRenameMigration renames the way a real schema change would
+const schema7File = `{
+  "schemaVersion": 7,
+  "appVersion": "1.0.0",
+  "appUser": "user",
+  "lastUpdated": "2025-06-01T10:00:00Z",
+  "secrets": [
+    {
+      "secretName": "legacy",
+      "type": "key_value",
+      "currentVersion": 2,
+      "versions": [
+        {"secretValueEnc": "djE=", "version": 1, "updatedAt": "2025-05-01T10:00:00Z"},
+        {"secretValueEnc": "djI=", "version": 2, "updatedAt": "2025-06-01T10:00:00Z", "changeNote": "rotated after the leak"}
+      ]
+    }
+  ]
+}`
+
+var noteRenameMigration = storage.Migration{
+	From:        7,
+	Description: "versions call their note changeNote",
+	Apply: func(doc map[string]any) error {
+		secrets, _ := doc["secrets"].([]any)
+		for _, secret := range secrets {
+			fields, _ := secret.(map[string]any)
+			versions, _ := fields["versions"].([]any)
+			for _, version := range versions {
+				version, _ := version.(map[string]any)
+				if note, ok := version["changeNote"]; ok {
+					version["note"] = note
+					delete(version, "changeNote")
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	if err := storage.RegisterMigration(noteRenameMigration); err != nil {
+		panic(err)
+	}
+}
+
+// signDocument signs a secrets file the way a signed write of its schema would have
+func signDocument(tc *helpers.UnitTestCase, raw string, integrity *memoryIntegrity) []byte {
+	var doc map[string]any
+	tc.Require.NoError(json.Unmarshal([]byte(raw), &doc))
+	doc["generation"] = integrity.generation
+	canonical, err := json.Marshal(doc)
+	tc.Require.NoError(err)
+	mac, err := integrity.MAC(canonical)
+	tc.Require.NoError(err)
+	doc["mac"] = base64.StdEncoding.EncodeToString(mac)
+	signed, err := json.MarshalIndent(doc, "", "  ")
+	tc.Require.NoError(err)
+	return signed
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	unversioned := domain.SecretsFile{
+		AppVersion: TestFileStorageVersion,
+		AppUser:    TestFileStorageUser,
+		Secrets:    []domain.Secret{{SecretName: testdata.TestSecrets.Simple.Name, CurrentVersion: 1}},
+	}
+
+	helpers.WithUnitTestCase(t, "Upgrades unversioned files on load", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		writeRaw(tc, path, unversioned)
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(domain.CurrentSchemaVersion, data.SchemaVersion)
+		tc.Assert.Equal(unversioned.Secrets, data.Secrets)
+	})
+
+	helpers.WithUnitTestCase(t, "Backs up the old file before replacing it", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		original := writeRaw(tc, path, unversioned)
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Require.NoError(store.WriteSecrets(data))
+		tc.Require.NoError(store.WriteSecrets(data))
+
+		backup, err := os.ReadFile(path + ".schema-v0.bak")
+		tc.Require.NoError(err, "The unversioned file should be kept")
+		tc.Assert.Equal(original, backup, "Later writes should not replace the schema backup")
+
+		var written domain.SecretsFile
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Require.NoError(json.Unmarshal(raw, &written))
+		tc.Assert.Equal(domain.CurrentSchemaVersion, written.SchemaVersion)
+	})
+
+	helpers.WithUnitTestCase(t, "Verifies signed files before migrating", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		integrity := &memoryIntegrity{generation: 1}
+
+		// Signed by a version that did not record the schema yet
+		raw, err := json.Marshal(unversioned)
+		tc.Require.NoError(err)
+		tc.Require.NoError(os.WriteFile(path, signDocument(tc, string(raw), integrity), 0600))
+
+		store := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, integrity)
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(domain.CurrentSchemaVersion, data.SchemaVersion)
+
+		tc.Require.NoError(store.WriteSecrets(data))
+		_, err = store.ReadSecrets()
+		tc.Assert.NoError(err, "The upgraded file should be signed again")
+	})
+
+	helpers.WithUnitTestCase(t, "Refuses files from a newer schema", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+		tc.Require.NoError(store.WriteSecrets(unversioned))
+		tc.Require.NoError(store.WriteSecrets(unversioned))
+
+		newer := unversioned
+		newer.SchemaVersion = domain.CurrentSchemaVersion + 1
+		original := writeRaw(tc, path, newer)
+
+		_, err := store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrSchemaTooNew, "The previous copy must not be used instead")
+
+		tc.Assert.ErrorIs(store.WriteSecrets(unversioned), service.ErrSchemaTooNew)
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.Equal(original, raw, "The newer file must not be overwritten")
+	})
+
+	helpers.WithUnitTestCase(t, "Migrates fields an older schema named differently", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		tc.Require.NoError(os.WriteFile(path, []byte(schema7File), 0600))
+		store := storage.NewFileStorage(path, TestFileStorageVersion, TestFileStorageUser)
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err)
+		tc.Assert.Equal(domain.CurrentSchemaVersion, data.SchemaVersion)
+		tc.Require.Len(data.Secrets, 1)
+		tc.Assert.Equal("rotated after the leak", data.Secrets[0].Versions[1].Note, "The renamed field should survive the migration")
+		tc.Assert.Empty(data.Secrets[0].Versions[0].Note)
+
+		tc.Require.NoError(store.WriteSecrets(data))
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.Contains(string(raw), `"note": "rotated after the leak"`)
+		tc.Assert.NotContains(string(raw), "changeNote")
+		backup, err := os.ReadFile(path + ".schema-v7.bak")
+		tc.Require.NoError(err)
+		tc.Assert.Equal(schema7File, string(backup))
+	})
+
+	helpers.WithUnitTestCase(t, "Verifies the file as written before migrating it", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json")
+		integrity := &memoryIntegrity{generation: 3}
+		tc.Require.NoError(os.WriteFile(path, signDocument(tc, schema7File, integrity), 0600))
+		store := storage.NewFileStorageWithIntegrity(path, TestFileStorageVersion, TestFileStorageUser, integrity)
+
+		data, err := store.ReadSecrets()
+		tc.Require.NoError(err, "The signature covers the renamed field")
+		tc.Assert.Equal("rotated after the leak", data.Secrets[0].Versions[1].Note)
+
+		tampered := strings.Replace(string(signDocument(tc, schema7File, integrity)), "rotated after the leak", "nothing to see", 1)
+		tc.Require.NoError(os.WriteFile(path, []byte(tampered), 0600))
+		_, err = store.ReadSecrets()
+		tc.Assert.ErrorIs(err, service.ErrVaultTampered, "Fields migrated away must still be signed")
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects duplicate migrations", func(tc *helpers.UnitTestCase) {
+		tc.Assert.Error(storage.RegisterMigration(noteRenameMigration))
+	})
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/service"
+	"net/url"
+	os "os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RemoteTransport moves the sealed vault to and from the other side of a sync without looking into it.
+// Fetch returns nil when nothing was stored yet. Store refuses with service.ErrRemoteChanged unless
+// the remote is still at the expected revision, "" meaning empty.
+type RemoteTransport interface {
+	Fetch() (raw []byte, revision string, err error)
+	Store(raw []byte, expected string) (revision string, err error)
+}
+
+// RemoteType creates transports for the locations with one URL scheme
+type RemoteType struct {
+	Scheme string
+	New    func(location, token string) (RemoteTransport, error) // token authenticates to remotes that require one
+}
+
+var (
+	remotesMu sync.RWMutex
+	remotes   = map[string]RemoteType{}
+)
+
+func init() {
+	mustRegisterRemote(RemoteType{Scheme: "file", New: func(location, _ string) (RemoteTransport, error) {
+		return NewDirectoryTransport(strings.TrimPrefix(location, "file://"), nil), nil
+	}})
+	mustRegisterRemote(RemoteType{Scheme: "http", New: newHTTPTransport})
+	mustRegisterRemote(RemoteType{Scheme: "https", New: newHTTPTransport})
+}
+
+// RegisterRemote adds a remote type to the registry
+func RegisterRemote(r RemoteType) error {
+	if r.Scheme == "" || r.New == nil {
+		return fmt.Errorf("invalid remote type definition")
+	}
+
+	remotesMu.Lock()
+	defer remotesMu.Unlock()
+	if _, ok := remotes[r.Scheme]; ok {
+		return fmt.Errorf("remote type %s already registered", r.Scheme)
+	}
+	remotes[r.Scheme] = r
+	return nil
+}
+
+func mustRegisterRemote(r RemoteType) {
+	if err := RegisterRemote(r); err != nil {
+		panic(err)
+	}
+}
+
+// NewTransport creates the transport for location, a URL or a plain directory path, authenticating with token
+func NewTransport(location, token string) (RemoteTransport, error) {
+	scheme := "file"
+	if parsed, err := url.Parse(location); err == nil && len(parsed.Scheme) > 1 {
+		// One letter schemes are Windows drive letters
+		scheme = parsed.Scheme
+	}
+
+	remotesMu.RLock()
+	r, ok := remotes[scheme]
+	remotesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported sync remote: %s (available: %s)", location, strings.Join(remoteSchemes(), ", "))
+	}
+	return r.New(location, token)
+}
+
+// NewRemote creates the sync remote at location, authenticating with token where the remote requires one.
+// The vault is sealed with crypto before it leaves this machine.
+func NewRemote(location, token string, crypto service.CryptoService) (service.Remote, error) {
+	if crypto == nil {
+		return nil, fmt.Errorf("sync needs a crypto service to seal the vault")
+	}
+	transport, err := NewTransport(location, token)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedRemote{transport: transport, crypto: crypto}, nil
+}
+
+// sealedRemote seals the vault for a transport, see service.Remote
+type sealedRemote struct {
+	transport RemoteTransport
+	crypto    service.CryptoService
+}
+
+func (r *sealedRemote) Pull() (*domain.SecretsFile, string, error) {
+	raw, revision, err := r.transport.Fetch()
+	if err != nil || raw == nil {
+		return nil, revision, err
+	}
+	if _, sealed := parseContainer(raw); !sealed {
+		return nil, "", fmt.Errorf("remote vault is not sealed")
+	}
+
+	var doc map[string]any
+	if err := decodeDocument(r.crypto, raw, &doc); err != nil {
+		return nil, "", err
+	}
+	data, err := migrate(doc)
+	if err != nil {
+		return nil, "", err
+	}
+	return &data, revision, nil
+}
+
+func (r *sealedRemote) Push(data domain.SecretsFile, revision string) (string, error) {
+	data.SchemaVersion = domain.CurrentSchemaVersion
+	raw, err := encodeDocument(r.crypto, data, data.KeyID)
+	if err != nil {
+		return "", err
+	}
+	return r.transport.Store(raw, revision)
+}
+
+// DirectoryTransport keeps the sealed vault in a directory, such as a mounted share or a folder
+// synchronized by another tool. Writers are serialized with the same lock files as the vault.
+type DirectoryTransport struct {
+	dir  string
+	lock *vaultLock
+}
+
+const remoteVaultFile = "vault.sealed"
+
+// NewDirectoryTransport creates a transport storing the vault in dir
+func NewDirectoryTransport(dir string, opts []Option) *DirectoryTransport {
+	return &DirectoryTransport{dir: dir, lock: newVaultLock(remotePath(dir), opts)}
+}
+
+func (dt *DirectoryTransport) Fetch() ([]byte, string, error) {
+	raw, err := os.ReadFile(remotePath(dt.dir))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, revisionOf(raw), nil
+}
+
+func (dt *DirectoryTransport) Store(raw []byte, expected string) (string, error) {
+	if err := os.MkdirAll(dt.dir, 0700); err != nil {
+		return "", err
+	}
+	unlock, err := dt.lock.acquire(true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	_, current, err := dt.Fetch()
+	if err != nil {
+		return "", err
+	}
+	if current != expected {
+		return "", service.ErrRemoteChanged
+	}
+	if err := fsutil.WriteFileAtomic(remotePath(dt.dir), raw, 0600); err != nil {
+		return "", err
+	}
+	return revisionOf(raw), nil
+}
+
+func remotePath(dir string) string {
+	return filepath.Join(dir, remoteVaultFile)
+}
+
+// revisionOf names the contents of a stored vault
+func revisionOf(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// remoteSchemes lists the registered remote types, for error messages
+func remoteSchemes() []string {
+	remotesMu.RLock()
+	defer remotesMu.RUnlock()
+	schemes := make([]string, 0, len(remotes))
+	for scheme := range remotes {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go-password-manager/internal/service"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRemoteVaultSize bounds what the sync handler accepts and the transport reads
+const maxRemoteVaultSize = 64 << 20
+
+// httpTimeout bounds a single request to a sync endpoint
+const httpTimeout = 30 * time.Second
+
+// httpTransport stores the sealed vault at a URL: GET fetches it with its ETag, 404 meaning empty,
+// and PUT replaces it with If-Match, or If-None-Match for an empty remote. 412 means it changed.
+// Every request carries the token as a bearer token. NewRemoteHandler serves this protocol.
+type httpTransport struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newHTTPTransport(location, token string) (RemoteTransport, error) {
+	return &httpTransport{url: location, token: token, client: &http.Client{Timeout: httpTimeout}}, nil
+}
+
+// do sends req with the token, turning a refused token into an error that names the setting
+func (ht *httpTransport) do(req *http.Request) (*http.Response, error) {
+	if ht.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ht.token)
+	}
+	resp, err := ht.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote refused the sync token, check storage.sync.token")
+	}
+	return resp, nil
+}
+
+func (ht *httpTransport) Fetch() ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, ht.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := ht.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", nil
+	default:
+		return nil, "", fmt.Errorf("remote answered %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteVaultSize))
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+func (ht *httpTransport) Store(raw []byte, expected string) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, ht.url, bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if expected == "" {
+		req.Header.Set("If-None-Match", "*")
+	} else {
+		req.Header.Set("If-Match", `"`+expected+`"`)
+	}
+
+	resp, err := ht.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+	case http.StatusPreconditionFailed:
+		return "", service.ErrRemoteChanged
+	default:
+		return "", fmt.Errorf("remote answered %s", resp.Status)
+	}
+}
+
+// NewRemoteHandler serves a transport to other machines over HTTP, see httpTransport.
+// The vault stays sealed: the handler never needs the key. Requests must carry token as a
+// bearer token; with an empty token every request is refused. The handler speaks plain HTTP,
+// so put it behind TLS before exposing it beyond localhost.
+func NewRemoteHandler(transport RemoteTransport, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validBearerToken(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "a valid sync token is required", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			raw, revision, err := transport.Fetch()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if raw == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"`+revision+`"`)
+			_, _ = w.Write(raw)
+
+		case http.MethodPut:
+			raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRemoteVaultSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if _, sealed := parseContainer(raw); !sealed {
+				http.Error(w, "only sealed vaults are accepted", http.StatusBadRequest)
+				return
+			}
+			expected := strings.Trim(r.Header.Get("If-Match"), `"`)
+			if expected == "" && r.Header.Get("If-None-Match") != "*" {
+				http.Error(w, "If-Match or If-None-Match is required", http.StatusPreconditionRequired)
+				return
+			}
+
+			revision, err := transport.Store(raw, expected)
+			if errors.Is(err, service.ErrRemoteChanged) {
+				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("ETag", `"`+revision+`"`)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// validBearerToken reports whether r carries token, comparing in constant time
+func validBearerToken(r *http.Request, token string) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -0,0 +1,152 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"go-password-manager/tests/testdata"
+	"net/http"
+	"net/http/httptest"
+	os "os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newContainerCrypto() *containerCrypto {
+	return &containerCrypto{key: []byte(testdata.TestEncryptionKey)}
+}
+
+func TestDirectoryTransport(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Stores only over the expected revision", func(tc *helpers.UnitTestCase) {
+		transport := storage.NewDirectoryTransport(t.TempDir(), nil)
+
+		raw, revision, err := transport.Fetch()
+		tc.Require.NoError(err)
+		tc.Assert.Nil(raw, "Nothing was stored yet")
+		tc.Assert.Empty(revision)
+
+		first, err := transport.Store([]byte("first"), "")
+		tc.Require.NoError(err)
+		_, err = transport.Store([]byte("other"), "")
+		tc.Assert.ErrorIs(err, service.ErrRemoteChanged, "The remote is no longer empty")
+
+		second, err := transport.Store([]byte("second"), first)
+		tc.Require.NoError(err)
+		tc.Assert.NotEqual(first, second)
+		_, err = transport.Store([]byte("third"), first)
+		tc.Assert.ErrorIs(err, service.ErrRemoteChanged)
+
+		raw, revision, err = transport.Fetch()
+		tc.Require.NoError(err)
+		tc.Assert.Equal("second", string(raw))
+		tc.Assert.Equal(second, revision)
+	})
+}
+
+func TestRemote(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Seals the vault in a directory", func(tc *helpers.UnitTestCase) {
+		dir := t.TempDir()
+		remote, err := storage.NewRemote(dir, "", newContainerCrypto())
+		tc.Require.NoError(err)
+
+		pulled, revision, err := remote.Pull()
+		tc.Require.NoError(err)
+		tc.Assert.Nil(pulled)
+
+		_, err = remote.Push(secretsWith("first"), revision)
+		tc.Require.NoError(err)
+		raw, err := os.ReadFile(filepath.Join(dir, "vault.sealed"))
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), "first")
+
+		pulled, _, err = remote.Pull()
+		tc.Require.NoError(err)
+		tc.Require.NotNil(pulled)
+		tc.Require.Len(pulled.Secrets, 1)
+		tc.Assert.Equal("first", pulled.Secrets[0].SecretName)
+	})
+
+	helpers.WithUnitTestCase(t, "Talks to the HTTP handler", func(tc *helpers.UnitTestCase) {
+		server := httptest.NewServer(storage.NewRemoteHandler(storage.NewDirectoryTransport(t.TempDir(), nil), "sync-token"))
+		defer server.Close()
+
+		remote, err := storage.NewRemote(server.URL+"/vault", "sync-token", newContainerCrypto())
+		tc.Require.NoError(err)
+		_, revision, err := remote.Pull()
+		tc.Require.NoError(err)
+
+		_, err = remote.Push(secretsWith("first"), revision)
+		tc.Require.NoError(err)
+		_, err = remote.Push(secretsWith("second"), revision)
+		tc.Assert.ErrorIs(err, service.ErrRemoteChanged, "The first push changed the remote")
+
+		pulled, revision, err := remote.Pull()
+		tc.Require.NoError(err)
+		tc.Require.NotNil(pulled)
+		tc.Assert.Equal("first", pulled.Secrets[0].SecretName)
+		_, err = remote.Push(secretsWith("second"), revision)
+		tc.Assert.NoError(err)
+	})
+
+	helpers.WithUnitTestCase(t, "The HTTP handler refuses unsealed vaults", func(tc *helpers.UnitTestCase) {
+		server := httptest.NewServer(storage.NewRemoteHandler(storage.NewDirectoryTransport(t.TempDir(), nil), "sync-token"))
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"secrets":[]}`))
+		tc.Require.NoError(err)
+		req.Header.Set("If-None-Match", "*")
+		req.Header.Set("Authorization", "Bearer sync-token")
+		resp, err := http.DefaultClient.Do(req)
+		tc.Require.NoError(err)
+		resp.Body.Close()
+		tc.Assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	})
+
+	helpers.WithUnitTestCase(t, "The HTTP handler refuses requests without the token", func(tc *helpers.UnitTestCase) {
+		server := httptest.NewServer(storage.NewRemoteHandler(storage.NewDirectoryTransport(t.TempDir(), nil), "sync-token"))
+		defer server.Close()
+
+		for _, token := range []string{"", "wrong-token"} {
+			remote, err := storage.NewRemote(server.URL+"/vault", token, newContainerCrypto())
+			tc.Require.NoError(err)
+			_, _, err = remote.Pull()
+			tc.Assert.ErrorContains(err, "refused the sync token")
+			_, err = remote.Push(secretsWith("first"), "")
+			tc.Assert.ErrorContains(err, "refused the sync token")
+		}
+
+		open := httptest.NewServer(storage.NewRemoteHandler(storage.NewDirectoryTransport(t.TempDir(), nil), ""))
+		defer open.Close()
+		remote, err := storage.NewRemote(open.URL+"/vault", "", newContainerCrypto())
+		tc.Require.NoError(err)
+		_, _, err = remote.Pull()
+		tc.Assert.ErrorContains(err, "refused the sync token", "A handler without a token serves nobody")
+	})
+
+	helpers.WithUnitTestCase(t, "Rejects unknown schemes", func(tc *helpers.UnitTestCase) {
+		_, err := storage.NewRemote("ftp://example.com/vault", "", newContainerCrypto())
+		tc.Assert.ErrorContains(err, "unsupported sync remote")
+	})
+}
+
+func TestSyncBase(t *testing.T) {
+	helpers.WithUnitTestCase(t, "Keeps the last synced vault", func(tc *helpers.UnitTestCase) {
+		path := filepath.Join(t.TempDir(), "secrets.json.sync-base")
+		base := storage.NewSyncBase(path, newContainerCrypto())
+
+		data, err := base.ReadBase()
+		tc.Require.NoError(err)
+		tc.Assert.Nil(data, "There is no base before the first sync")
+
+		tc.Require.NoError(base.WriteBase(secretsWith("first")))
+		data, err = base.ReadBase()
+		tc.Require.NoError(err)
+		tc.Require.NotNil(data)
+		tc.Assert.Equal("first", data.Secrets[0].SecretName)
+
+		raw, err := os.ReadFile(path)
+		tc.Require.NoError(err)
+		tc.Assert.NotContains(string(raw), "first")
+	})
+}
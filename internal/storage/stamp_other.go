@@ -0,0 +1,10 @@
+//go:build !unix
+
+package storage
+
+import os "os"
+
+// fileIdentity is not available here, stamps rely on mtime, size and generation
+func fileIdentity(info os.FileInfo) string {
+	return ""
+}
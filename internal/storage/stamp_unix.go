@@ -0,0 +1,18 @@
+//go:build unix
+
+package storage
+
+import (
+	"fmt"
+	os "os"
+	"syscall"
+)
+
+// fileIdentity distinguishes files that replaced each other within one mtime tick.
+// Every write renames a new file into place, so the inode changes with it.
+func fileIdentity(info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf(":%d", stat.Ino)
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/fsutil"
+	"go-password-manager/internal/service"
+	os "os"
+)
+
+// FileSyncBase keeps the vault as of the last sync in a file next to the vault, see service.SyncBase
+type FileSyncBase struct {
+	path   string
+	crypto service.CryptoService
+}
+
+// NewSyncBase creates a sync base stored at path, sealed when crypto is given
+func NewSyncBase(path string, crypto service.CryptoService) service.SyncBase {
+	return &FileSyncBase{path: path, crypto: crypto}
+}
+
+func (b *FileSyncBase) ReadBase() (*domain.SecretsFile, error) {
+	raw, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := decodeDocument(b.crypto, raw, &doc); err != nil {
+		return nil, err
+	}
+	data, err := migrate(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (b *FileSyncBase) WriteBase(data domain.SecretsFile) error {
+	data.SchemaVersion = domain.CurrentSchemaVersion
+	raw, err := encodeDocument(b.crypto, data, data.KeyID)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(b.path, raw, 0600)
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"go-password-manager/internal/logger"
+	os "os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce merges the events of one write, which creates, renames and chmods files, into one change
+const watchDebounce = 100 * time.Millisecond
+
+// watchFiles calls onChange shortly after any of files is created, written, renamed or removed,
+// until stop is called. The directories are watched rather than the files themselves, since
+// atomic writes replace the files and a watch on the old file would go silent.
+func watchFiles(files []string, onChange func()) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, file := range files {
+		file = filepath.Clean(file)
+		watched[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var mu sync.Mutex
+		var pending *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !watched[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+					continue
+				}
+				mu.Lock()
+				if pending != nil {
+					pending.Stop()
+				}
+				pending = time.AfterFunc(watchDebounce, func() {
+					select {
+					case <-done:
+					default:
+						onChange()
+					}
+				})
+				mu.Unlock()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Vault watcher:", err.Error())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			watcher.Close()
+		})
+	}, nil
+}
+
+// Watch reports changes to the secrets file, see service.Watcher
+func (fs *FileStorage) Watch(onChange func()) (func(), error) {
+	return watchFiles([]string{fs.filePath}, onChange)
+}
+
+// Watch reports changes to the vault, see service.Watcher. The index is rewritten on every write.
+func (ds *DirectoryStorage) Watch(onChange func()) (func(), error) {
+	if err := os.MkdirAll(ds.dir, 0700); err != nil {
+		return nil, err
+	}
+	return watchFiles([]string{filepath.Join(ds.dir, directoryIndexFile)}, onChange)
+}
+
+// Watch reports changes to the snapshot or the journal, see service.Watcher
+func (js *JournalStorage) Watch(onChange func()) (func(), error) {
+	return watchFiles([]string{js.path + snapshotSuffix, js.path + journalSuffix}, onChange)
+}
@@ -0,0 +1,52 @@
+package storage_test
+
+import (
+	"go-password-manager/internal/service"
+	"go-password-manager/internal/storage"
+	"go-password-manager/tests/helpers"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	newStores := map[string]func(dir string) service.StorageService{
+		"file": func(dir string) service.StorageService {
+			return storage.NewFileStorage(filepath.Join(dir, "secrets.json"), TestFileStorageVersion, TestFileStorageUser)
+		},
+		"directory": func(dir string) service.StorageService {
+			return storage.NewDirectoryStorage(filepath.Join(dir, "vault"), TestFileStorageVersion, TestFileStorageUser, nil)
+		},
+		"journal": func(dir string) service.StorageService {
+			return storage.NewJournalStorage(filepath.Join(dir, "secrets.json"), TestFileStorageVersion, TestFileStorageUser, nil, 0)
+		},
+	}
+
+	for name, newStore := range newStores {
+		helpers.WithUnitTestCase(t, "Reports writes to the "+name+" storage", func(tc *helpers.UnitTestCase) {
+			dir := t.TempDir()
+			watched, other := newStore(dir), newStore(dir)
+			tc.Require.NoError(watched.WriteSecrets(secretsWith("first")))
+
+			changes := make(chan struct{}, 10)
+			stop, err := watched.(service.Watcher).Watch(func() { changes <- struct{}{} })
+			tc.Require.NoError(err)
+			defer stop()
+
+			tc.Require.NoError(other.WriteSecrets(secretsWith("first", "second")))
+			select {
+			case <-changes:
+			case <-time.After(5 * time.Second):
+				tc.Assert.Fail("No change was reported")
+			}
+
+			stop()
+			tc.Require.NoError(other.WriteSecrets(secretsWith("third")))
+			select {
+			case <-changes:
+				tc.Assert.Fail("Changes were reported after stop")
+			case <-time.After(300 * time.Millisecond):
+			}
+		})
+	}
+}
@@ -37,7 +37,9 @@ func NewMainPageObject(t *testing.T, window fyne.Window, secretsService *service
 
 // LoadPage loads the main page content
 func (p *MainPageObject) LoadPage() {
-	p.mainContent = pages.MainPageWithService(p.window, p.secretsService, p.configService)
+	content, closePage := pages.MainPageWithService(p.window, p.secretsService, p.configService)
+	p.t.Cleanup(closePage)
+	p.mainContent = content
 	p.window.SetContent(p.mainContent)
 	p.waitForUIUpdate()
 }
@@ -12,6 +12,7 @@ import (
 
 	buildconfig "go-password-manager/internal/config/buildconfig"
 	"go-password-manager/internal/service"
+	"go-password-manager/tests/testdata"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/test"
@@ -82,6 +83,9 @@ func (suite *E2ETestSuite) SetupTestEnvironment() {
 	if err != nil {
 		suite.t.Fatalf("Failed to create crypto service: %v", err)
 	}
+	if err := cryptoService.Unlock(testdata.TestMasterPassword); err != nil {
+		suite.t.Fatalf("Failed to unlock crypto service: %v", err)
+	}
 	secretsPath, err := buildCfg.GetSecretsFilePath()
 	if err != nil {
 		suite.t.Fatalf("Failed to get secrets file path: %v", err)
@@ -33,6 +33,7 @@ func testCreateEditDeleteWorkflow(reporter *reporting.TestWrapper) {
 
 	cryptoService, err := crypto.NewCryptoService(configService)
 	require.NoError(t, err, "Failed to create crypto service")
+	require.NoError(t, cryptoService.Unlock(testdata.TestMasterPassword), "Failed to unlock crypto service")
 
 	secretsPath, err := buildCfg.GetSecretsFilePath()
 	require.NoError(t, err)
@@ -80,7 +81,7 @@ func testEditSecretWorkflow(reporter *reporting.TestWrapper, secretsService *ser
 	newValue := testdata.TestSecrets.Complex.Value
 
 	reporter.LogStep("Editing secret to create a new version", map[string]interface{}{"newValue": newValue})
-	err := secretsService.UpdateSecret(secretName, newValue)
+	err := secretsService.UpdateSecret(secretName, newValue, "")
 	require.NoError(t, err, "Failed to edit secret")
 
 	// Test 5: Verify edit created new version
@@ -139,6 +140,7 @@ func TestErrorHandlingE2E(t *testing.T) {
 
 		cryptoService, err := crypto.NewCryptoService(configService)
 		require.NoError(t, err, "Failed to create crypto service")
+		require.NoError(t, cryptoService.Unlock(testdata.TestMasterPassword), "Failed to unlock crypto service")
 
 		secretsPath, err := buildCfg.GetSecretsFilePath()
 		require.NoError(t, err)
@@ -147,7 +149,7 @@ func TestErrorHandlingE2E(t *testing.T) {
 
 		// Test error handling - edit non-existent secret
 		reporter.LogStep("Testing error on editing non-existent secret", nil)
-		err = secretsService.UpdateSecret("non-existent-secret", "some-value")
+		err = secretsService.UpdateSecret("non-existent-secret", "some-value", "")
 		require.Error(t, err, "Expected error when editing non-existent secret")
 
 		// Test error handling - delete non-existent secret (should not error but should be idempotent)
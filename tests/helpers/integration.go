@@ -8,6 +8,7 @@ import (
 	"go-password-manager/internal/service"
 	"go-password-manager/internal/storage"
 	"go-password-manager/tests/reporting"
+	"go-password-manager/tests/testdata"
 	"os"
 )
 
@@ -60,6 +61,9 @@ func (suite *IntegrationTestSuite) SetupTestEnvironment() {
 	if err != nil {
 		suite.Reporter.T().Fatalf("Failed to create crypto service: %v", err)
 	}
+	if err := suite.CryptoService.Unlock(testdata.TestMasterPassword); err != nil {
+		suite.Reporter.T().Fatalf("Failed to unlock crypto service: %v", err)
+	}
 
 	// Initialize secrets service with test configuration
 	secretsPath, err := suite.BuildConfig.GetSecretsFilePath()
@@ -72,7 +72,7 @@ func testEditSecretWorkflow(reporter *reporting.TestWrapper, secretsService *ser
 	// Test 4: Edit secret (create new version) using unique data
 	newValue := uniqueComplexSecret.Value
 
-	err = secretsService.UpdateSecret(secretName, newValue)
+	err = secretsService.UpdateSecret(secretName, newValue, "")
 	require.NoError(reporter.T(), err, "Failed to edit secret")
 
 	// Test 5: Verify edit created new version
@@ -125,7 +125,7 @@ func TestSecretVersioning(t *testing.T) {
 
 		// Edit the secret to create a new version
 		newSecretValue := "UpdatedSuperSecretPassword456"
-		err = suite.SecretsService.UpdateSecret(secretName, newSecretValue)
+		err = suite.SecretsService.UpdateSecret(secretName, newSecretValue, "")
 		require.NoError(t, err, "Should be able to edit secret")
 
 		// Verify version count increased
@@ -205,7 +205,7 @@ func TestErrorHandlingForNonExistentSecret(t *testing.T) {
 		suite.SetupTestEnvironment()
 		defer suite.Cleanup()
 
-		err := suite.SecretsService.UpdateSecret("NonExistentSecret", "NewValue")
+		err := suite.SecretsService.UpdateSecret("NonExistentSecret", "NewValue", "")
 		assert.Error(t, err, "Should return error for non-existent secret")
 
 		err = suite.SecretsService.DeleteSecret("NonExistentSecret")
@@ -53,6 +53,7 @@ type TestScenariosCollection struct {
 const (
 	TestEncryptionKey      = "12345678901234567890123456789012"
 	DifferentEncryptionKey = "abcdefghijklmnopqrstuvwxyz123456"
+	TestMasterPassword     = "test-master-password"
 )
 
 var (
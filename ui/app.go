@@ -4,6 +4,7 @@ import (
 	"fmt"
 	buildconfig "go-password-manager/internal/config/buildconfig"
 	config "go-password-manager/internal/config/runtimeconfig"
+	"go-password-manager/internal/crypto"
 	"go-password-manager/internal/logger"
 	"go-password-manager/internal/service"
 	pages "go-password-manager/ui/pages"
@@ -20,6 +21,8 @@ type App struct {
 	configService  *config.ConfigService
 	buildconfig    *buildconfig.Config
 	secretsService *service.SecretsService
+	cryptoService  *crypto.CryptoService
+	closePage      func() // Releases what the shown page holds, like its vault watcher
 }
 
 const (
@@ -27,15 +30,14 @@ const (
 	FALLBACK_WINDOW_HEIGHT = 1100
 )
 
-// NewApp creates a new application instance
-func NewApp(buildCfg *buildconfig.Config, secretsService *service.SecretsService) *App {
+// NewApp creates a new application instance. configService is the one the crypto service was
+// created with, so key rotations and the window size are recorded in the same config.
+func NewApp(buildCfg *buildconfig.Config, configService *config.ConfigService, secretsService *service.SecretsService, cryptoService *crypto.CryptoService) *App {
 	fyneApp := app.New()
 	fyneApp.Settings().SetTheme(&themes.LightTheme{})
 	window := fyneApp.NewWindow(buildCfg.Application.Name)
 
-	// Load legacy config service for window size persistence
-	configService, err := config.NewConfigService(buildCfg)
-	if err != nil {
+	if configService == nil {
 		// Use environment config defaults
 		window.Resize(fyne.NewSize(
 			float32(FALLBACK_WINDOW_WIDTH),
@@ -63,15 +65,22 @@ func NewApp(buildCfg *buildconfig.Config, secretsService *service.SecretsService
 		configService:  configService,
 		buildconfig:    buildCfg,
 		secretsService: secretsService,
+		cryptoService:  cryptoService,
 	}
 }
 
 // Run starts the application
 func (a *App) Run() {
-	a.window.SetContent(pages.MainPageWithService(a.window, a.secretsService, a.configService))
+	if a.cryptoService.IsUnlocked() {
+		a.purgeExpiredTrash()
+		a.showMainPage()
+	} else {
+		a.showUnlockPage()
+	}
 
 	// Save window size on close
 	a.window.SetOnClosed(func() {
+		a.closeShownPage()
 		if a.configService != nil {
 			size := a.window.Canvas().Size()
 			_ = a.configService.SetWindowSize(int(size.Width), int(size.Height))
@@ -80,3 +89,61 @@ func (a *App) Run() {
 
 	a.window.ShowAndRun()
 }
+
+// showUnlockPage asks for the master password and opens the vault once it is accepted
+func (a *App) showUnlockPage() {
+	initialized, err := a.cryptoService.IsInitialized()
+	if err != nil {
+		logger.Error("Failed to check vault key:", err.Error())
+	}
+
+	a.setPage(pages.UnlockPage(a.window, pages.UnlockPageProps{
+		IsNewVault: !initialized,
+		OnUnlock: func(masterPassword string) error {
+			if err := a.cryptoService.Unlock(masterPassword); err != nil {
+				return err
+			}
+			if a.configService != nil {
+				if err := a.secretsService.ResumeKeyRotation(a.configService); err != nil {
+					return err
+				}
+			}
+			if err := a.secretsService.MigrateSecrets(); err != nil {
+				return err
+			}
+			a.purgeExpiredTrash()
+			a.showMainPage()
+			return nil
+		},
+	}), nil)
+}
+
+// purgeExpiredTrash removes the secrets that stayed in the trash longer than the configured retention
+func (a *App) purgeExpiredTrash() {
+	retention := a.buildconfig.GetTrashRetention()
+	if retention == 0 {
+		return
+	}
+	if _, err := a.secretsService.PurgeTrash(retention); err != nil {
+		logger.Warn("Failed to purge expired secrets from the trash:", err.Error())
+	}
+}
+
+// showMainPage replaces the window content with the secrets view
+func (a *App) showMainPage() {
+	a.setPage(pages.MainPageWithService(a.window, a.secretsService, a.configService))
+}
+
+// setPage replaces the window content, closing the page shown before. release may be nil.
+func (a *App) setPage(content fyne.CanvasObject, release func()) {
+	a.closeShownPage()
+	a.closePage = release
+	a.window.SetContent(content)
+}
+
+func (a *App) closeShownPage() {
+	if a.closePage != nil {
+		a.closePage()
+		a.closePage = nil
+	}
+}
@@ -64,7 +64,7 @@ func CreateMainContent(window fyne.Window) fyne.CanvasObject {
 	updateDetail = func() {
 		detailBox.Objects = nil
 		if selectedIdx >= 0 && selectedIdx < len(fileData.Secrets) {
-			detailBox.Add(molecules.SecretDetail(fileData.Secrets[selectedIdx], secretsService, window, refreshDetail))
+			detailBox.Add(molecules.SecretDetail(fileData.Secrets[selectedIdx], secretsService, window, refreshDetail, nil, nil))
 		} else {
 			detailBox.Add(widget.NewLabel("Select a secret"))
 		}
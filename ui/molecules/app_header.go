@@ -11,6 +11,9 @@ type AppHeaderProps struct {
 	OnCreateSecret func()
 	OnMenuAction   func()
 	OnThemeChange  func(themeName string) // Add this for theme switching
+	OnRotateKey    func()
+	OnShowBackups  func()
+	OnShowTrash    func()
 }
 
 // headerLayout lays out the search box at 50% width and the buttons at the far right, with padding.
@@ -62,7 +65,7 @@ func (headerLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
 // AppHeader renders the search box, menu button, and create button in a responsive header
 func AppHeader(props AppHeaderProps, win fyne.Window) fyne.CanvasObject {
 	searchEntry := widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search...")
+	searchEntry.SetPlaceHolder("Search... (tag:prod folder:aws/)")
 	searchEntry.OnChanged = props.OnSearch
 
 	menuBtn := widget.NewButton("☰", nil)
@@ -84,7 +87,25 @@ func AppHeader(props AppHeaderProps, win fyne.Window) fyne.CanvasObject {
 		themesItem := fyne.NewMenuItem("Themes", nil)
 		themesItem.ChildMenu = themesSubMenu
 
-		mainMenu := fyne.NewMenu("Menu", themesItem /*, other items here */)
+		rotateKeyItem := fyne.NewMenuItem("Rotate Encryption Key", func() {
+			if props.OnRotateKey != nil {
+				props.OnRotateKey()
+			}
+		})
+
+		backupsItem := fyne.NewMenuItem("Backups…", func() {
+			if props.OnShowBackups != nil {
+				props.OnShowBackups()
+			}
+		})
+
+		trashItem := fyne.NewMenuItem("Trash…", func() {
+			if props.OnShowTrash != nil {
+				props.OnShowTrash()
+			}
+		})
+
+		mainMenu := fyne.NewMenu("Menu", themesItem, fyne.NewMenuItemSeparator(), rotateKeyItem, backupsItem, trashItem /*, other items here */)
 		pop := widget.NewPopUpMenu(mainMenu, win.Canvas())
 		pop.ShowAtPosition(menuBtn.Position().AddXY(0, menuBtn.Size().Height))
 	}
@@ -0,0 +1,85 @@
+package molecules
+
+import (
+	"fmt"
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+	"io"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Attachments lists the files attached to the current version of a secret, attaches new ones
+// and saves them to disk
+func Attachments(secret domain.Secret, secretsService *service.SecretsService, window fyne.Window, onUpdate func()) fyne.CanvasObject {
+	title := widget.NewLabel("Attachments")
+	title.TextStyle = fyne.TextStyle{Bold: true}
+
+	changed := func(err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if onUpdate != nil {
+			onUpdate()
+		}
+	}
+
+	attachBtn := widget.NewButton("📎", func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			defer reader.Close()
+			data, err := io.ReadAll(io.LimitReader(reader, service.MaxAttachmentSize+1))
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			_, err = secretsService.AddAttachment(secret.SecretName, reader.URI().Name(), reader.URI().MimeType(), data)
+			changed(err)
+		}, window)
+	})
+
+	rows := container.NewVBox()
+	if current := secret.GetCurrentVersion(); current != nil {
+		for _, attachment := range current.Attachments {
+			attachment := attachment
+			label := widget.NewLabel(fmt.Sprintf("%s (%s, %d bytes)", attachment.Name, attachment.MIMEType, attachment.Size))
+
+			saveBtn := widget.NewButton("💾", func() {
+				data, err := secretsService.ExtractAttachment(&secret, attachment.ID)
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+					if err != nil || writer == nil {
+						return
+					}
+					defer writer.Close()
+					if _, err := writer.Write(data); err != nil {
+						logger.Error("Failed to save attachment:", err.Error())
+						dialog.ShowError(err, window)
+					}
+				}, window)
+				save.SetFileName(attachment.Name)
+				save.Show()
+			})
+			removeBtn := widget.NewButton("🗑", func() {
+				dialog.ShowConfirm("Remove Attachment", "Remove '"+attachment.Name+"' from this secret? Older versions keep it.", func(ok bool) {
+					if ok {
+						changed(secretsService.RemoveAttachment(secret.SecretName, attachment.ID))
+					}
+				}, window)
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(saveBtn, removeBtn), label))
+		}
+	}
+
+	return container.NewVBox(container.NewBorder(nil, nil, nil, attachBtn, title), rows)
+}
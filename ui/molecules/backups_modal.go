@@ -0,0 +1,62 @@
+package molecules
+
+import (
+	"fmt"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// BackupsModal lists the vault backups, newest first, and restores the chosen one.
+// onRestored is called after a backup replaced the vault.
+func BackupsModal(window fyne.Window, secretsService *service.SecretsService, onRestored func()) {
+	backups, err := secretsService.ListBackups()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	var modal dialog.Dialog
+	backupsBox := container.NewVBox()
+	if len(backups) == 0 {
+		backupsBox.Add(widget.NewLabel("No backups yet. One is taken before every change."))
+	}
+
+	for _, backup := range backups {
+		id := backup.ID
+		label := widget.NewLabel(fmt.Sprintf("%s (%d bytes)", backup.CreatedAt.Local().Format("Jan 2, 2006 15:04:05"), backup.Size))
+		restoreBtn := widget.NewButton("Restore", func() {
+			dialog.ShowConfirm(
+				"Restore Backup",
+				"Replace the vault with this backup? The current vault is backed up first.",
+				func(confirm bool) {
+					if !confirm {
+						return
+					}
+					if err := secretsService.RestoreBackup(id); err != nil {
+						logger.Error("Restoring backup failed:", err.Error())
+						dialog.ShowError(err, window)
+						return
+					}
+					modal.Hide()
+					if onRestored != nil {
+						onRestored()
+					}
+					dialog.ShowInformation("Restore Backup", "The backup was restored.", window)
+				},
+				window,
+			)
+		})
+		backupsBox.Add(container.NewBorder(nil, nil, nil, restoreBtn, label))
+	}
+
+	scroll := container.NewVScroll(backupsBox)
+	scroll.SetMinSize(fyne.NewSize(480, 320))
+
+	modal = dialog.NewCustom("Backups", "Close", scroll, window)
+	modal.Show()
+}
@@ -0,0 +1,127 @@
+package molecules
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/ui/atoms"
+	"go-password-manager/ui/helpers"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var customFieldTypes = []domain.CustomFieldType{domain.CustomFieldText, domain.CustomFieldURL, domain.CustomFieldEmail, domain.CustomFieldOTP}
+
+// CustomFields lists the custom fields of a secret with reveal and copy, and adds, edits and removes them
+func CustomFields(secret domain.Secret, secretsService *service.SecretsService, window fyne.Window, onUpdate func()) fyne.CanvasObject {
+	title := widget.NewLabel("Custom Fields")
+	title.TextStyle = fyne.TextStyle{Bold: true}
+
+	fields, err := secretsService.GetCustomFields(&secret)
+	if err != nil {
+		return container.NewVBox(title, widget.NewLabel("Failed to decrypt: "+err.Error()))
+	}
+
+	changed := func(err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if onUpdate != nil {
+			onUpdate()
+		}
+	}
+	addBtn := widget.NewButton("➕", func() {
+		customFieldModal(window, "Add Field", domain.CustomField{Type: domain.CustomFieldText}, func(field domain.CustomField) {
+			changed(secretsService.AddCustomField(secret.SecretName, field))
+		})
+	})
+
+	revealed := map[string]bool{}
+	rows := container.NewVBox()
+	var showRows func()
+	showRows = func() {
+		rows.Objects = nil
+		for _, field := range fields {
+			field := field
+			copyValue := func() { helpers.CopyToClipboard(field.Value, window) }
+
+			var value fyne.CanvasObject
+			if field.Hidden {
+				value = atoms.SecretValue(atoms.SecretValueProps{
+					Value:      field.Value,
+					IsRevealed: revealed[field.Label],
+					OnRevealClick: func() {
+						revealed[field.Label] = !revealed[field.Label]
+						showRows()
+					},
+					OnValueClick: copyValue,
+				})
+			} else {
+				valueButton := widget.NewButton(field.Value, copyValue)
+				valueButton.Importance = widget.LowImportance
+				valueButton.Alignment = widget.ButtonAlignLeading
+				value = valueButton
+			}
+
+			editBtn := widget.NewButton("✏️", func() {
+				customFieldModal(window, "Edit Field", field, func(updated domain.CustomField) {
+					changed(secretsService.UpdateCustomField(secret.SecretName, field.Label, updated))
+				})
+			})
+			removeBtn := widget.NewButton("🗑", func() {
+				dialog.ShowConfirm("Remove Field", "Remove '"+field.Label+"' from this secret?", func(ok bool) {
+					if ok {
+						changed(secretsService.RemoveCustomField(secret.SecretName, field.Label))
+					}
+				}, window)
+			})
+			label := widget.NewLabel(field.Label)
+			rows.Add(container.NewBorder(nil, nil, label, container.NewHBox(editBtn, removeBtn), value))
+		}
+		rows.Refresh()
+	}
+	showRows()
+
+	return container.NewVBox(container.NewBorder(nil, nil, nil, addBtn, title), rows)
+}
+
+// customFieldModal asks for the label, type, value and visibility of a custom field
+func customFieldModal(window fyne.Window, title string, field domain.CustomField, onSave func(domain.CustomField)) {
+	labelEntry := widget.NewEntry()
+	labelEntry.SetText(field.Label)
+	valueEntry := widget.NewEntry()
+	valueEntry.SetText(field.Value)
+
+	typeNames := make([]string, len(customFieldTypes))
+	for i, t := range customFieldTypes {
+		typeNames[i] = string(t)
+	}
+	typeSelect := widget.NewSelect(typeNames, nil)
+	typeSelect.SetSelected(string(field.Type))
+	if typeSelect.Selected == "" {
+		typeSelect.SetSelected(string(domain.CustomFieldText))
+	}
+	hiddenCheck := widget.NewCheck("Hide until revealed", nil)
+	hiddenCheck.SetChecked(field.Hidden)
+
+	size := fyne.NewSize(400, labelEntry.MinSize().Height)
+	form := []*widget.FormItem{
+		widget.NewFormItem("Label", container.NewGridWrap(size, labelEntry)),
+		widget.NewFormItem("Type", typeSelect),
+		widget.NewFormItem("Value", container.NewGridWrap(size, valueEntry)),
+		widget.NewFormItem("", hiddenCheck),
+	}
+	dialog.ShowForm(title, "Save", "Cancel", form, func(ok bool) {
+		if ok {
+			onSave(domain.CustomField{
+				Label:  labelEntry.Text,
+				Value:  valueEntry.Text,
+				Type:   domain.CustomFieldType(typeSelect.Selected),
+				Hidden: hiddenCheck.Checked,
+			})
+		}
+	}, window)
+}
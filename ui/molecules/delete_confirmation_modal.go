@@ -25,7 +25,7 @@ func DeleteConfirmationModal(window fyne.Window, props DeleteConfirmationModalPr
 		fyne.TextStyle{Bold: true},
 	)
 	warning := widget.NewLabelWithStyle(
-		"This action cannot be undone. All versions of this secret will be permanently deleted.",
+		"The secret and all its versions move to the trash, where it can be restored until it is purged.",
 		fyne.TextAlignCenter,
 		fyne.TextStyle{Bold: true},
 	)
@@ -34,7 +34,7 @@ func DeleteConfirmationModal(window fyne.Window, props DeleteConfirmationModalPr
 	gap := canvas.NewRectangle(bgColor)
 	gap.SetMinSize(fyne.NewSize(0, 24)) // 24px tall gap
 
-	warning.Importance = widget.DangerImportance
+	warning.Importance = widget.WarningImportance
 
 	content := container.NewVBox(
 		boldMsg,
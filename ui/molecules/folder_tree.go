@@ -0,0 +1,81 @@
+package molecules
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Tree node IDs: the folder and tag nodes are the search filters they select
+const (
+	treeAllNode  = "all"
+	treeTagsNode = "tags"
+)
+
+// FolderTree is the sidebar with all secrets, the folders and the tags. data is read again on every Refresh.
+// Selecting a node calls onSelect with the search filter it stands for, empty for all secrets.
+func FolderTree(data func() (folders, tags []string), onSelect func(filter string)) *widget.Tree {
+	children := func(id widget.TreeNodeID) []widget.TreeNodeID {
+		folders, tags := data()
+		var ids []widget.TreeNodeID
+		switch {
+		case id == "":
+			ids = append(ids, treeAllNode)
+			for _, folder := range folders {
+				if !strings.Contains(folder, "/") {
+					ids = append(ids, "folder:"+folder)
+				}
+			}
+			if len(tags) > 0 {
+				ids = append(ids, treeTagsNode)
+			}
+		case id == treeTagsNode:
+			for _, tag := range tags {
+				ids = append(ids, "tag:"+tag)
+			}
+		case strings.HasPrefix(id, "folder:"):
+			parent := strings.TrimPrefix(id, "folder:") + "/"
+			for _, folder := range folders {
+				if strings.HasPrefix(folder, parent) && !strings.Contains(strings.TrimPrefix(folder, parent), "/") {
+					ids = append(ids, "folder:"+folder)
+				}
+			}
+		}
+		return ids
+	}
+
+	tree := widget.NewTree(
+		children,
+		func(id widget.TreeNodeID) bool {
+			return id == "" || len(children(id)) > 0
+		},
+		func(bool) fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TreeNodeID, _ bool, object fyne.CanvasObject) {
+			label := object.(*widget.Label)
+			switch {
+			case id == treeAllNode:
+				label.SetText("All secrets")
+			case id == treeTagsNode:
+				label.SetText("Tags")
+			case strings.HasPrefix(id, "folder:"):
+				folder := strings.TrimPrefix(id, "folder:")
+				label.SetText("📁 " + folder[strings.LastIndex(folder, "/")+1:])
+			case strings.HasPrefix(id, "tag:"):
+				label.SetText("🏷 " + strings.TrimPrefix(id, "tag:"))
+			}
+		},
+	)
+	tree.OnSelected = func(id widget.TreeNodeID) {
+		switch id {
+		case treeAllNode:
+			onSelect("")
+		case treeTagsNode:
+		default:
+			onSelect(id)
+		}
+	}
+	return tree
+}
@@ -1,6 +1,7 @@
 package molecules
 
 import (
+	"go-password-manager/internal/domain"
 	"go-password-manager/internal/service"
 
 	"fyne.io/fyne/v2"
@@ -15,18 +16,39 @@ func NewSecretModal(win fyne.Window, secretsService *service.SecretsService, onS
 	nameEntry.SetPlaceHolder("Secret name")
 	nameRow := container.NewGridWrap(fyne.NewSize(500, nameEntry.MinSize().Height), nameEntry)
 
-	valueEntry := widget.NewEntry()
-	valueEntry.SetPlaceHolder("Secret value")
-	valueRow := container.NewGridWrap(fyne.NewSize(500, valueEntry.MinSize().Height), valueEntry)
+	// The fields below the name follow the selected type
+	types := service.SecretTypes()
+	labels := make([]string, len(types))
+	for i, t := range types {
+		labels[i] = t.Label
+	}
+	spec := types[0]
+	fieldsContainer := container.NewVBox()
+	var getFields func() map[string]string
+	showFields := func() {
+		var fieldsForm *widget.Form
+		fieldsForm, getFields = secretFieldsForm(spec, nil)
+		fieldsContainer.Objects = []fyne.CanvasObject{fieldsForm}
+		fieldsContainer.Refresh()
+	}
+	typeSelect := widget.NewSelect(labels, func(label string) {
+		for _, t := range types {
+			if t.Label == label {
+				spec = t
+			}
+		}
+		showFields()
+	})
+	typeSelect.SetSelected(spec.Label)
 
 	form := widget.NewForm(
 		widget.NewFormItem("Name", nameRow),
-		widget.NewFormItem("Value", valueRow),
+		widget.NewFormItem("Type", typeSelect),
 	)
 
 	bgColor := fyne.CurrentApp().Settings().Theme().Color("overlayBackground", fyne.CurrentApp().Settings().ThemeVariant())
 	// Rectangle background for the form area
-	paddedForm := container.NewPadded(form)
+	paddedForm := container.NewPadded(container.NewVBox(form, fieldsContainer))
 
 	// Add a gap below the form, above the buttons
 	gap := canvas.NewRectangle(bgColor)
@@ -43,14 +65,19 @@ func NewSecretModal(win fyne.Window, secretsService *service.SecretsService, onS
 		"Cancel",
 		content,
 		func(ok bool) {
-			if ok {
-				name := nameEntry.Text
-				value := valueEntry.Text
-				if name != "" && value != "" {
-					_ = secretsService.SaveNewSecret(name, value)
-					onSuccess()
-				}
+			if !ok {
+				return
+			}
+			name := nameEntry.Text
+			fields := getFields()
+			if name == "" || (!spec.Structured() && fields[domain.FieldValue] == "") {
+				return
+			}
+			if err := secretsService.SaveNewTypedSecret(name, spec.Type, fields); err != nil {
+				dialog.ShowError(err, win)
+				return
 			}
+			onSuccess()
 		},
 		win,
 	).Show()
@@ -8,10 +8,22 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
-func SecretDetail(secret domain.Secret, secretsService *service.SecretsService, window fyne.Window, onUpdate func()) fyne.CanvasObject {
+// SecretDetail shows a secret with its history. onEditing, if set, is told when an edit starts and ends;
+// onSelect, if set, is given the secret to show after a rename or duplicate.
+func SecretDetail(secret domain.Secret, secretsService *service.SecretsService, window fyne.Window, onUpdate func(), onEditing func(bool), onSelect func(name string)) fyne.CanvasObject {
+	if spec, err := service.LookupSecretType(secret.Type); err == nil && spec.Structured() {
+		return container.NewVBox(
+			typedSecretDetail(secret, spec, secretsService, window, onUpdate, onEditing),
+			SecretOrganize(secret, secretsService, window, onUpdate, onSelect),
+			CustomFields(secret, secretsService, window, onUpdate),
+			Attachments(secret, secretsService, window, onUpdate),
+			SecretHistory(secret.SecretName, secretsService, window, onUpdate),
+		)
+	}
 
 	revealed := false
 	editMode := false
@@ -24,6 +36,9 @@ func SecretDetail(secret domain.Secret, secretsService *service.SecretsService,
 	// Secret value entry for edit mode
 	valueEntry := widget.NewEntry()
 	valueEntry.Hide() // Initially hidden
+	noteEntry := widget.NewEntry()
+	noteEntry.SetPlaceHolder("Why did it change? (optional)")
+	noteEntry.Hide()
 
 	// Edit/Save button
 	editBtn := widget.NewButton("✏️", nil)
@@ -72,6 +87,9 @@ func SecretDetail(secret domain.Secret, secretsService *service.SecretsService,
 			// Enter edit mode
 			editMode = true
 			editBtn.SetText("💾") // Save icon
+			if onEditing != nil {
+				onEditing(true)
+			}
 
 			// Get current value and show in entry
 			plain, err := secretsService.GetSecretValue(&secret)
@@ -83,21 +101,29 @@ func SecretDetail(secret domain.Secret, secretsService *service.SecretsService,
 			// Hide main value container, show entry
 			mainValueContainer.Hide()
 			valueEntry.Show()
+			noteEntry.SetText("")
+			noteEntry.Show()
 		} else {
 			// Save mode
 			newValue := valueEntry.Text
 			if newValue != "" {
 				// Update the secret using EditSecret method
-				err := secretsService.UpdateSecret(secret.SecretName, newValue)
-				if err == nil {
+				err := secretsService.UpdateSecret(secret.SecretName, newValue, noteEntry.Text)
+				if err != nil {
+					dialog.ShowError(err, window)
+				} else {
 					// Exit edit mode
 					editMode = false
 					revealed = false
 					editBtn.SetText("✏️") // Edit icon
 					currentPlainValue = ""
+					if onEditing != nil {
+						onEditing(false)
+					}
 
 					// Hide entry, show main value container
 					valueEntry.Hide()
+					noteEntry.Hide()
 					updateMainValueDisplay() // Refresh to reset state
 					mainValueContainer.Show()
 
@@ -114,7 +140,7 @@ func SecretDetail(secret domain.Secret, secretsService *service.SecretsService,
 	valueRow := container.NewStack(mainValueContainer, valueEntry)
 
 	// History component
-	historyComponent := SecretHistory(secret.SecretName, secretsService, window)
+	historyComponent := SecretHistory(secret.SecretName, secretsService, window, onUpdate)
 
-	return container.NewVBox(header, valueRow, historyComponent)
+	return container.NewVBox(header, valueRow, noteEntry, SecretOrganize(secret, secretsService, window, onUpdate, onSelect), CustomFields(secret, secretsService, window, onUpdate), Attachments(secret, secretsService, window, onUpdate), historyComponent)
 }
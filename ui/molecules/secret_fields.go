@@ -0,0 +1,139 @@
+package molecules
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"go-password-manager/ui/atoms"
+	"go-password-manager/ui/helpers"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// secretFieldsForm builds an entry for every field of a secret type, filled from values,
+// and returns it with a function reading back what was typed
+func secretFieldsForm(spec service.SecretTypeSpec, values map[string]string) (*widget.Form, func() map[string]string) {
+	form := widget.NewForm()
+	entries := map[string]*widget.Entry{}
+	for _, field := range spec.Fields {
+		var entry *widget.Entry
+		switch {
+		case field.Multiline:
+			entry = widget.NewMultiLineEntry()
+			entry.SetMinRowsVisible(4)
+		case field.Sensitive:
+			entry = widget.NewPasswordEntry()
+		default:
+			entry = widget.NewEntry()
+		}
+		entry.SetText(values[field.Name])
+		if field.Required {
+			entry.SetPlaceHolder("Required")
+		}
+		entries[field.Name] = entry
+		form.Append(field.Label, container.NewGridWrap(fyne.NewSize(500, entry.MinSize().Height), entry))
+	}
+
+	return form, func() map[string]string {
+		fields := make(map[string]string, len(entries))
+		for name, entry := range entries {
+			fields[name] = entry.Text
+		}
+		return fields
+	}
+}
+
+// typedSecretDetail shows the fields of a typed secret, sensitive ones hidden until revealed,
+// and edits them all at once
+func typedSecretDetail(secret domain.Secret, spec service.SecretTypeSpec, secretsService *service.SecretsService, window fyne.Window, onUpdate func(), onEditing func(bool)) fyne.CanvasObject {
+	revealed := map[string]bool{}
+	editMode := false
+
+	nameLabel := widget.NewLabel(secret.SecretName)
+	nameLabel.TextStyle = fyne.TextStyle{Bold: true}
+	typeLabel := widget.NewLabel(spec.Label)
+	typeLabel.TextStyle = fyne.TextStyle{Italic: true}
+	editBtn := widget.NewButton("✏️", nil)
+	header := container.NewBorder(nil, nil, nil, container.NewHBox(typeLabel, editBtn), nameLabel)
+
+	fields, err := secretsService.GetSecretFields(&secret)
+	if err != nil {
+		return container.NewVBox(header, widget.NewLabel("Failed to decrypt: "+err.Error()))
+	}
+
+	body := container.NewVBox()
+	var getFields func() map[string]string
+
+	var showFields func()
+	showFields = func() {
+		form := widget.NewForm()
+		for _, field := range spec.Fields {
+			value := fields[field.Name]
+			if value == "" {
+				continue
+			}
+			copyValue := func() { helpers.CopyToClipboard(value, window) }
+
+			if !field.Sensitive {
+				valueButton := widget.NewButton(value, copyValue)
+				valueButton.Importance = widget.LowImportance
+				valueButton.Alignment = widget.ButtonAlignLeading
+				form.Append(field.Label, valueButton)
+				continue
+			}
+			name := field.Name
+			form.Append(field.Label, atoms.SecretValue(atoms.SecretValueProps{
+				Value:      value,
+				IsRevealed: revealed[name],
+				OnRevealClick: func() {
+					revealed[name] = !revealed[name]
+					showFields()
+				},
+				OnValueClick: copyValue,
+			}))
+		}
+		body.Objects = []fyne.CanvasObject{form}
+		body.Refresh()
+	}
+	showFields()
+
+	noteEntry := widget.NewEntry()
+	noteEntry.SetPlaceHolder("Why did it change? (optional)")
+
+	editBtn.OnTapped = func() {
+		if !editMode {
+			editMode = true
+			editBtn.SetText("💾")
+			if onEditing != nil {
+				onEditing(true)
+			}
+			var form *widget.Form
+			form, getFields = secretFieldsForm(spec, fields)
+			noteEntry.SetText("")
+			form.Append("Change Note", container.NewGridWrap(fyne.NewSize(500, noteEntry.MinSize().Height), noteEntry))
+			body.Objects = []fyne.CanvasObject{form}
+			body.Refresh()
+			return
+		}
+
+		if err := secretsService.UpdateSecretFields(secret.SecretName, getFields(), noteEntry.Text); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		editMode = false
+		editBtn.SetText("✏️")
+		fields = getFields()
+		revealed = map[string]bool{}
+		showFields()
+		if onEditing != nil {
+			onEditing(false)
+		}
+		if onUpdate != nil {
+			onUpdate()
+		}
+	}
+
+	return container.NewVBox(header, body)
+}
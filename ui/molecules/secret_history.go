@@ -10,10 +10,12 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
-func SecretHistory(secretName string, secretsService *service.SecretsService, window fyne.Window) fyne.CanvasObject {
+// SecretHistory lists the older versions of a secret. onUpdate is called after one was restored.
+func SecretHistory(secretName string, secretsService *service.SecretsService, window fyne.Window, onUpdate func()) fyne.CanvasObject {
 	// Load the secret to get its versions
 	secret, err := secretsService.GetSecret(secretName)
 	if err != nil || secret == nil || len(secret.Versions) <= 1 {
@@ -32,7 +34,7 @@ func SecretHistory(secretName string, secretsService *service.SecretsService, wi
 	// Add each version (skip the latest one since it's shown above)
 	// Versions are sorted descending, so skip the first one (current version)
 	for _, version := range versions[1:] {
-		historyBox.Add(createVersionItem(*secret, version, secretsService, window))
+		historyBox.Add(createVersionItem(*secret, version, secretsService, window, onUpdate))
 	}
 
 	return container.NewVBox(
@@ -42,7 +44,7 @@ func SecretHistory(secretName string, secretsService *service.SecretsService, wi
 	)
 }
 
-func createVersionItem(secret domain.Secret, version domain.SecretVersion, secretsService *service.SecretsService, window fyne.Window) fyne.CanvasObject {
+func createVersionItem(secret domain.Secret, version domain.SecretVersion, secretsService *service.SecretsService, window fyne.Window, onUpdate func()) fyne.CanvasObject {
 	revealed := false
 	var currentPlainValue string
 
@@ -56,9 +58,48 @@ func createVersionItem(secret domain.Secret, version domain.SecretVersion, secre
 	}
 
 	// Version label with date
-	versionLabel := widget.NewLabel(fmt.Sprintf("Version %d - %s", version.Version, dateStr))
+	versionText := fmt.Sprintf("Version %d - %s", version.Version, dateStr)
+	if version.UpdatedBy != "" {
+		versionText += " by " + version.UpdatedBy
+	}
+	if version.RenamedFrom != "" {
+		versionText += " - renamed from " + version.RenamedFrom
+	}
+	versionLabel := widget.NewLabel(versionText)
 	versionLabel.TextStyle = fyne.TextStyle{Italic: true}
 
+	// Restoring adds a new version with this one's content, so nothing is lost
+	restoreBtn := widget.NewButton("Restore this version", func() {
+		dialog.ShowConfirm(
+			"Restore Version",
+			fmt.Sprintf("Make the content of version %d current again? It is added as a new version.", version.Version),
+			func(confirm bool) {
+				if !confirm {
+					return
+				}
+				if err := secretsService.RevertToVersion(secret.SecretName, version.Version); err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				if onUpdate != nil {
+					onUpdate()
+				}
+			},
+			window,
+		)
+	})
+	restoreBtn.Importance = widget.LowImportance
+	if version.Version == secret.CurrentVersion {
+		restoreBtn.Disable()
+	}
+
+	// Note saying why the version was written, if one was given
+	noteLabel := widget.NewLabel(version.Note)
+	noteLabel.Wrapping = fyne.TextWrapWord
+	if version.Note == "" {
+		noteLabel.Hide()
+	}
+
 	// Create a container that will hold the secret value atom
 	valueContainer := container.NewVBox()
 
@@ -94,7 +135,8 @@ func createVersionItem(secret domain.Secret, version domain.SecretVersion, secre
 	updateValueDisplay()
 
 	return container.NewVBox(
-		versionLabel,
+		container.NewBorder(nil, nil, nil, restoreBtn, versionLabel),
+		noteLabel,
 		valueContainer,
 	)
 }
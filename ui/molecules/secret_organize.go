@@ -0,0 +1,99 @@
+package molecules
+
+import (
+	"go-password-manager/internal/domain"
+	"go-password-manager/internal/service"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// SecretOrganize shows the folder and tags of a secret and edits them, and renames or duplicates
+// the secret. onSelect, if set, is given the name to show after a rename or duplicate.
+func SecretOrganize(secret domain.Secret, secretsService *service.SecretsService, window fyne.Window, onUpdate func(), onSelect func(name string)) fyne.CanvasObject {
+	folder := secret.Folder
+	if folder == "" {
+		folder = "/"
+	}
+	summary := "📁 " + folder
+	if len(secret.Tags) > 0 {
+		summary += "   🏷 " + strings.Join(secret.Tags, ", ")
+	}
+	summaryLabel := widget.NewLabel(summary)
+
+	editBtn := widget.NewButton("Organize", func() {
+		folderEntry := widget.NewEntry()
+		folderEntry.SetText(secret.Folder)
+		folderEntry.SetPlaceHolder("aws/prod")
+		tagsEntry := widget.NewEntry()
+		tagsEntry.SetText(strings.Join(secret.Tags, ", "))
+		tagsEntry.SetPlaceHolder("prod, database")
+
+		size := fyne.NewSize(400, folderEntry.MinSize().Height)
+		form := []*widget.FormItem{
+			widget.NewFormItem("Folder", container.NewGridWrap(size, folderEntry)),
+			widget.NewFormItem("Tags", container.NewGridWrap(size, tagsEntry)),
+		}
+		dialog.ShowForm("Organize "+secret.SecretName, "Save", "Cancel", form, func(ok bool) {
+			if !ok {
+				return
+			}
+			err := secretsService.SetSecretFolder(secret.SecretName, folderEntry.Text)
+			if err == nil {
+				err = secretsService.SetSecretTags(secret.SecretName, strings.Split(tagsEntry.Text, ","))
+			}
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if onUpdate != nil {
+				onUpdate()
+			}
+		}, window)
+	})
+	editBtn.Importance = widget.LowImportance
+
+	selected := func(name string) {
+		switch {
+		case onSelect != nil:
+			onSelect(name)
+		case onUpdate != nil:
+			onUpdate()
+		}
+	}
+	askName := func(title, confirm, name string, save func(newName string) error) {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(name)
+		size := fyne.NewSize(400, nameEntry.MinSize().Height)
+		form := []*widget.FormItem{widget.NewFormItem("Name", container.NewGridWrap(size, nameEntry))}
+		dialog.ShowForm(title, confirm, "Cancel", form, func(ok bool) {
+			if !ok {
+				return
+			}
+			newName := strings.TrimSpace(nameEntry.Text)
+			if err := save(newName); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			selected(newName)
+		}, window)
+	}
+
+	renameBtn := widget.NewButton("Rename", func() {
+		askName("Rename "+secret.SecretName, "Rename", secret.SecretName, func(newName string) error {
+			return secretsService.RenameSecret(secret.SecretName, newName)
+		})
+	})
+	renameBtn.Importance = widget.LowImportance
+	duplicateBtn := widget.NewButton("Duplicate", func() {
+		askName("Duplicate "+secret.SecretName, "Duplicate", secret.SecretName+" copy", func(newName string) error {
+			return secretsService.DuplicateSecret(secret.SecretName, newName)
+		})
+	})
+	duplicateBtn.Importance = widget.LowImportance
+
+	return container.NewBorder(nil, nil, nil, container.NewHBox(editBtn, renameBtn, duplicateBtn), summaryLabel)
+}
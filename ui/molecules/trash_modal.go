@@ -0,0 +1,98 @@
+package molecules
+
+import (
+	"fmt"
+	"go-password-manager/internal/logger"
+	"go-password-manager/internal/service"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// TrashModal lists the deleted secrets, most recently deleted first, and restores or purges them.
+// onChange is called after a secret was restored or purged.
+func TrashModal(window fyne.Window, secretsService *service.SecretsService, onChange func()) {
+	trashBox := container.NewVBox()
+	emptyBtn := widget.NewButton("Empty Trash", nil)
+	emptyBtn.Importance = widget.DangerImportance
+
+	changed := func(err error) {
+		if err != nil {
+			logger.Error("Trash operation failed:", err.Error())
+			dialog.ShowError(err, window)
+		}
+		if onChange != nil {
+			onChange()
+		}
+	}
+
+	var refresh func()
+	refresh = func() {
+		trashBox.Objects = nil
+		trash, err := secretsService.ListTrash()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if len(trash) == 0 {
+			trashBox.Add(widget.NewLabel("The trash is empty. Deleted secrets can be restored from here."))
+			emptyBtn.Disable()
+		} else {
+			emptyBtn.Enable()
+		}
+
+		for _, secret := range trash {
+			name := secret.SecretName
+			deletedAt := secret.DeletedAt
+			if t, err := time.Parse(time.RFC3339, secret.DeletedAt); err == nil {
+				deletedAt = t.Local().Format("Jan 2, 2006 15:04:05")
+			}
+			label := widget.NewLabel(fmt.Sprintf("%s (deleted %s, v%d)", name, deletedAt, secret.CurrentVersion))
+
+			restoreBtn := widget.NewButton("Restore", func() {
+				changed(secretsService.RestoreSecret(name))
+				refresh()
+			})
+			purgeBtn := widget.NewButton("Purge", func() {
+				dialog.ShowConfirm(
+					"Purge Secret",
+					"Permanently delete '"+name+"' and all its versions? This cannot be undone.",
+					func(confirm bool) {
+						if confirm {
+							changed(secretsService.PurgeSecret(name))
+							refresh()
+						}
+					},
+					window,
+				)
+			})
+			purgeBtn.Importance = widget.DangerImportance
+			trashBox.Add(container.NewBorder(nil, nil, nil, container.NewHBox(restoreBtn, purgeBtn), label))
+		}
+		trashBox.Refresh()
+	}
+
+	emptyBtn.OnTapped = func() {
+		dialog.ShowConfirm(
+			"Empty Trash",
+			"Permanently delete every secret in the trash? This cannot be undone.",
+			func(confirm bool) {
+				if confirm {
+					_, err := secretsService.PurgeTrash(0)
+					changed(err)
+					refresh()
+				}
+			},
+			window,
+		)
+	}
+	refresh()
+
+	scroll := container.NewVScroll(trashBox)
+	scroll.SetMinSize(fyne.NewSize(480, 320))
+
+	dialog.NewCustom("Trash", "Close", container.NewBorder(nil, emptyBtn, nil, nil, scroll), window).Show()
+}
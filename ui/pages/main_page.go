@@ -2,62 +2,95 @@ package pages
 
 import (
 	config "go-password-manager/internal/config/runtimeconfig"
+	"go-password-manager/internal/domain"
 	"go-password-manager/internal/logger"
 	"go-password-manager/internal/service"
 	"go-password-manager/ui/atoms"
 	"go-password-manager/ui/molecules"
 	"go-password-manager/ui/themes"
-	"strings"
+	"slices"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
-func MainPageWithService(win fyne.Window, secretsService *service.SecretsService, configService *config.ConfigService) fyne.CanvasObject {
+// MainPageWithService renders the secrets view. The returned close func stops its live reload and
+// must be called once the page is no longer shown.
+func MainPageWithService(win fyne.Window, secretsService *service.SecretsService, configService *config.ConfigService) (fyne.CanvasObject, func()) {
 	fileData, _ := secretsService.LoadAllSecrets()
-	var selectedIdx int = -1
+	var selectedName string
+	var query string
+	var scope string // Filter of the node selected in the folder tree
+	var editing bool
 	listBox := container.NewVBox()
 	detailBox := container.NewVBox(widget.NewLabel("Select a secret"))
 
 	var updateList func()
 	var updateDetail func()
 
+	selectedSecret := func() (domain.Secret, bool) {
+		for _, secret := range fileData.Secrets {
+			if secret.SecretName == selectedName {
+				return secret, true
+			}
+		}
+		return domain.Secret{}, false
+	}
+
 	refreshDetail := func() {
-		// Reload the data to get the latest version
-		fileData, _ = secretsService.LoadAllSecrets()
-		if selectedIdx >= 0 && selectedIdx < len(fileData.Secrets) {
+		// Reload the data to get the latest version, and the folders and tags with it
+		updateList()
+		if _, ok := selectedSecret(); ok {
 			updateDetail()
 		}
 	}
 
 	updateDetail = func() {
+		editing = false
 		detailBox.Objects = nil
-		if selectedIdx >= 0 && selectedIdx < len(fileData.Secrets) {
-			detailBox.Add(molecules.SecretDetail(fileData.Secrets[selectedIdx], secretsService, win, refreshDetail))
+		if secret, ok := selectedSecret(); ok {
+			detailBox.Add(molecules.SecretDetail(secret, secretsService, win, refreshDetail, func(isEditing bool) {
+				editing = isEditing
+			}, func(name string) {
+				selectedName = name
+				refreshDetail()
+			}))
 		} else {
 			detailBox.Add(widget.NewLabel("Select a secret"))
 		}
 		detailBox.Refresh()
 	}
 
+	folderTree := molecules.FolderTree(func() ([]string, []string) {
+		return service.FoldersOf(fileData.Secrets), service.TagsOf(fileData.Secrets)
+	}, func(filter string) {
+		scope = filter
+		updateList()
+	})
+
 	updateList = func() {
 		fileData, _ = secretsService.LoadAllSecrets()
+		filter := service.ParseSecretQuery(scope + " " + query)
 		listBox.Objects = nil
-		for i, s := range fileData.Secrets {
-			listBox.Add(atoms.SecretName(s, func(idx int) func() {
+		for _, s := range fileData.Secrets {
+			if !filter.Matches(s) {
+				continue
+			}
+			listBox.Add(atoms.SecretName(s, func(name string) func() {
 				return func() {
-					selectedIdx = idx
+					selectedName = name
 					updateDetail()
 				}
-			}(i), func(secretName string) func() {
+			}(s.SecretName), func(secretName string) func() {
 				return func() {
 					// Show delete confirmation modal
 					molecules.DeleteConfirmationModal(win, molecules.DeleteConfirmationModalProps{
 						SecretName: secretName,
 						OnConfirm: func() {
 							_ = secretsService.DeleteSecret(secretName)
-							selectedIdx = -1
+							selectedName = ""
 							updateList()
 							updateDetail()
 						},
@@ -69,40 +102,53 @@ func MainPageWithService(win fyne.Window, secretsService *service.SecretsService
 			}(s.SecretName)))
 		}
 		listBox.Refresh()
+		folderTree.Refresh()
+	}
+
+	// showWarning puts a notice above the detail pane until the detail is rebuilt
+	showWarning := func(message string) {
+		warning := widget.NewLabel(message)
+		warning.Importance = widget.WarningImportance
+		warning.Wrapping = fyne.TextWrapWord
+		detailBox.Objects = append([]fyne.CanvasObject{warning}, detailBox.Objects...)
+		detailBox.Refresh()
+	}
+
+	// onExternalChange reloads the page after another program changed the vault, keeping an edit in progress
+	onExternalChange := func(change domain.VaultChange) {
+		if change.Err != nil {
+			logger.Error("Failed to reload the changed vault:", change.Err.Error())
+			showWarning("The vault was changed by another program and could not be reloaded: " + change.Err.Error())
+			return
+		}
+		updateList()
+
+		affected := change.Secrets == nil || slices.Contains(change.Secrets, selectedName)
+		if !editing || !affected {
+			updateDetail()
+			return
+		}
+		if _, ok := selectedSecret(); ok {
+			showWarning("This secret was changed by another program while you were editing it. Saving adds your value as a new version on top of that change.")
+		} else {
+			showWarning("This secret was deleted by another program while you were editing it.")
+		}
+	}
+
+	stopWatching := func() {}
+	if stop, err := secretsService.Watch(func(change domain.VaultChange) {
+		fyne.Do(func() { onExternalChange(change) })
+	}); err != nil {
+		logger.Debug("Live reload is unavailable:", err.Error())
+	} else {
+		stopWatching = stop
 	}
 
 	// --- AppHeader logic moved to component ---
 	props := molecules.AppHeaderProps{
-		OnSearch: func(query string) {
-			fileData, _ = secretsService.LoadAllSecrets()
-			listBox.Objects = nil
-			for i, s := range fileData.Secrets {
-				if query == "" || containsIgnoreCase(s.SecretName, query) {
-					listBox.Add(atoms.SecretName(s, func(idx int) func() {
-						return func() {
-							selectedIdx = idx
-							updateDetail()
-						}
-					}(i), func(secretName string) func() {
-						return func() {
-							// Show delete confirmation modal
-							molecules.DeleteConfirmationModal(win, molecules.DeleteConfirmationModalProps{
-								SecretName: secretName,
-								OnConfirm: func() {
-									_ = secretsService.DeleteSecret(secretName)
-									selectedIdx = -1
-									updateList()
-									updateDetail()
-								},
-								OnCancel: func() {
-									// Do nothing on cancel
-								},
-							})
-						}
-					}(s.SecretName)))
-				}
-			}
-			listBox.Refresh()
+		OnSearch: func(q string) {
+			query = q
+			updateList()
 		},
 		OnCreateSecret: func() {
 			molecules.NewSecretModal(win, secretsService, func() {
@@ -127,25 +173,58 @@ func MainPageWithService(win fyne.Window, secretsService *service.SecretsService
 		}
 	}
 
+	props.OnRotateKey = func() {
+		if configService == nil {
+			return
+		}
+		dialog.ShowConfirm(
+			"Rotate Encryption Key",
+			"Generate a new vault key and rewrap every secret's data key?",
+			func(confirm bool) {
+				if !confirm {
+					return
+				}
+				if err := secretsService.RotateKey(configService); err != nil {
+					logger.Error("Key rotation failed:", err.Error())
+					dialog.ShowError(err, win)
+					return
+				}
+				dialog.ShowInformation("Rotate Encryption Key", "The vault key was rotated successfully.", win)
+			},
+			win,
+		)
+	}
+
+	props.OnShowBackups = func() {
+		molecules.BackupsModal(win, secretsService, func() {
+			selectedName = ""
+			updateList()
+			updateDetail()
+		})
+	}
+
+	props.OnShowTrash = func() {
+		molecules.TrashModal(win, secretsService, func() {
+			updateList()
+			updateDetail()
+		})
+	}
+
 	header := molecules.AppHeader(props, win)
 	updateList()
 
 	split := container.NewHSplit(listBox, detailBox)
 	split.SetOffset(0.3) // This sets the split ratio, not a fixed size
 
+	sidebar := container.NewHSplit(folderTree, split)
+	sidebar.SetOffset(0.2)
+
 	content := container.NewBorder(
 		header, // top
 		nil,    // bottom
 		nil,    // left
 		nil,    // right
-		container.NewHSplit(listBox, detailBox),
+		sidebar,
 	)
-	return content
-}
-
-// Helper for case-insensitive substring search
-func containsIgnoreCase(s, substr string) bool {
-	s = strings.ToLower(s)
-	substr = strings.ToLower(substr)
-	return strings.Contains(s, substr)
+	return content, stopWatching
 }
@@ -0,0 +1,103 @@
+package pages
+
+import (
+	"errors"
+	"go-password-manager/internal/crypto"
+	"go-password-manager/internal/service"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// UnlockPageProps holds the properties for the unlock page
+type UnlockPageProps struct {
+	IsNewVault bool                              // Ask the user to choose a master password instead of entering it
+	OnUnlock   func(masterPassword string) error // Called with the entered password, returns an error to display
+}
+
+// UnlockPage renders the master password prompt shown before the vault is opened
+func UnlockPage(win fyne.Window, props UnlockPageProps) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle("Unlock Vault", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	if props.IsNewVault {
+		title.SetText("Create Master Password")
+	}
+
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("Master password")
+
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.SetPlaceHolder("Confirm master password")
+	if !props.IsNewVault {
+		confirmEntry.Hide()
+	}
+
+	errorLabel := widget.NewLabel("")
+	errorLabel.Importance = widget.DangerImportance
+	errorLabel.Alignment = fyne.TextAlignCenter
+	errorLabel.Hide()
+
+	showError := func(msg string) {
+		errorLabel.SetText(msg)
+		errorLabel.Show()
+	}
+
+	var unlockBtn *widget.Button
+	submit := func() {
+		password := passwordEntry.Text
+		if password == "" {
+			showError("Please enter a master password")
+			return
+		}
+		if props.IsNewVault && password != confirmEntry.Text {
+			showError("Passwords do not match")
+			return
+		}
+
+		unlockBtn.Disable()
+		err := props.OnUnlock(password)
+		unlockBtn.Enable()
+		if err != nil {
+			switch {
+			case errors.Is(err, crypto.ErrWrongPassword):
+				showError("Incorrect master password")
+			case errors.Is(err, service.ErrVaultTampered):
+				showError("The vault file was modified outside the app and was not opened")
+			case errors.Is(err, service.ErrVaultRolledBack):
+				showError("The vault file was replaced by an older copy and was not opened")
+			case errors.Is(err, service.ErrSchemaTooNew):
+				showError("The vault was saved by a newer version of the app. Upgrade to open it")
+			default:
+				showError(err.Error())
+			}
+			passwordEntry.SetText("")
+			win.Canvas().Focus(passwordEntry)
+		}
+	}
+
+	buttonText := "Unlock"
+	if props.IsNewVault {
+		buttonText = "Create Vault"
+	}
+	unlockBtn = widget.NewButton(buttonText, submit)
+	unlockBtn.Importance = widget.HighImportance
+
+	passwordEntry.OnSubmitted = func(string) {
+		if props.IsNewVault {
+			win.Canvas().Focus(confirmEntry)
+			return
+		}
+		submit()
+	}
+	confirmEntry.OnSubmitted = func(string) { submit() }
+
+	form := container.NewVBox(
+		title,
+		passwordEntry,
+		confirmEntry,
+		errorLabel,
+		unlockBtn,
+	)
+
+	return container.NewCenter(container.NewGridWrap(fyne.NewSize(360, form.MinSize().Height), form))
+}